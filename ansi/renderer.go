@@ -0,0 +1,723 @@
+// Package ansi renders a Markdown AST to styled text for terminals.
+//
+// Text is styled with ANSI escape sequences: bold, italics, underline,
+// coloured headings and code highlighted with chroma. Paragraphs are
+// wrapped at RendererOptions.Width, tables are drawn with box characters
+// and links are OSC 8 hyperlinks, which most terminals open on click.
+package ansi
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/alecthomas/chroma"
+	"github.com/alecthomas/chroma/formatters"
+	"github.com/alecthomas/chroma/lexers"
+	"github.com/alecthomas/chroma/styles"
+	"github.com/eternalsad/markdownify/ast"
+	"github.com/eternalsad/markdownify/internal/plain"
+	"github.com/eternalsad/markdownify/parser/latex"
+)
+
+// Default styling used for the blank fields of RendererOptions.
+var (
+	// DefaultWidth is the width paragraphs are wrapped at.
+	DefaultWidth = 80
+	// DefaultHeadingStyles are the SGR parameters of headings by level.
+	DefaultHeadingStyles = []string{"1;35", "1;34", "1;36", "1;32", "1;33", "1"}
+	// DefaultCodeStyle is the chroma style of code blocks.
+	DefaultCodeStyle = "monokai"
+	// DefaultCodeFormatter is the chroma formatter of code blocks, it tells
+	// how many colours the terminal has.
+	DefaultCodeFormatter = "terminal256"
+	// DefaultBullets are the markers of unordered list items by nesting level.
+	DefaultBullets = []string{"•", "◦", "▪"}
+	// DefaultTaskUnchecked and DefaultTaskChecked mark task list items.
+	DefaultTaskUnchecked = "☐"
+	DefaultTaskChecked   = "☑"
+)
+
+// RenderNodeFunc allows reusing most of Renderer logic and replacing
+// rendering of some nodes. If it returns false, Renderer.RenderNode
+// will execute its logic. If it returns true, Renderer.RenderNode will
+// skip rendering this node and will return WalkStatus
+type RenderNodeFunc func(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool)
+
+// RendererOptions is a collection of supplementary parameters tweaking
+// the behavior of various parts of the terminal renderer.
+// Blank fields are replaced by the matching Default* value.
+type RendererOptions struct {
+	// Width is the width paragraphs are wrapped at, including the
+	// indentation of lists and quotes. Negative widths disable wrapping.
+	Width int
+
+	// HeadingStyles are the SGR parameters of headings by level, e.g.
+	// "1;35" for bold magenta. The last one is used for deeper levels.
+	HeadingStyles []string
+
+	// CodeStyle and CodeFormatter are the names of the chroma style and
+	// formatter used to highlight code blocks.
+	CodeStyle     string
+	CodeFormatter string
+
+	// Bullets are the markers of unordered list items by nesting level.
+	Bullets []string
+
+	// TaskUnchecked and TaskChecked are written after the bullet of task
+	// list items.
+	TaskUnchecked string
+	TaskChecked   string
+
+	// NoColor disables escape sequences, the text keeps its layout.
+	NoColor bool
+
+	// NoHyperlinks writes link URLs in parentheses after the text instead
+	// of OSC 8 hyperlinks, for terminals that don't support them.
+	NoHyperlinks bool
+
+	// RawMath renders formulas as written instead of replacing LaTeX
+	// commands with Unicode symbols.
+	RawMath bool
+
+	// if set, called at the start of RenderNode(). Allows replacing
+	// rendering of some nodes
+	RenderNodeHook RenderNodeFunc
+}
+
+// Renderer renders to styled terminal text.
+//
+// Do not create this directly, instead use the NewRenderer function.
+type Renderer struct {
+	Opts RendererOptions
+
+	// prefixes of the lines of the enclosing quotes and list items
+	prefixes []string
+	// written instead of the innermost prefix on the next line, the
+	// marker of a list item
+	marker string
+	// set once a line was written
+	written bool
+	// a blank line is written before the next block
+	needBlank bool
+	// stack of lists being rendered, innermost last
+	lists []*listLevel
+	// > 0 while inside bold text, nested bold text is not closed early
+	boldDepth int
+
+	latex *latex.LaTeXToMarkdownV2
+}
+
+// listLevel is the rendering state of a single (possibly nested) list.
+type listLevel struct {
+	ordered bool
+	tight   bool
+	counter int
+}
+
+// NewRenderer returns a terminal renderer.
+func NewRenderer(opts RendererOptions) *Renderer {
+	if opts.Width == 0 {
+		opts.Width = DefaultWidth
+	}
+	if len(opts.HeadingStyles) == 0 {
+		opts.HeadingStyles = DefaultHeadingStyles
+	}
+	if opts.CodeStyle == "" {
+		opts.CodeStyle = DefaultCodeStyle
+	}
+	if opts.CodeFormatter == "" {
+		opts.CodeFormatter = DefaultCodeFormatter
+	}
+	if len(opts.Bullets) == 0 {
+		opts.Bullets = DefaultBullets
+	}
+	if opts.TaskUnchecked == "" {
+		opts.TaskUnchecked = DefaultTaskUnchecked
+	}
+	if opts.TaskChecked == "" {
+		opts.TaskChecked = DefaultTaskChecked
+	}
+	return &Renderer{
+		Opts:  opts,
+		latex: latex.NewLaTeXToMarkdownV2(),
+	}
+}
+
+// sgr returns the escape sequence selecting the graphic rendition params.
+func (r *Renderer) sgr(params string) string {
+	if r.Opts.NoColor {
+		return ""
+	}
+	return "\x1b[" + params + "m"
+}
+
+// hyperlink returns the OSC 8 escape sequence starting a link to url, or
+// ending the link if url is blank.
+func hyperlink(url string) string {
+	return "\x1b]8;;" + url + "\x1b\\"
+}
+
+// dropControl drops the C0 and C1 control characters, the document can't
+// write escape sequences of its own.
+func dropControl(c rune) rune {
+	if unicode.IsControl(c) {
+		return -1
+	}
+	return c
+}
+
+// stripControl removes the control characters from s except line breaks
+// and tabs.
+func stripControl(s string) string {
+	return strings.Map(func(c rune) rune {
+		if c == '\n' || c == '\t' {
+			return c
+		}
+		return dropControl(c)
+	}, s)
+}
+
+// escapeRe matches the CSI and OSC escape sequences written by the renderer.
+var escapeRe = regexp.MustCompile("\x1b\\[[0-9;]*[A-Za-z]|\x1b\\][^\x1b\x07]*(?:\x1b\\\\|\x07)")
+
+// visibleLen returns the number of characters of s shown by the terminal.
+func visibleLen(s string) int {
+	return utf8.RuneCountInString(escapeRe.ReplaceAllString(s, ""))
+}
+
+// wrap splits text at line breaks and wraps the lines at width.
+func wrap(text string, width int) []string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if width <= 0 {
+			lines = append(lines, line)
+			continue
+		}
+		var cur string
+		curLen := 0
+		for _, word := range strings.Split(line, " ") {
+			n := visibleLen(word)
+			if curLen > 0 && curLen+1+n > width {
+				lines = append(lines, cur)
+				cur, curLen = "", 0
+			}
+			if cur != "" || curLen > 0 {
+				cur += " "
+				curLen++
+			}
+			cur += word
+			curLen += n
+		}
+		lines = append(lines, cur)
+	}
+	return lines
+}
+
+// renderChildren renders the children of node to a string.
+func (r *Renderer) renderChildren(node ast.Node) string {
+	var buf bytes.Buffer
+	for _, child := range node.GetChildren() {
+		ast.WalkFunc(child, func(n ast.Node, entering bool) ast.WalkStatus {
+			return r.RenderNode(&buf, n, entering)
+		})
+	}
+	return buf.String()
+}
+
+// prefix returns the prefix of the next line and consumes the marker.
+func (r *Renderer) prefix() string {
+	if len(r.prefixes) == 0 {
+		return ""
+	}
+	last := len(r.prefixes) - 1
+	prefix := strings.Join(r.prefixes[:last], "")
+	if r.marker != "" {
+		prefix += r.marker
+		r.marker = ""
+	} else {
+		prefix += r.prefixes[last]
+	}
+	return prefix
+}
+
+// width returns the width available to the text of the current block.
+func (r *Renderer) width() int {
+	if r.Opts.Width < 0 {
+		return -1
+	}
+	width := r.Opts.Width - visibleLen(strings.Join(r.prefixes, ""))
+	if width < 20 {
+		width = 20
+	}
+	return width
+}
+
+// startBlock writes the blank line separating blocks if needed.
+func (r *Renderer) startBlock(w io.Writer) {
+	if r.needBlank && r.written && r.marker == "" {
+		io.WriteString(w, strings.TrimRight(strings.Join(r.prefixes, ""), " ")+"\n")
+	}
+	r.needBlank = false
+}
+
+// endBlock marks the end of a block, blocks of tight list items are not
+// separated by blank lines.
+func (r *Renderer) endBlock() {
+	r.needBlank = len(r.lists) == 0 || !r.lists[len(r.lists)-1].tight
+}
+
+// writeLines writes lines with the prefixes of the enclosing blocks.
+func (r *Renderer) writeLines(w io.Writer, lines []string) {
+	for _, line := range lines {
+		io.WriteString(w, r.prefix()+line+"\n")
+		r.written = true
+	}
+}
+
+func (r *Renderer) text(w io.Writer, node *ast.Text) {
+	io.WriteString(w, plain.CleanWithoutTrim(stripControl(string(node.Literal))))
+}
+
+// bold opens or closes bold text. The SGR code ending bold also ends it
+// for the enclosing text, so only the outermost bold text is closed.
+func (r *Renderer) bold(w io.Writer, entering bool) {
+	if entering {
+		if r.boldDepth == 0 {
+			io.WriteString(w, r.sgr("1"))
+		}
+		r.boldDepth++
+	} else {
+		r.boldDepth--
+		if r.boldDepth == 0 {
+			io.WriteString(w, r.sgr("22"))
+		}
+	}
+}
+
+func (r *Renderer) para(w io.Writer, node *ast.Paragraph) {
+	text := strings.TrimSpace(r.renderChildren(node))
+	if item, ok := node.Parent.(*ast.ListItem); ok && item.IsTask && ast.GetFirstChild(item) == node {
+		box := r.Opts.TaskUnchecked
+		if item.Checked {
+			box = r.Opts.TaskChecked
+		}
+		text = box + " " + text
+	}
+	r.startBlock(w)
+	r.writeLines(w, wrap(text, r.width()))
+	r.endBlock()
+}
+
+func (r *Renderer) heading(w io.Writer, node *ast.Heading) {
+	style := r.Opts.HeadingStyles[len(r.Opts.HeadingStyles)-1]
+	if node.Level <= len(r.Opts.HeadingStyles) {
+		style = r.Opts.HeadingStyles[node.Level-1]
+	}
+	// headings are bold already, bold text in them must not end it
+	r.boldDepth++
+	text := strings.TrimSpace(r.renderChildren(node))
+	r.boldDepth--
+	lines := wrap(text, r.width())
+	longest := 0
+	for i, line := range lines {
+		if n := visibleLen(line); n > longest {
+			longest = n
+		}
+		lines[i] = r.sgr(style) + line + r.sgr("0")
+	}
+	switch node.Level {
+	case 1:
+		lines = append(lines, r.sgr(style)+strings.Repeat("═", longest)+r.sgr("0"))
+	case 2:
+		lines = append(lines, r.sgr(style)+strings.Repeat("─", longest)+r.sgr("0"))
+	}
+	r.startBlock(w)
+	r.writeLines(w, lines)
+	r.endBlock()
+}
+
+func (r *Renderer) list(w io.Writer, node *ast.List, entering bool) {
+	if entering {
+		level := &listLevel{
+			ordered: node.ListFlags&ast.ListTypeOrdered != 0,
+			tight:   node.Tight,
+			counter: 1,
+		}
+		if node.Start > 0 {
+			level.counter = node.Start
+		}
+		r.startBlock(w)
+		r.lists = append(r.lists, level)
+		return
+	}
+	r.lists = r.lists[:len(r.lists)-1]
+	r.endBlock()
+}
+
+func (r *Renderer) listItem(w io.Writer, node *ast.ListItem, entering bool) {
+	if !entering {
+		r.prefixes = r.prefixes[:len(r.prefixes)-1]
+		r.marker = ""
+		return
+	}
+	level := r.lists[len(r.lists)-1]
+	var marker string
+	switch {
+	case node.ListFlags&ast.ListTypeTerm != 0:
+		marker = ""
+	case node.ListFlags&ast.ListTypeDefinition != 0:
+		marker = "    "
+	case level.ordered:
+		marker = fmt.Sprintf("%d. ", level.counter)
+		level.counter++
+	default:
+		bullets := r.Opts.Bullets
+		marker = bullets[(len(r.lists)-1)%len(bullets)] + " "
+	}
+	r.startBlock(w)
+	r.prefixes = append(r.prefixes, strings.Repeat(" ", visibleLen(marker)))
+	r.marker = marker
+}
+
+func (r *Renderer) blockQuote(w io.Writer, entering bool) {
+	if entering {
+		r.startBlock(w)
+		r.prefixes = append(r.prefixes, r.sgr("2")+"│"+r.sgr("22")+" ")
+		return
+	}
+	r.prefixes = r.prefixes[:len(r.prefixes)-1]
+	r.endBlock()
+}
+
+func (r *Renderer) link(w io.Writer, dest []byte, node ast.Node) {
+	url := strings.Map(dropControl, string(dest))
+	text := r.renderChildren(node)
+	if text == "" {
+		text = url
+	}
+	switch {
+	case r.Opts.NoHyperlinks && text != url && text != strings.TrimPrefix(url, "mailto:"):
+		io.WriteString(w, r.sgr("4")+text+r.sgr("24")+" "+r.sgr("2")+"("+url+")"+r.sgr("22"))
+	case r.Opts.NoHyperlinks:
+		io.WriteString(w, r.sgr("4")+text+r.sgr("24"))
+	default:
+		// the URL is written without the text styling to keep it
+		// intact when the text is wrapped
+		io.WriteString(w, hyperlink(url)+r.sgr("4")+text+r.sgr("24")+hyperlink(""))
+	}
+}
+
+func (r *Renderer) code(w io.Writer, literal string) {
+	io.WriteString(w, r.sgr("48;5;236")+stripControl(literal)+r.sgr("49"))
+}
+
+// highlight returns code highlighted as language, or guessing the language
+// if it is blank.
+func (r *Renderer) highlight(code, language string) string {
+	if r.Opts.NoColor {
+		return code
+	}
+	lexer := lexers.Get(language)
+	if lexer == nil {
+		lexer = lexers.Analyse(code)
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	style := styles.Get(r.Opts.CodeStyle)
+	formatter := formatters.Get(r.Opts.CodeFormatter)
+	it, err := chroma.Coalesce(lexer).Tokenise(nil, code)
+	if err != nil {
+		return code
+	}
+	var buf bytes.Buffer
+	if err := formatter.Format(&buf, style, it); err != nil {
+		return code
+	}
+	return buf.String()
+}
+
+func (r *Renderer) codeBlock(w io.Writer, node *ast.CodeBlock) {
+	language := ""
+	if info := strings.Fields(string(node.Info)); len(info) > 0 {
+		language = info[0]
+	}
+	lines := strings.Split(r.highlight(stripControl(string(node.Literal)), language), "\n")
+	// lexers end the code with a newline, it may be followed by a reset
+	if last := len(lines) - 1; last > 0 && visibleLen(lines[last]) == 0 {
+		lines[last-1] += lines[last]
+		lines = lines[:last]
+	}
+	for i, line := range lines {
+		lines[i] = "  " + line
+	}
+	r.startBlock(w)
+	r.writeLines(w, lines)
+	r.endBlock()
+}
+
+// mathText returns the text of a formula as it is shown, see
+// RendererOptions.RawMath.
+func (r *Renderer) mathText(literal []byte) string {
+	if r.Opts.RawMath {
+		return string(literal)
+	}
+	return r.latex.ToUnicode(string(literal))
+}
+
+// htmlSpanRe matches an HTML tag and captures the slash of closing tags and
+// the tag name.
+var htmlSpanRe = regexp.MustCompile(`^<(/?)([A-Za-z][A-Za-z0-9-]*)[^>]*>$`)
+
+// spanStyles are the SGR parameters starting and ending the styles of HTML
+// tags.
+var spanStyles = map[string][2]string{
+	"u":   {"4", "24"},
+	"ins": {"4", "24"},
+	"i":   {"3", "23"},
+	"em":  {"3", "23"},
+	"s":   {"9", "29"},
+	"del": {"9", "29"},
+	// the terminal has no spoilers, the text is concealed
+	"tg-spoiler": {"8", "28"},
+}
+
+// htmlSpan writes the style of known inline tags, the other tags are
+// dropped.
+func (r *Renderer) htmlSpan(w io.Writer, node *ast.HTMLSpan) {
+	m := htmlSpanRe.FindStringSubmatch(strings.TrimSpace(string(node.Literal)))
+	if m == nil {
+		return
+	}
+	closing := m[1] == "/"
+	name := strings.ToLower(m[2])
+	switch name {
+	case "br":
+		io.WriteString(w, "\n")
+	case "b", "strong":
+		r.bold(w, !closing)
+	default:
+		if style, ok := spanStyles[name]; ok {
+			if closing {
+				io.WriteString(w, r.sgr(style[1]))
+			} else {
+				io.WriteString(w, r.sgr(style[0]))
+			}
+		}
+	}
+}
+
+// htmlBlock writes the text of an HTML block without the tags.
+func (r *Renderer) htmlBlock(w io.Writer, node *ast.HTMLBlock) {
+	text := plain.HTMLText(stripControl(string(node.Literal)))
+	if text == "" {
+		return
+	}
+	r.startBlock(w)
+	r.writeLines(w, wrap(plain.CleanWithoutTrim(text), r.width()))
+	r.endBlock()
+}
+
+func (r *Renderer) horizontalRule(w io.Writer) {
+	width := r.width()
+	if width < 0 {
+		width = DefaultWidth
+	}
+	r.startBlock(w)
+	r.writeLines(w, []string{r.sgr("2") + strings.Repeat("─", width) + r.sgr("22")})
+	r.endBlock()
+}
+
+// tableCell is a rendered cell of a table.
+type tableCell struct {
+	text  string
+	align ast.CellAlignFlags
+}
+
+// pad returns the text of cell padded to width.
+func (c tableCell) pad(width int) string {
+	space := width - visibleLen(c.text)
+	switch c.align {
+	case ast.TableAlignmentRight:
+		return strings.Repeat(" ", space) + c.text
+	case ast.TableAlignmentCenter:
+		return strings.Repeat(" ", space/2) + c.text + strings.Repeat(" ", space-space/2)
+	}
+	return c.text + strings.Repeat(" ", space)
+}
+
+// table writes the table with box-drawn borders.
+func (r *Renderer) table(w io.Writer, node *ast.Table) {
+	var rows [][]tableCell
+	header := -1
+	columns := 0
+	ast.WalkFunc(node, func(n ast.Node, entering bool) ast.WalkStatus {
+		row, ok := n.(*ast.TableRow)
+		if !ok || !entering {
+			return ast.GoToNext
+		}
+		_, isHeader := row.Parent.(*ast.TableHeader)
+		var cells []tableCell
+		for _, child := range row.Children {
+			cell := tableCell{text: strings.TrimSpace(r.renderChildren(child))}
+			if c, ok := child.(*ast.TableCell); ok {
+				cell.align = c.Align
+			}
+			if isHeader {
+				cell.text = r.sgr("1") + cell.text + r.sgr("22")
+			}
+			cells = append(cells, cell)
+		}
+		if len(cells) > columns {
+			columns = len(cells)
+		}
+		if isHeader {
+			header = len(rows)
+		}
+		rows = append(rows, cells)
+		return ast.SkipChildren
+	})
+	if len(rows) == 0 {
+		return
+	}
+
+	widths := make([]int, columns)
+	for _, row := range rows {
+		for i, cell := range row {
+			if n := visibleLen(cell.text); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+	border := func(left, middle, right string) string {
+		parts := make([]string, columns)
+		for i, width := range widths {
+			parts[i] = strings.Repeat("─", width+2)
+		}
+		return left + strings.Join(parts, middle) + right
+	}
+
+	lines := []string{border("┌", "┬", "┐")}
+	for i, row := range rows {
+		parts := make([]string, columns)
+		for j := range parts {
+			var cell tableCell
+			if j < len(row) {
+				cell = row[j]
+			}
+			parts[j] = " " + cell.pad(widths[j]) + " "
+		}
+		lines = append(lines, "│"+strings.Join(parts, "│")+"│")
+		if i == header && i < len(rows)-1 {
+			lines = append(lines, border("├", "┼", "┤"))
+		}
+	}
+	lines = append(lines, border("└", "┴", "┘"))
+	r.startBlock(w)
+	r.writeLines(w, lines)
+	r.endBlock()
+}
+
+// RenderNode renders a markdown node to styled terminal text.
+func (r *Renderer) RenderNode(w io.Writer, node ast.Node, entering bool) ast.WalkStatus {
+	if r.Opts.RenderNodeHook != nil {
+		status, didHandle := r.Opts.RenderNodeHook(w, node, entering)
+		if didHandle {
+			return status
+		}
+	}
+	switch node := node.(type) {
+	case *ast.Text:
+		r.text(w, node)
+	case *ast.Softbreak:
+		io.WriteString(w, " ")
+	case *ast.Hardbreak:
+		io.WriteString(w, "\n")
+	case *ast.NonBlockingSpace:
+		io.WriteString(w, " ")
+	case *ast.Emph:
+		if entering {
+			io.WriteString(w, r.sgr("3"))
+		} else {
+			io.WriteString(w, r.sgr("23"))
+		}
+	case *ast.Strong:
+		r.bold(w, entering)
+	case *ast.Del:
+		if entering {
+			io.WriteString(w, r.sgr("9"))
+		} else {
+			io.WriteString(w, r.sgr("29"))
+		}
+	case *ast.BlockQuote:
+		r.blockQuote(w, entering)
+	case *ast.Link:
+		if entering {
+			r.link(w, node.Destination, node)
+		}
+		return ast.SkipChildren
+	case *ast.Image:
+		if entering {
+			r.link(w, node.Destination, node)
+		}
+		return ast.SkipChildren
+	case *ast.Code:
+		r.code(w, string(node.Literal))
+	case *ast.CodeBlock:
+		r.codeBlock(w, node)
+	case *ast.Paragraph:
+		if entering {
+			r.para(w, node)
+		}
+		return ast.SkipChildren
+	case *ast.HTMLSpan:
+		r.htmlSpan(w, node)
+	case *ast.HTMLBlock:
+		r.htmlBlock(w, node)
+	case *ast.Heading:
+		if entering {
+			r.heading(w, node)
+		}
+		return ast.SkipChildren
+	case *ast.HorizontalRule:
+		r.horizontalRule(w)
+	case *ast.List:
+		r.list(w, node, entering)
+	case *ast.ListItem:
+		r.listItem(w, node, entering)
+	case *ast.Table:
+		if entering {
+			r.table(w, node)
+		}
+		return ast.SkipChildren
+	case *ast.Math:
+		r.code(w, r.mathText(node.Literal))
+	case *ast.MathBlock:
+		if entering {
+			r.codeBlock(w, &ast.CodeBlock{
+				Info: []byte("text"),
+				Leaf: ast.Leaf{Literal: []byte(strings.TrimSpace(r.mathText(node.Literal)))},
+			})
+		}
+		return ast.SkipChildren
+	default:
+		// the other nodes have no terminal styling, their children are
+		// rendered as they are
+	}
+	return ast.GoToNext
+}
+
+// RenderHeader renders header
+func (r *Renderer) RenderHeader(w io.Writer, ast ast.Node) {
+	// do nothing
+}
+
+// RenderFooter renders footer
+func (r *Renderer) RenderFooter(w io.Writer, ast ast.Node) {
+	// do nothing
+}
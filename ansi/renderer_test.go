@@ -0,0 +1,76 @@
+package ansi
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/eternalsad/markdownify/internal/rendertest"
+)
+
+func testRendering(t *testing.T, opts RendererOptions, source string, expected string) {
+	t.Helper()
+	rendertest.Expect(t, source, expected, rendertest.Render(NewRenderer(opts), source))
+}
+
+func TestRenderInline(t *testing.T) {
+	testRendering(t, RendererOptions{}, "**bold *both* x** *italic* ~~strike~~ <u>under</u>\n",
+		"\x1b[1mbold \x1b[3mboth\x1b[23m x\x1b[22m \x1b[3mitalic\x1b[23m \x1b[9mstrike\x1b[29m \x1b[4munder\x1b[24m\n")
+}
+
+func TestRenderLinks(t *testing.T) {
+	testRendering(t, RendererOptions{}, "[docs](https://go.dev)\n",
+		"\x1b]8;;https://go.dev\x1b\\\x1b[4mdocs\x1b[24m\x1b]8;;\x1b\\\n")
+	testRendering(t, RendererOptions{NoColor: true, NoHyperlinks: true}, "[docs](https://go.dev) https://go.dev\n",
+		"docs (https://go.dev) https://go.dev\n")
+}
+
+func TestRenderControlChars(t *testing.T) {
+	opts := RendererOptions{NoColor: true}
+	testRendering(t, opts, "a\x1b[31mred\x1b[0m \u009b2J `\x1b]0;title\x07`\n", "a[31mred[0m 2J ]0;title\n")
+	testRendering(t, opts, "```\n\x1b[2J\tx\n```\n", "  [2J\tx\n")
+	testRendering(t, RendererOptions{}, "[docs](https://go.dev/\x1b[2J\x07)\n",
+		"\x1b]8;;https://go.dev/[2J\x1b\\\x1b[4mdocs\x1b[24m\x1b]8;;\x1b\\\n")
+}
+
+func TestRenderHeading(t *testing.T) {
+	testRendering(t, RendererOptions{}, "## Title **bold**\n",
+		"\x1b[1;34mTitle bold\x1b[0m\n\x1b[1;34m──────────\x1b[0m\n")
+	testRendering(t, RendererOptions{NoColor: true}, "# A\n\ntext\n", "A\n═\n\ntext\n")
+}
+
+func TestRenderWrap(t *testing.T) {
+	opts := RendererOptions{Width: 20, NoColor: true}
+	testRendering(t, opts, "one two three four five six seven eight\n",
+		"one two three four\nfive six seven eight\n")
+	testRendering(t, RendererOptions{Width: 22, NoColor: true}, "> aaaa bbbb cccc dddd eeee\n",
+		"│ aaaa bbbb cccc dddd\n│ eeee\n")
+	testRendering(t, RendererOptions{Width: 20}, "one **two three four five**\n",
+		"one \x1b[1mtwo three four\nfive\x1b[22m\n")
+}
+
+func TestRenderLists(t *testing.T) {
+	opts := RendererOptions{NoColor: true}
+	testRendering(t, opts, "- a\n    - b\n- [x] c\n\n3. d\n4. e\n",
+		"• a\n  ◦ b\n• ☑ c\n\n3. d\n4. e\n")
+	testRendering(t, RendererOptions{Width: 20, NoColor: true}, "- one two three four five six\n",
+		"• one two three four\n  five six\n")
+}
+
+func TestRenderCodeBlock(t *testing.T) {
+	testRendering(t, RendererOptions{NoColor: true}, "text\n\n```go\nx := 1\n```\n", "text\n\n  x := 1\n")
+	got := rendertest.Render(NewRenderer(RendererOptions{}), "```go\nfunc main() {}\n```\n")
+	if !strings.Contains(got, "\x1b[") {
+		t.Errorf("expected highlighted code, got %q", got)
+	}
+}
+
+func TestRenderTable(t *testing.T) {
+	source := "| Name | Year |\n|---|--:|\n| Go | 2009 |\n| C | 72 |\n"
+	expected := "┌──────┬──────┐\n" +
+		"│ Name │ Year │\n" +
+		"├──────┼──────┤\n" +
+		"│ Go   │ 2009 │\n" +
+		"│ C    │   72 │\n" +
+		"└──────┴──────┘\n"
+	testRendering(t, RendererOptions{NoColor: true}, source, expected)
+}
@@ -218,6 +218,8 @@ type ListItem struct {
 	Delimiter       byte   // '.' or ')' after the number in ordered lists
 	RefLink         []byte // If not nil, turns this list item into a footnote item and triggers different rendering
 	IsFootnotesList bool   // This is a list of footnotes
+	IsTask          bool   // Item started with a task marker, "[ ]" or "[x]"
+	Checked         bool   // For task items, true if the marker was "[x]"
 }
 
 // Paragraph represents markdown paragraph node
@@ -149,6 +149,13 @@ func printRecur(w io.Writer, node Node, prefix string, depth int) {
 		if v.Tight {
 			content += "tight "
 		}
+		if v.IsTask {
+			if v.Checked {
+				content += "task=checked "
+			} else {
+				content += "task=unchecked "
+			}
+		}
 		if v.IsFootnotesList {
 			content += "footnotes "
 		}
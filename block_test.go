@@ -89,6 +89,10 @@ func TestNestedDefinitionList(t *testing.T) {
 	doTestsBlock(t, "NestedDefinitionList.tests", parser.DefinitionLists)
 }
 
+func TestTaskList(t *testing.T) {
+	doTestsBlock(t, "TaskList.tests", parser.TaskLists)
+}
+
 func TestPreformattedHtml(t *testing.T) {
 	doTestsBlock(t, "PreformattedHtml.tests", 0)
 }
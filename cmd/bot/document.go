@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/eternalsad/markdownify/contract"
+	"github.com/eternalsad/markdownify/telegram"
+)
+
+// Максимальный размер присланного файла, как у конвертера документов
+var maxDocumentSize = int64(contract.DocumentOptions.MaxInputSize)
+
+// Скачивает присланный .md или .txt файл, конвертирует его в MarkdownV2 и
+// отправляет результат, разбитый на сообщения
+func convertDocument(ctx context.Context, req *request) error {
+	doc := req.msg.Document
+	if !markdownExtensions[strings.ToLower(filepath.Ext(doc.FileName))] {
+		return req.reply(ctx, "Поддерживаются только файлы .md и .txt")
+	}
+	if doc.FileSize > maxDocumentSize {
+		return req.reply(ctx, fmt.Sprintf("Файл слишком большой: %d КБ, максимум %d КБ", doc.FileSize>>10, maxDocumentSize>>10))
+	}
+
+	file, err := req.client.GetFile(ctx, doc.FileID)
+	if err != nil {
+		return fmt.Errorf("не удалось получить файл %s: %w", doc.FileName, err)
+	}
+	data, err := req.client.DownloadFile(ctx, file.FilePath, maxDocumentSize)
+	if errors.Is(err, telegram.ErrFileTooLarge) {
+		return req.reply(ctx, fmt.Sprintf("Файл слишком большой, максимум %d КБ", maxDocumentSize>>10))
+	}
+	if err != nil {
+		return fmt.Errorf("не удалось скачать файл %s: %w", doc.FileName, err)
+	}
+	if !utf8.Valid(data) {
+		return req.reply(ctx, "Файл должен быть текстом в кодировке UTF-8")
+	}
+
+	output, err := contract.ConvertMD2WithOptions(string(data), contract.DocumentOptions)
+	if err != nil {
+		return err
+	}
+	messages := contract.SplitMessages(output, contract.MessageLimit)
+	if len(messages) == 0 {
+		return req.reply(ctx, fmt.Sprintf("Файл %s пуст", doc.FileName))
+	}
+	// Короткий документ при ошибке разметки отправляется с entities,
+	// части длинного - простым текстом
+	source := ""
+	if len(messages) == 1 {
+		source = string(data)
+	}
+	for i, message := range messages {
+		if _, err := sendMarkdown(ctx, req.client, req.chatID, contract.DocumentOptions, source, message); err != nil {
+			return fmt.Errorf("не удалось отправить часть %d из %d: %w", i+1, len(messages), err)
+		}
+	}
+	log.Printf("Файл %s отправлен в чат %d, сообщений: %d", doc.FileName, req.chatID, len(messages))
+	return nil
+}
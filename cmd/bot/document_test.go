@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/eternalsad/markdownify/telegram"
+)
+
+// Отправляет боту файл от пользователя
+func sendFile(srv *testServer, fileName string, data []byte) {
+	update := srv.SendFile(42, fileName, data)
+	client := telegram.NewSender(srv.Bot(), testSenderOptions)
+	newBotRouter(&Config{}, srv.files).dispatch(context.Background(), client, update.Message)
+}
+
+func TestDocument(t *testing.T) {
+	srv := newTestServer(t)
+	sendFile(srv, "notes.md", []byte("# Заметки\n\n**Жирный** текст."))
+	msgs := srv.Messages()
+	if len(msgs) != 1 || msgs[0].ParseMode != telegram.ModeMarkdownV2 || !strings.HasSuffix(msgs[0].PlainText, "Заметки\n\nЖирный текст.") {
+		t.Errorf("messages = %+v", msgs)
+	}
+}
+
+func TestLongDocument(t *testing.T) {
+	srv := newTestServer(t)
+	var b strings.Builder
+	for i := 0; i < 300; i++ {
+		fmt.Fprintf(&b, "Абзац %d с **жирным** текстом и [ссылкой](https://example.com/%d).\n\n", i, i)
+	}
+	sendFile(srv, "long.txt", []byte(b.String()))
+	msgs := srv.Messages()
+	if len(msgs) < 2 {
+		t.Fatalf("expected several messages, got %d", len(msgs))
+	}
+	for _, msg := range msgs {
+		if msg.ParseMode != telegram.ModeMarkdownV2 {
+			t.Errorf("message = %+v", msg)
+		}
+	}
+	if !strings.HasPrefix(msgs[0].PlainText, "Абзац 0 с жирным") || !strings.HasSuffix(msgs[len(msgs)-1].PlainText, "Абзац 299 с жирным текстом и ссылкой.") {
+		t.Errorf("first = %q, last = %q", msgs[0].PlainText, msgs[len(msgs)-1].PlainText)
+	}
+}
+
+func TestDocumentRejected(t *testing.T) {
+	tests := []struct {
+		fileName string
+		data     []byte
+		reply    string
+	}{
+		{"image.png", []byte("png"), "Поддерживаются только файлы .md и .txt"},
+		{"big.md", make([]byte, maxDocumentSize+1), "Файл слишком большой: 1024 КБ, максимум 1024 КБ"},
+		{"binary.md", []byte{0xff, 0xfe}, "Файл должен быть текстом в кодировке UTF-8"},
+		{"empty.md", []byte("\n\n"), "Файл empty.md пуст"},
+	}
+	for _, test := range tests {
+		srv := newTestServer(t)
+		sendFile(srv, test.fileName, test.data)
+		msgs := srv.Messages()
+		if len(msgs) != 1 || msgs[0].PlainText != test.reply {
+			t.Errorf("%s: messages = %+v", test.fileName, msgs)
+		}
+	}
+}
+
+func TestStripMarkdownV2(t *testing.T) {
+	tests := map[string]string{
+		"*bold* _it_ __u__ ~s~ ||sp|| 1\\.5":      "bold it u s sp 1.5",
+		"[link](https://example.com/a\\)b) `a*b`": "link (https://example.com/a)b) a*b",
+		"```go\nx := *p\n```":                     "x := *p\n",
+		">quote\n**>expandable||":                 "quote\nexpandable",
+	}
+	for text, expected := range tests {
+		if got := stripMarkdownV2(text); got != expected {
+			t.Errorf("%q: got %q, expected %q", text, got, expected)
+		}
+	}
+}
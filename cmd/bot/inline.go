@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/eternalsad/markdownify/contract"
+	"github.com/eternalsad/markdownify/telegram"
+)
+
+// Сколько секунд Telegram хранит ответ на inline-запрос. Ответ зависит
+// только от текста запроса
+const inlineCacheTime = 60
+
+// Длина описания результата, которое показывается под заголовком
+const inlinePreviewLength = 100
+
+// Ошибка для варианта, после преобразования которого не осталось текста
+var errEmptyResult = errors.New("после преобразования не осталось текста")
+
+// Отвечает на inline-запрос вида "@бот **markdown**" вариантами
+// преобразования текста запроса: MarkdownV2, HTML и простой текст. Вариант,
+// который не удалось получить, заменяется результатом с объяснением.
+// Inline-режим включается у бота через @BotFather
+func answerInline(ctx context.Context, req *request) error {
+	params := telegram.AnswerInlineQueryParams{
+		InlineQueryID: req.query.ID,
+		// пустой список, а не null: на пустой запрос результатов нет
+		Results:    []telegram.InlineQueryResultArticle{},
+		CacheTime:  inlineCacheTime,
+		IsPersonal: true,
+	}
+	if strings.TrimSpace(req.query.Query) != "" {
+		params.Results = inlineResults(req.query.Query)
+	}
+	err := req.client.AnswerInlineQuery(ctx, params)
+	if !telegram.IsParseError(err) {
+		return err
+	}
+
+	// рендерер выдал разметку, которую Telegram не принял, остается
+	// простой текст и объяснение
+	log.Printf("Telegram не разобрал разметку в ответе на inline-запрос пользователя %d: %v", req.chatID, err)
+	var results []telegram.InlineQueryResultArticle
+	for _, result := range params.Results {
+		if result.InputMessageContent.ParseMode == "" {
+			results = append(results, result)
+		}
+	}
+	params.Results = append(results, inlineError("markup", "Telegram не принял разметку", err))
+	return req.client.AnswerInlineQuery(ctx, params)
+}
+
+// Преобразует Markdown из inline-запроса в результаты для каждого формата
+func inlineResults(query string) []telegram.InlineQueryResultArticle {
+	opts := contract.ChatOptions
+	plain, _, plainErr := contract.ConvertMDToEntitiesWithOptions(query, opts)
+	markdownV2, markdownV2Err := contract.ConvertMD2WithOptions(query, opts)
+	html, htmlErr := contract.ConvertTelegramHTMLWithOptions(query, opts)
+
+	var results []telegram.InlineQueryResultArticle
+	add := func(id, title, text, parseMode string, err error) {
+		if err == nil && strings.TrimSpace(text) == "" {
+			err = errEmptyResult
+		}
+		if err != nil {
+			results = append(results, inlineError(id, "Не удалось преобразовать в "+title, err))
+			return
+		}
+		results = append(results, telegram.InlineQueryResultArticle{
+			ID:          id,
+			Title:       title,
+			Description: preview(plain),
+			InputMessageContent: telegram.InputTextMessageContent{
+				MessageText: text,
+				ParseMode:   parseMode,
+			},
+		})
+	}
+	add("markdownv2", "MarkdownV2", markdownV2, telegram.ModeMarkdownV2, markdownV2Err)
+	add("html", "HTML", html, telegram.ModeHTML, htmlErr)
+	add("plain", "Простой текст", plain, "", plainErr)
+	return results
+}
+
+// Результат с объяснением ошибки. Если его выбрать, в чат отправляется
+// текст ошибки
+func inlineError(id, title string, err error) telegram.InlineQueryResultArticle {
+	return telegram.InlineQueryResultArticle{
+		ID:          "error-" + id,
+		Title:       title,
+		Description: err.Error(),
+		InputMessageContent: telegram.InputTextMessageContent{
+			MessageText: fmt.Sprintf("%s: %v", title, err),
+		},
+	}
+}
+
+// Начало текста в одну строку для описания результата
+func preview(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if runes := []rune(text); len(runes) > inlinePreviewLength {
+		return string(runes[:inlinePreviewLength-1]) + "…"
+	}
+	return text
+}
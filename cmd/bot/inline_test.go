@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/eternalsad/markdownify/telegram"
+	"github.com/eternalsad/markdownify/telegram/telegramtest"
+)
+
+// Отправляет боту inline-запрос и возвращает ответ
+func sendInlineQuery(t *testing.T, srv *testServer, config *Config, query string) telegramtest.InlineAnswer {
+	t.Helper()
+	update := srv.SendInlineQuery(7, query)
+	client := telegram.NewSender(srv.Bot(), testSenderOptions)
+	newDispatcher(client, newBotRouter(config, srv.files))(context.Background(), update)
+	answers := srv.InlineAnswers()
+	if len(answers) == 0 || answers[len(answers)-1].QueryID != update.InlineQuery.ID {
+		t.Fatalf("query %q is not answered: %+v", query, answers)
+	}
+	return answers[len(answers)-1]
+}
+
+func TestInlineQuery(t *testing.T) {
+	srv := newTestServer(t)
+	answer := sendInlineQuery(t, srv, &Config{}, "**bold** & `x<y`")
+	expected := []struct{ id, parseMode string }{
+		{"markdownv2", telegram.ModeMarkdownV2},
+		{"html", telegram.ModeHTML},
+		{"plain", ""},
+	}
+	if len(answer.Results) != len(expected) {
+		t.Fatalf("results = %+v", answer.Results)
+	}
+	for i, result := range answer.Results {
+		if result.ID != expected[i].id || result.ParseMode != expected[i].parseMode {
+			t.Errorf("result %d = %+v", i, result)
+		}
+		if result.PlainText != "bold & x<y" || result.Description != "bold & x<y" {
+			t.Errorf("%s: text = %q, description = %q", result.ID, result.PlainText, result.Description)
+		}
+	}
+	if html := answer.Results[1].Text; html != "<b>bold</b> &amp; <code>x&lt;y</code>" {
+		t.Errorf("html = %q", html)
+	}
+
+	// на пустой запрос результатов нет
+	if answer := sendInlineQuery(t, srv, &Config{}, "  "); len(answer.Results) != 0 {
+		t.Errorf("results = %+v", answer.Results)
+	}
+}
+
+func TestInlineQueryErrors(t *testing.T) {
+	srv := newTestServer(t)
+	// текст длиннее contract.ChatOptions.MaxInputSize не преобразуется
+	answer := sendInlineQuery(t, srv, &Config{}, strings.Repeat("a", 20<<10))
+	if len(answer.Results) != 3 {
+		t.Fatalf("results = %+v", answer.Results)
+	}
+	for _, result := range answer.Results {
+		if !strings.HasPrefix(result.ID, "error-") || !strings.Contains(result.Description, "too large") ||
+			!strings.HasPrefix(result.PlainText, "Не удалось преобразовать в ") {
+			t.Errorf("result = %+v", result)
+		}
+	}
+
+	answer = sendInlineQuery(t, srv, &Config{AllowedChats: []int64{1}}, "**bold**")
+	if len(answer.Results) != 1 || answer.Results[0].PlainText != "Ошибка: доступ запрещен" {
+		t.Errorf("results = %+v", answer.Results)
+	}
+}
+
+func TestPreview(t *testing.T) {
+	if got := preview("a\n\nb  c"); got != "a b c" {
+		t.Errorf("preview = %q", got)
+	}
+	if got := []rune(preview(strings.Repeat("я", 200))); len(got) != inlinePreviewLength || got[len(got)-1] != '…' {
+		t.Errorf("preview = %q", string(got))
+	}
+}
@@ -2,119 +2,134 @@ package main
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"github.com/eternalsad/markdownify/ast"
+	"github.com/eternalsad/markdownify/contract"
 	"github.com/eternalsad/markdownify/md2"
 	"github.com/eternalsad/markdownify/parser"
-	"io/ioutil"
+	"github.com/eternalsad/markdownify/telegram"
+	"io/fs"
 	"log"
 	"net/http"
 	"os"
-	"path/filepath"
+	"os/signal"
+	"path"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 )
 
 // Конфигурация бота
 type Config struct {
 	BotToken string
-	Offset   int
+	// Адрес Bot API, по умолчанию telegram.DefaultBaseURL
+	APIURL string
+	// Файл, в котором хранится offset getUpdates между перезапусками.
+	// Если пусто, offset не сохраняется
+	OffsetFile string
+	// Число обновлений, которые обрабатываются одновременно
+	Workers int
+
+	// Способ получения обновлений: modePolling или modeWebhook
+	Mode string
+	// Адрес и путь, на которых вебхук принимает обновления
+	ListenAddr  string
+	WebhookPath string
+	// Публичный адрес вебхука. Если задан, бот сам вызывает setWebhook,
+	// иначе вебхук регистрируется отдельно, например при деплое
+	WebhookURL string
+	// Секрет, который Telegram передает в заголовке
+	// X-Telegram-Bot-Api-Secret-Token
+	WebhookSecret string
+	// Файлы сертификата и ключа. Если не заданы, вебхук работает по HTTP,
+	// а TLS завершается на ingress
+	TLSCertFile string
+	TLSKeyFile  string
+
+	// Имя бота без "@", чтобы в группах отвечать только на свои команды
+	BotName string
+	// Чаты, которым разрешено пользоваться ботом. Если пусто - всем
+	AllowedChats []int64
+	// Директория с тестовыми файлами для /files и /file
+	TestDir string
 }
 
-// Структуры для работы с API Telegram
-type Update struct {
-	UpdateID int     `json:"update_id"`
-	Message  Message `json:"message"`
-}
-
-type Message struct {
-	Chat Chat   `json:"chat"`
-	Text string `json:"text"`
-}
-
-type Chat struct {
-	ID int `json:"id"`
-}
-
-type SendMessageRequest struct {
-	ChatID    int    `json:"chat_id"`
-	Text      string `json:"text"`
-	ParseMode string `json:"parse_mode"`
-}
-
-type ApiResponse struct {
-	Ok     bool            `json:"ok"`
-	Result json.RawMessage `json:"result"`
-}
-
-// GetUpdates получает обновления от API Telegram
-func GetUpdates(config *Config) ([]Update, error) {
-	// Формируем URL для API запроса
-	url := fmt.Sprintf("https://api.telegram.org/bot%s/getUpdates?offset=%d&timeout=60", config.BotToken, config.Offset)
-
-	// Отправляем GET запрос
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	// Читаем и проверяем ответ
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
+// Способы получения обновлений
+const (
+	modePolling = "polling"
+	modeWebhook = "webhook"
+)
 
-	var apiResp ApiResponse
-	if err := json.Unmarshal(body, &apiResp); err != nil {
-		return nil, err
+// Читает конфигурацию из флагов и переменных окружения. Флаги имеют
+// приоритет, секреты задаются только через окружение
+func loadConfig(args []string) (*Config, error) {
+	config := &Config{
+		BotToken:      os.Getenv("TELEGRAM_BOT_TOKEN"),
+		APIURL:        os.Getenv("TELEGRAM_API_URL"),
+		WebhookSecret: os.Getenv("WEBHOOK_SECRET"),
+		BotName:       strings.TrimPrefix(os.Getenv("BOT_USERNAME"), "@"),
 	}
-
-	if !apiResp.Ok {
-		return nil, fmt.Errorf("API вернул ошибку: %s", string(body))
+	envOr := func(key, value string) string {
+		if v := os.Getenv(key); v != "" {
+			return v
+		}
+		return value
 	}
 
-	var updates []Update
-	if err := json.Unmarshal(apiResp.Result, &updates); err != nil {
+	flags := flag.NewFlagSet("bot", flag.ContinueOnError)
+	flags.StringVar(&config.Mode, "mode", envOr("BOT_MODE", modePolling), "способ получения обновлений: polling или webhook (BOT_MODE)")
+	flags.StringVar(&config.ListenAddr, "listen", envOr("WEBHOOK_LISTEN", ":8443"), "адрес вебхука (WEBHOOK_LISTEN)")
+	flags.StringVar(&config.WebhookPath, "webhook-path", envOr("WEBHOOK_PATH", "/webhook"), "путь вебхука (WEBHOOK_PATH)")
+	flags.StringVar(&config.WebhookURL, "webhook-url", os.Getenv("WEBHOOK_URL"), "публичный адрес для setWebhook (WEBHOOK_URL)")
+	flags.StringVar(&config.TLSCertFile, "tls-cert", os.Getenv("TLS_CERT_FILE"), "файл сертификата (TLS_CERT_FILE)")
+	flags.StringVar(&config.TLSKeyFile, "tls-key", os.Getenv("TLS_KEY_FILE"), "файл ключа (TLS_KEY_FILE)")
+	flags.StringVar(&config.OffsetFile, "offset-file", envOr("BOT_OFFSET_FILE", "bot.offset"), "файл для сохранения offset getUpdates, пусто - не сохранять (BOT_OFFSET_FILE)")
+	workers := envOr("BOT_WORKERS", strconv.Itoa(defaultWorkers))
+	flags.StringVar(&workers, "workers", workers, "число одновременно обрабатываемых обновлений (BOT_WORKERS)")
+	flags.StringVar(&config.TestDir, "tests", envOr("BOT_TESTS_DIR", "./cmd/bot/tests"), "директория с тестовыми файлами (BOT_TESTS_DIR)")
+	err := flags.Parse(args)
+	if err != nil {
 		return nil, err
 	}
 
-	return updates, nil
-}
-
-// SendMarkdownMessage отправляет сообщение в формате Markdown V2
-func SendMarkdownMessage(botToken string, chatID int, text string) error {
-	// Формируем URL для API запроса
-	url := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", botToken)
-
-	// Создаем данные для запроса
-	reqData := SendMessageRequest{
-		ChatID:    chatID,
-		Text:      text,
-		ParseMode: "MarkdownV2",
+	if config.BotToken == "" {
+		return nil, errors.New("необходимо установить переменную окружения TELEGRAM_BOT_TOKEN")
 	}
-
-	// Преобразуем данные в JSON
-	reqBody, err := json.Marshal(reqData)
-	if err != nil {
-		return err
+	config.Workers, err = strconv.Atoi(workers)
+	if err != nil || config.Workers < 1 {
+		return nil, fmt.Errorf("неверное число обработчиков %q", workers)
 	}
-
-	// Отправляем POST запрос
-	resp, err := http.Post(url, "application/json", strings.NewReader(string(reqBody)))
-	if err != nil {
-		return err
+	// Список чатов через запятую
+	for _, field := range strings.Split(os.Getenv("BOT_ALLOWED_CHATS"), ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("неверный чат %q в BOT_ALLOWED_CHATS", field)
+		}
+		config.AllowedChats = append(config.AllowedChats, id)
 	}
-	defer resp.Body.Close()
-
-	// Проверяем статус ответа
-	if resp.StatusCode != http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		return fmt.Errorf("API вернул статус %d: %s", resp.StatusCode, string(body))
+	switch config.Mode {
+	case modePolling:
+	case modeWebhook:
+		if !telegram.IsValidSecretToken(config.WebhookSecret) {
+			return nil, errors.New("для вебхука необходимо установить WEBHOOK_SECRET: 1-256 символов A-Z, a-z, 0-9, _ и -")
+		}
+		if (config.TLSCertFile == "") != (config.TLSKeyFile == "") {
+			return nil, errors.New("сертификат и ключ TLS задаются вместе")
+		}
+		if !strings.HasPrefix(config.WebhookPath, "/") {
+			config.WebhookPath = "/" + config.WebhookPath
+		}
+	default:
+		return nil, fmt.Errorf("неизвестный режим %q, ожидается %s или %s", config.Mode, modePolling, modeWebhook)
 	}
-
-	return nil
+	return config, nil
 }
 
 // Render выполняет рендеринг AST документа в формат Markdown V2
@@ -128,16 +143,18 @@ func Render(doc ast.Node, renderer *md2.Renderer) []byte {
 	return buf.Bytes()
 }
 
-// Перерабатывает Markdown в формат Markdown V2 для Telegram
-func processMarkdownFile(filePath string, printAst bool) (string, error) {
+// Перерабатывает Markdown из файла name в fsys в формат Markdown V2 для
+// Telegram. Возвращает также исходный текст файла для запасных вариантов
+// отправки
+func processMarkdownFile(fsys fs.FS, name string, printAst bool) (source string, output string, err error) {
 	// Читаем файл
-	input, err := os.ReadFile(filePath)
+	input, err := fs.ReadFile(fsys, name)
 	if err != nil {
-		return "", fmt.Errorf("ошибка чтения файла %s: %v", filePath, err)
+		return "", "", fmt.Errorf("ошибка чтения файла %s: %w", name, err)
 	}
 
 	// Создаем парсер Markdown с расширениями
-	extensions := parser.CommonExtensions | parser.AutoHeadingIDs | parser.NoEmptyLineBeforeBlock
+	extensions := parser.CommonExtensions | parser.AutoHeadingIDs | parser.NoEmptyLineBeforeBlock | parser.OrderedListStart | parser.TaskLists
 	p := parser.NewWithExtensions(extensions)
 
 	// Парсим документ
@@ -145,100 +162,224 @@ func processMarkdownFile(filePath string, printAst bool) (string, error) {
 
 	// Если нужно, выводим AST дерево
 	if printAst {
-		fmt.Printf("--- AST tree для файла %s:\n", filePath)
+		fmt.Printf("--- AST tree для файла %s:\n", name)
 		ast.Print(os.Stdout, doc)
 		fmt.Print("\n")
 	}
 
 	// Создаем рендерер для Markdown V2
-	renderer := md2.NewRenderer()
+	renderer := md2.NewRenderer(md2.RendererOptions{})
 
 	// Рендерим документ
-	output := Render(doc, renderer)
+	rendered := Render(doc, renderer)
+
+	// Возвращаем результат как строку
+	return string(input), string(rendered), nil
+}
 
-	err = os.WriteFile("output.txt", output, os.ModePerm)
+// Этапы отправки сообщения, от полного форматирования к простому тексту
+const (
+	stageMarkdownV2 = "MarkdownV2"
+	stageEntities   = "текст с entities"
+	stagePlain      = "простой текст"
+)
+
+// Отправляет Markdown source, уже переведенный в MarkdownV2. Если Telegram
+// не может разобрать разметку, тот же документ отправляется как текст с
+// entities, а если и это не удалось, как простой текст. Без source (для
+// частей длинного документа) простым текстом отправляется markdownV2 без
+// разметки. opts - параметры, с которыми source переведен в MarkdownV2,
+// с ними же он переводится в entities. Возвращает этап, на котором
+// сообщение ушло, или ошибку последней попытки
+func sendMarkdown(ctx context.Context, client telegram.Client, chatID int64, opts contract.Options, source, markdownV2 string) (string, error) {
+	err := client.SendMarkdownMessage(ctx, chatID, markdownV2)
+	if !telegram.IsParseError(err) {
+		return stageMarkdownV2, err
+	}
+	// смещение нужно, чтобы найти ошибку в рендерере MarkdownV2
+	offset, near := "неизвестно", ""
+	if n, ok := telegram.ParseErrorOffset(err); ok {
+		offset, near = strconv.Itoa(n), around(markdownV2, n)
+	}
+	log.Printf("Telegram не разобрал MarkdownV2 для чата %d (смещение %s, текст рядом %q): %v", chatID, offset, near, err)
+
+	if source == "" {
+		_, err = client.SendMessage(ctx, telegram.SendMessageParams{ChatID: chatID, Text: stripMarkdownV2(markdownV2)})
+		if err == nil {
+			log.Printf("Сообщение в чат %d отправлено на этапе «%s», ошибка MarkdownV2 по смещению %s", chatID, stagePlain, offset)
+		}
+		return stagePlain, err
+	}
+
+	text, ents, err := contract.ConvertMDToEntitiesWithOptions(source, opts)
+	if err == nil && strings.TrimSpace(text) != "" {
+		_, err = client.SendMessage(ctx, telegram.SendMessageParams{ChatID: chatID, Text: text, Entities: ents})
+		if err == nil {
+			log.Printf("Сообщение в чат %d отправлено на этапе «%s», ошибка MarkdownV2 по смещению %s", chatID, stageEntities, offset)
+			return stageEntities, nil
+		}
+		if !telegram.IsBadRequest(err) {
+			return stageEntities, err
+		}
+	}
 	if err != nil {
-		log.Printf("output err: %s", err)
+		log.Printf("Не удалось отправить текст с entities в чат %d: %v", chatID, err)
 	}
 
-	// Возвращаем результат как строку
-	return string(output), nil
+	_, err = client.SendMessage(ctx, telegram.SendMessageParams{ChatID: chatID, Text: source})
+	if err == nil {
+		log.Printf("Сообщение в чат %d отправлено на этапе «%s», ошибка MarkdownV2 по смещению %s", chatID, stagePlain, offset)
+	}
+	return stagePlain, err
 }
 
-// Получает список тестовых файлов
-func getTestFiles(testDirPath string) ([]string, error) {
-	// Проверяем существование директории
-	_, err := os.Stat(testDirPath)
-	if os.IsNotExist(err) {
-		return nil, fmt.Errorf("директория %s не существует", testDirPath)
+// Убирает из text разметку MarkdownV2: экранирование, маркеры
+// форматирования и цитат. Ссылки записываются как "текст (адрес)"
+func stripMarkdownV2(text string) string {
+	var b strings.Builder
+	inCode, inPre := false, false
+	lineStart := true
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		atLineStart := lineStart
+		lineStart = c == '\n'
+		switch {
+		case c == '\\' && i+1 < len(text):
+			i++
+			b.WriteByte(text[i])
+		case strings.HasPrefix(text[i:], "```"):
+			i += 2
+			inPre = !inPre
+			if inPre {
+				// пропускаем язык до конца строки
+				if j := strings.IndexByte(text[i:], '\n'); j >= 0 {
+					i += j
+				} else {
+					i = len(text)
+				}
+			}
+		case inPre, inCode && c != '`':
+			b.WriteByte(c)
+		case c == '`':
+			inCode = !inCode
+		case atLineStart && strings.HasPrefix(text[i:], "**>"):
+			i += 2
+		case atLineStart && c == '>', c == '*', c == '_', c == '~', c == '|', c == '[':
+		case c == ']' && strings.HasPrefix(text[i+1:], "("):
+			var url strings.Builder
+			for i += 2; i < len(text) && text[i] != ')'; i++ {
+				if text[i] == '\\' && i+1 < len(text) {
+					i++
+				}
+				url.WriteByte(text[i])
+			}
+			b.WriteString(" (" + url.String() + ")")
+		default:
+			b.WriteByte(c)
+		}
 	}
+	return b.String()
+}
 
+// Возвращает часть text вокруг байтового смещения offset
+func around(text string, offset int) string {
+	const radius = 20
+	start, end := max(offset-radius, 0), min(offset+radius, len(text))
+	if start > end {
+		return ""
+	}
+	return strings.ToValidUTF8(text[start:end], "")
+}
+
+// Расширения файлов с Markdown, которые бот читает и конвертирует
+var markdownExtensions = map[string]bool{
+	".md":       true,
+	".markdown": true,
+	".txt":      true,
+}
+
+// Ошибка в имени файла, запрошенного пользователем
+var errBadFileName = errors.New("недопустимое имя файла")
+
+// Проверяет имя файла из команды: только относительный путь внутри
+// директории без ".." и с расширением из markdownExtensions. Возвращает
+// имя для fs.FS
+func testFileName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	// fs.ValidPath не пропускает абсолютные пути, "..", "." и пустые
+	// элементы, обратную косую черту Windows проверяем отдельно
+	if !fs.ValidPath(name) || name == "." || strings.ContainsAny(name, "\\\x00") {
+		return "", fmt.Errorf("%w %q", errBadFileName, name)
+	}
+	if !markdownExtensions[strings.ToLower(path.Ext(name))] {
+		return "", fmt.Errorf("%w %q: можно запросить только файлы .md и .txt", errBadFileName, name)
+	}
+	return name, nil
+}
+
+// Получает список тестовых файлов в корне fsys
+func getTestFiles(fsys fs.FS) ([]string, error) {
 	// Получаем список файлов
-	files, err := os.ReadDir(testDirPath)
+	files, err := fs.ReadDir(fsys, ".")
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, errors.New("директория с тестовыми файлами не существует")
+	}
 	if err != nil {
-		return nil, fmt.Errorf("ошибка чтения директории %s: %v", testDirPath, err)
+		return nil, fmt.Errorf("ошибка чтения директории с тестовыми файлами: %v", err)
 	}
 
-	// Собираем пути к файлам
-	var filePaths []string
+	// Собираем имена файлов
+	var names []string
 	for _, file := range files {
-		if !file.IsDir() && (strings.HasSuffix(file.Name(), ".md") || strings.HasSuffix(file.Name(), ".txt")) {
-			filePaths = append(filePaths, filepath.Join(testDirPath, file.Name()))
+		if file.Type().IsRegular() && markdownExtensions[strings.ToLower(path.Ext(file.Name()))] {
+			names = append(names, file.Name())
 		}
 	}
 
-	return filePaths, nil
+	return names, nil
 }
 
 // Отправляет все тестовые файлы пользователю
-func sendAllTestFiles(botToken string, chatID int, testDirPath string, printAst bool) {
+func sendAllTestFiles(ctx context.Context, client telegram.Client, chatID int64, tests fs.FS, printAst bool) {
 	// Получаем список файлов
-	filePaths, err := getTestFiles(testDirPath)
+	fileNames, err := getTestFiles(tests)
 	if err != nil {
 		log.Printf("Ошибка при получении списка файлов: %v", err)
-		SendMarkdownMessage(botToken, chatID, escapeMarkdownV2(fmt.Sprintf("Ошибка: %v", err)))
+		client.SendMarkdownMessage(ctx, chatID, escapeMarkdownV2(fmt.Sprintf("Ошибка: %v", err)))
 		return
 	}
 
 	// Если файлов нет, отправляем сообщение об этом
-	if len(filePaths) == 0 {
-		SendMarkdownMessage(botToken, chatID, escapeMarkdownV2("Тестовые файлы не найдены"))
+	if len(fileNames) == 0 {
+		client.SendMarkdownMessage(ctx, chatID, escapeMarkdownV2("Тестовые файлы не найдены"))
 		return
 	}
 
 	// Отправляем каждый файл
-	for _, filePath := range filePaths {
-		filename := filepath.Base(filePath)
-
+	for _, filename := range fileNames {
 		// Обрабатываем файл Markdown
-		outputMarkdown, err := processMarkdownFile(filePath, printAst)
+		source, outputMarkdown, err := processMarkdownFile(tests, filename, printAst)
 		if err != nil {
 			log.Printf("Ошибка при обработке файла %s: %v", filename, err)
-			SendMarkdownMessage(botToken, chatID, escapeMarkdownV2(fmt.Sprintf("Ошибка при обработке файла %s: %v", filename, err)))
+			client.SendMarkdownMessage(ctx, chatID, escapeMarkdownV2(fmt.Sprintf("Ошибка при обработке файла %s: %v", filename, err)))
 			continue
 		}
 
 		// Отправляем сообщение с заголовком файла
-		SendMarkdownMessage(botToken, chatID, escapeMarkdownV2(fmt.Sprintf("📁 Файл: %s", filename)))
-
-		// Ждем немного, чтобы сообщения приходили в правильном порядке
-		time.Sleep(500 * time.Millisecond)
+		client.SendMarkdownMessage(ctx, chatID, escapeMarkdownV2(fmt.Sprintf("📁 Файл: %s", filename)))
 
 		// Отправляем содержимое файла
-		err = SendMarkdownMessage(botToken, chatID, outputMarkdown)
+		_, err = sendMarkdown(ctx, client, chatID, contract.Options{}, source, outputMarkdown)
 		if err != nil {
 			log.Printf("Ошибка при отправке файла %s: %v", filename, err)
-			SendMarkdownMessage(botToken, chatID, escapeMarkdownV2(fmt.Sprintf("Не удалось отправить содержимое файла %s: %v", filename, err)))
+			client.SendMarkdownMessage(ctx, chatID, escapeMarkdownV2(fmt.Sprintf("Не удалось отправить содержимое файла %s: %v", filename, err)))
 		} else {
 			log.Printf("Файл %s успешно отправлен в чат %d", filename, chatID)
 		}
-
-		// Пауза между отправкой файлов, чтобы не превысить лимиты API
-		time.Sleep(1 * time.Second)
 	}
 
 	// Отправляем сообщение о завершении
-	SendMarkdownMessage(botToken, chatID, escapeMarkdownV2("✅ Все файлы отправлены"))
+	client.SendMarkdownMessage(ctx, chatID, escapeMarkdownV2("✅ Все файлы отправлены"))
 }
 
 // Список символов, которые нужно экранировать в Markdown V2
@@ -259,108 +400,213 @@ func escapeMarkdownV2(text string) string {
 	return result
 }
 
-func main() {
-	// Получаем токен бота из переменных окружения
-	botToken := os.Getenv("TELEGRAM_BOT_TOKEN")
-	if botToken == "" {
-		log.Fatal("Необходимо установить переменную окружения TELEGRAM_BOT_TOKEN")
+// Создает маршрутизатор с командами бота. Сообщения без команды
+// конвертируются из Markdown в MarkdownV2
+func newBotRouter(config *Config, tests fs.FS) *router {
+	r := newRouter()
+	r.botName = config.BotName
+	r.use(logRequests, allowChats(config.AllowedChats))
+	r.text = convertMessage
+	r.document = convertDocument
+	r.inline = answerInline
+
+	sendAll := func(ctx context.Context, req *request) error {
+		// Отправляем приветственное сообщение
+		req.reply(ctx, "Привет! Отправляю тестовые Markdown файлы...")
+
+		// Отправляем все тестовые файлы
+		sendAllTestFiles(ctx, req.client, req.chatID, tests, true)
+		return nil
 	}
+	r.handle("/start", "отправить все тестовые файлы", sendAll)
+	r.handle("/files", "отправить все тестовые файлы", sendAll)
+	r.handle("/file <имя_файла...>", "отправить конкретный файл", func(ctx context.Context, req *request) error {
+		return sendTestFile(ctx, req, tests, req.rawArgs)
+	})
+	return r
+}
 
-	// Путь к директории с тестовыми файлами
-	testDirPath := "./cmd/bot/tests"
+// Отправляет тестовый файл fileName. Файлы читаются только из tests
+func sendTestFile(ctx context.Context, req *request, tests fs.FS, fileName string) error {
+	name, err := testFileName(fileName)
+	if err != nil {
+		log.Printf("Чат %d запросил файл %q: %v", req.chatID, fileName, err)
+		return req.reply(ctx, fmt.Sprintf("Недопустимое имя файла %s. Укажите имя файла .md или .txt из списка /files", fileName))
+	}
 
-	// Создаем конфигурацию бота
-	config := &Config{
-		BotToken: botToken,
-		Offset:   0,
+	// Обрабатываем и отправляем конкретный файл
+	source, outputMarkdown, err := processMarkdownFile(tests, name, true)
+	if errors.Is(err, fs.ErrNotExist) {
+		return req.reply(ctx, fmt.Sprintf("Файл %s не найден", fileName))
+	}
+	if err != nil {
+		// os.Root отклоняет ссылки, ведущие за пределы директории
+		log.Printf("Ошибка при обработке файла %s: %v", fileName, err)
+		return fmt.Errorf("не удалось прочитать файл %s", fileName)
+	}
+
+	req.reply(ctx, fmt.Sprintf("📁 Файл: %s", fileName))
+
+	_, err = sendMarkdown(ctx, req.client, req.chatID, contract.Options{}, source, outputMarkdown)
+	if err != nil {
+		return fmt.Errorf("не удалось отправить содержимое файла %s: %v", fileName, err)
+	}
+	log.Printf("Файл %s успешно отправлен в чат %d", fileName, req.chatID)
+	return nil
+}
+
+// Конвертирует Markdown из сообщения и отправляет его обратно в формате
+// Markdown V2
+func convertMessage(ctx context.Context, req *request) error {
+	output, err := contract.ConvertMD2WithOptions(req.msg.Text, contract.ChatOptions)
+	if err != nil {
+		return err
 	}
 
-	fmt.Println("Бот запущен. Ожидание сообщений...")
+	// Отправляем обработанное сообщение, при ошибке разметки - без нее
+	_, err = sendMarkdown(ctx, req.client, req.chatID, contract.ChatOptions, req.msg.Text, output)
+	return err
+}
 
-	// Бесконечный цикл для получения и обработки обновлений
-	for {
-		updates, err := GetUpdates(config)
+// Обрабатывает обновление. Один и тот же обработчик используется при
+// опросе getUpdates и в режиме вебхука
+func newDispatcher(client telegram.Client, r *router) telegram.UpdateHandler {
+	return func(ctx context.Context, update telegram.Update) {
+		// Проверяем, есть ли текст сообщения или файл
+		if msg := update.Message; msg != nil && (msg.Text != "" || msg.Document != nil) {
+			r.dispatch(ctx, client, update.Message)
+		}
+		if update.InlineQuery != nil {
+			r.dispatchInline(ctx, client, update.InlineQuery)
+		}
+	}
+}
+
+// Получает обновления через getUpdates и передает их в пул, пока не
+// отменен ctx. Обработанные обновления отмечаются в offsets через
+// pool.done. Возвращает ошибку, если getUpdates отвечает 409 Conflict:
+// у бота есть вебхук или обновления получает другой экземпляр
+func runPolling(ctx context.Context, client *telegram.Sender, offsets *offsetStore, pool *workerPool) error {
+	for ctx.Err() == nil {
+		offset := offsets.nextOffset()
+		updates, err := client.GetUpdates(ctx, offset, 60*time.Second)
 		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			var apiErr *telegram.Error
+			if errors.As(err, &apiErr) && apiErr.Code == http.StatusConflict {
+				return fmt.Errorf("getUpdates недоступен, проверьте вебхук и другие запущенные экземпляры бота: %w", err)
+			}
+			// временные ошибки Sender уже повторил, остальные не исправятся
+			// сразу
 			log.Printf("Ошибка при получении обновлений: %v", err)
-			time.Sleep(5 * time.Second)
+			select {
+			case <-time.After(client.Opts.MaxBackoff):
+			case <-ctx.Done():
+			}
 			continue
 		}
 
-		// Обрабатываем каждое обновление
+		submitted := false
 		for _, update := range updates {
-			// Обновляем offset (чтобы не получать одни и те же сообщения повторно)
-			config.Offset = update.UpdateID + 1
-
-			// Проверяем, есть ли текст сообщения
-			if update.Message.Text != "" {
-				chatID := update.Message.Chat.ID
-				messageText := update.Message.Text
-				log.Printf("Получено сообщение от chat_id %d: %s", chatID, messageText)
-
-				// Если пользователь отправил команду /start или /files
-				if messageText == "/start" || messageText == "/files" {
-					// Отправляем приветственное сообщение
-					SendMarkdownMessage(config.BotToken, chatID, escapeMarkdownV2("Привет! Отправляю тестовые Markdown файлы..."))
-
-					// Отправляем все тестовые файлы
-					sendAllTestFiles(config.BotToken, chatID, testDirPath, true)
-				} else if strings.HasPrefix(messageText, "/file ") {
-					// Если пользователь запросил конкретный файл
-					fileName := strings.TrimPrefix(messageText, "/file ")
-					filePath := filepath.Join(testDirPath, fileName)
-
-					// Проверяем существование файла
-					if _, err := os.Stat(filePath); os.IsNotExist(err) {
-						SendMarkdownMessage(config.BotToken, chatID, escapeMarkdownV2(fmt.Sprintf("Файл %s не найден", fileName)))
-						continue
-					}
-
-					// Обрабатываем и отправляем конкретный файл
-					outputMarkdown, err := processMarkdownFile(filePath, true)
-					if err != nil {
-						log.Printf("Ошибка при обработке файла %s: %v", fileName, err)
-						SendMarkdownMessage(config.BotToken, chatID, escapeMarkdownV2(fmt.Sprintf("Ошибка при обработке файла %s: %v", fileName, err)))
-						continue
-					}
-
-					SendMarkdownMessage(config.BotToken, chatID, escapeMarkdownV2(fmt.Sprintf("📁 Файл: %s", fileName)))
-					time.Sleep(500 * time.Millisecond)
-
-					err = SendMarkdownMessage(config.BotToken, chatID, outputMarkdown)
-					if err != nil {
-						log.Printf("Ошибка при отправке файла %s: %v", fileName, err)
-						SendMarkdownMessage(config.BotToken, chatID, escapeMarkdownV2(fmt.Sprintf("Не удалось отправить содержимое файла %s: %v", fileName, err)))
-					} else {
-						log.Printf("Файл %s успешно отправлен в чат %d", fileName, chatID)
-					}
-				} else if messageText == "/help" {
-					// Отправляем справку
-					helpText := `
-Доступные команды:
-/start или /files - отправить все тестовые файлы
-/file имя_файла - отправить конкретный файл
-/help - показать эту справку
-					`
-					SendMarkdownMessage(config.BotToken, chatID, escapeMarkdownV2(helpText))
-				} else {
-					// Для любого другого сообщения отправляем обрабатываем его как Markdown
-					// и отправляем обратно в формате Markdown V2
-					p := parser.NewWithExtensions(parser.CommonExtensions)
-					doc := p.Parse([]byte(messageText))
-					renderer := md2.NewRenderer()
-					output := Render(doc, renderer)
-
-					// Отправляем обработанное сообщение
-					err := SendMarkdownMessage(config.BotToken, chatID, string(output))
-					if err != nil {
-						log.Printf("Ошибка при отправке обработанного сообщения: %v", err)
-						SendMarkdownMessage(config.BotToken, chatID, escapeMarkdownV2(fmt.Sprintf("Ошибка: %v", err)))
-					}
-				}
+			// обновления, которые еще обрабатываются, приходят снова
+			if offsets.received(update) {
+				pool.submit(update)
+				submitted = true
+			}
+		}
+		if len(updates) > 0 && !submitted {
+			// пока offset не сдвинется, getUpdates сразу вернет те же
+			// обновления
+			offsets.waitProcessed(ctx, offset)
+		}
+	}
+	return nil
+}
+
+// Время, за которое после сигнала остановки должна закончиться начатая
+// обработка обновлений
+const shutdownTimeout = 30 * time.Second
+
+func main() {
+	config, err := loadConfig(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Тестовые файлы читаются только из этой директории, os.Root не дает
+	// выйти за ее пределы, в том числе по символическим ссылкам
+	testsRoot, err := os.OpenRoot(config.TestDir)
+	if err != nil {
+		log.Fatalf("Не удалось открыть директорию с тестовыми файлами: %v", err)
+	}
+	defer testsRoot.Close()
+
+	// ctx отменяется по SIGINT или SIGTERM: бот перестает получать
+	// обновления, а начатая обработка продолжается в handlerCtx
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	handlerCtx, cancelHandlers := context.WithCancel(context.Background())
+	defer cancelHandlers()
+
+	// Клиент Bot API, адрес можно заменить на локальный сервер. Sender
+	// соблюдает лимиты API, повторяет запросы при ошибках и сохраняет
+	// порядок сообщений в чате
+	bot := telegram.NewBot(config.BotToken, telegram.BotOptions{BaseURL: config.APIURL})
+	client := telegram.NewSender(bot, telegram.SenderOptions{})
+	pool := newWorkerPool(handlerCtx, config.Workers, newDispatcher(client, newBotRouter(config, testsRoot.FS())))
+
+	var pollErr error
+	if config.Mode == modeWebhook {
+		if config.WebhookURL != "" {
+			err := bot.SetWebhook(ctx, telegram.SetWebhookParams{
+				URL:         config.WebhookURL,
+				SecretToken: config.WebhookSecret,
+			})
+			if err != nil {
+				log.Fatalf("Не удалось зарегистрировать вебхук: %v", err)
+			}
+		}
+		fmt.Printf("Бот запущен в режиме вебхука на %s%s\n", config.ListenAddr, config.WebhookPath)
+		// вебхук отвечает Telegram сразу, обработка идет в пуле
+		submit := func(_ context.Context, update telegram.Update) { pool.submit(update) }
+		if err := runWebhook(ctx, config, submit); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		offsets, err := loadOffset(config.OffsetFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		pool.done = func(update telegram.Update) {
+			if err := offsets.done(update); err != nil {
+				log.Print(err)
 			}
 		}
+		// пока у бота есть вебхук, getUpdates отвечает 409 Conflict
+		if err := bot.DeleteWebhook(ctx, telegram.DeleteWebhookParams{}); err != nil {
+			log.Fatalf("Не удалось удалить вебхук: %v", err)
+		}
+		fmt.Println("Бот запущен. Ожидание сообщений...")
+		pollErr = runPolling(ctx, client, offsets, pool)
+		if pollErr != nil {
+			log.Printf("Получение обновлений остановлено: %v", pollErr)
+		}
+	}
 
-		// Небольшая пауза между запросами (чтобы не перегружать API)
-		time.Sleep(1 * time.Second)
+	log.Print("Остановка: ожидание обработки полученных обновлений")
+	waitCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := pool.wait(waitCtx); err != nil {
+		// прерываем отправку, необработанные обновления после перезапуска
+		// придут снова
+		log.Printf("Обработка не закончилась за %v, прерываем", shutdownTimeout)
+		cancelHandlers()
+		pool.wait(context.Background())
+	}
+	log.Print("Бот остановлен")
+	if pollErr != nil {
+		os.Exit(1)
 	}
 }
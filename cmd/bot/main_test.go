@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eternalsad/markdownify/contract"
+	"github.com/eternalsad/markdownify/telegram"
+	"github.com/eternalsad/markdownify/telegram/telegramtest"
+)
+
+// Тестовый сервер Bot API и тестовые файлы бота
+type testServer struct {
+	*telegramtest.Server
+	files fs.FS
+}
+
+// Запускает тестовый сервер Bot API
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	root, err := os.OpenRoot(testsDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { root.Close() })
+	srv := telegramtest.NewServer()
+	t.Cleanup(srv.Close)
+	return &testServer{Server: srv, files: root.FS()}
+}
+
+// Абсолютный путь к тестовым файлам, тесты меняют текущую директорию
+var testsDir, _ = filepath.Abs("tests")
+
+// Лимиты Sender, при которых тесты не ждут
+var testSenderOptions = telegram.SenderOptions{
+	ChatInterval:   time.Microsecond,
+	GlobalInterval: time.Microsecond,
+	MinBackoff:     time.Millisecond,
+}
+
+func sendText(srv *testServer, text string) {
+	msg := &telegram.Message{Chat: telegram.Chat{ID: 42}, Text: text}
+	client := telegram.NewSender(srv.Bot(), testSenderOptions)
+	newBotRouter(&Config{}, srv.files).dispatch(context.Background(), client, msg)
+}
+
+func TestFileCommand(t *testing.T) {
+	srv := newTestServer(t)
+	sendText(srv, "/file sample1.md")
+	msgs := srv.Messages()
+	if len(msgs) != 2 {
+		t.Fatalf("expected 2 messages, got %+v", msgs)
+	}
+	if msgs[0].PlainText != "📁 Файл: sample1.md" {
+		t.Errorf("header = %q", msgs[0].PlainText)
+	}
+	if msgs[1].ParseMode != telegram.ModeMarkdownV2 || msgs[1].ChatID != 42 {
+		t.Errorf("content = %+v", msgs[1])
+	}
+}
+
+func TestFileCommandThrottled(t *testing.T) {
+	srv := newTestServer(t)
+	srv.Throttle(1, 1)
+	sendText(srv, "/file sample1.md")
+	msgs := srv.Messages()
+	if len(msgs) != 2 || msgs[0].PlainText != "📁 Файл: sample1.md" {
+		t.Errorf("messages = %+v", msgs)
+	}
+}
+
+func TestFileNotFound(t *testing.T) {
+	srv := newTestServer(t)
+	sendText(srv, "/file missing.md")
+	msgs := srv.Messages()
+	if len(msgs) != 1 || msgs[0].PlainText != "Файл missing.md не найден" {
+		t.Errorf("messages = %+v", msgs)
+	}
+}
+
+func TestFilesCommand(t *testing.T) {
+	srv := newTestServer(t)
+	sendText(srv, "/files")
+	msgs := srv.Messages()
+	files, err := getTestFiles(srv.files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers := 0
+	for _, msg := range msgs {
+		if strings.HasPrefix(msg.PlainText, "📁 Файл: ") {
+			headers++
+		}
+		if strings.HasPrefix(msg.PlainText, "Не удалось отправить") {
+			t.Errorf("%s", msg.PlainText)
+		}
+	}
+	if headers != len(files) {
+		t.Errorf("expected %d files, got %d headers", len(files), headers)
+	}
+	if last := msgs[len(msgs)-1].PlainText; last != "✅ Все файлы отправлены" {
+		t.Errorf("last message = %q", last)
+	}
+}
+
+func TestConvertMessage(t *testing.T) {
+	srv := newTestServer(t)
+	sendText(srv, "**bold** and `code`.")
+	msgs := srv.Messages()
+	if len(msgs) != 1 || msgs[0].PlainText != "bold and code." {
+		t.Errorf("messages = %+v", msgs)
+	}
+}
+
+func TestSendMarkdownFallback(t *testing.T) {
+	srv := newTestServer(t)
+	client := telegram.NewSender(srv.Bot(), testSenderOptions)
+	ctx := context.Background()
+
+	// рендерер мог бы выдать неверный MarkdownV2, отправляется текст с entities
+	stage, err := sendMarkdown(ctx, client, 42, contract.ChatOptions, "**bold** text.", "*bold text.")
+	if err != nil || stage != stageEntities {
+		t.Fatalf("stage = %q, err = %v", stage, err)
+	}
+	msgs := srv.Messages()
+	if len(msgs) != 1 || msgs[0].ParseMode != "" || msgs[0].PlainText != "bold text." {
+		t.Fatalf("messages = %+v", msgs)
+	}
+	if len(msgs[0].Entities) != 1 || msgs[0].Entities[0].Type != "bold" || msgs[0].Entities[0].Length != 4 {
+		t.Errorf("entities = %+v", msgs[0].Entities)
+	}
+
+	// у документа без текста нет entities, отправляется исходный текст
+	stage, err = sendMarkdown(ctx, client, 42, contract.ChatOptions, "<div></div>", "<div>")
+	if err != nil || stage != stagePlain {
+		t.Fatalf("stage = %q, err = %v", stage, err)
+	}
+	if msgs := srv.Messages(); len(msgs) != 2 || msgs[1].Text != "<div></div>" {
+		t.Errorf("messages = %+v", msgs)
+	}
+
+	// без исходного Markdown отправляется MarkdownV2 без разметки
+	stage, err = sendMarkdown(ctx, client, 42, contract.ChatOptions, "", "*broken\\. text")
+	if err != nil || stage != stagePlain {
+		t.Fatalf("stage = %q, err = %v", stage, err)
+	}
+	if msgs := srv.Messages(); len(msgs) != 3 || msgs[2].Text != "broken. text" {
+		t.Errorf("messages = %+v", msgs)
+	}
+
+	// ошибки, не связанные с разметкой, возвращаются как есть
+	stage, err = sendMarkdown(ctx, client, 42, contract.ChatOptions, "", "")
+	if !telegram.IsBadRequest(err) || stage != stageMarkdownV2 {
+		t.Errorf("stage = %q, err = %v", stage, err)
+	}
+
+	// entities получаются с параметрами вызывающего, здесь текст больше
+	// их MaxInputSize
+	stage, err = sendMarkdown(ctx, client, 42, contract.Options{MaxInputSize: 4}, "**bold** text.", "*bold text.")
+	if err != nil || stage != stagePlain {
+		t.Fatalf("stage = %q, err = %v", stage, err)
+	}
+	if msgs := srv.Messages(); len(msgs) != 4 || msgs[3].Text != "**bold** text." {
+		t.Errorf("messages = %+v", msgs)
+	}
+}
+
+func TestAround(t *testing.T) {
+	text := strings.Repeat("a", 30) + "*" + strings.Repeat("b", 30)
+	if got := around(text, 30); got != strings.Repeat("a", 20)+"*"+strings.Repeat("b", 19) {
+		t.Errorf("around = %q", got)
+	}
+	if got := around("ab", 10); got != "ab" {
+		t.Errorf("around = %q", got)
+	}
+}
+
+func TestTestFileName(t *testing.T) {
+	for name, valid := range map[string]bool{
+		"sample1.md":          true,
+		"dir/notes.TXT":       true,
+		"../main.go":          false,
+		"../../../etc/passwd": false,
+		"/etc/passwd":         false,
+		"dir/../sample1.md":   false,
+		"..":                  false,
+		".":                   false,
+		"":                    false,
+		"..\\main.md":         false,
+		"sample1.md\x00.txt":  false,
+		"main.go":             false,
+		"secret":              false,
+	} {
+		_, err := testFileName(name)
+		if valid != (err == nil) {
+			t.Errorf("%q: err = %v", name, err)
+		}
+		if err != nil && !errors.Is(err, errBadFileName) {
+			t.Errorf("%q: err = %v, expected errBadFileName", name, err)
+		}
+	}
+}
+
+func TestFileTraversal(t *testing.T) {
+	srv := newTestServer(t)
+	// директория с тестовыми файлами и секрет рядом с ней
+	base := t.TempDir()
+	dir := filepath.Join(base, "tests")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(base, "secret.md"), []byte("SECRET"), 0o644)
+	os.WriteFile(filepath.Join(dir, "ok.md"), []byte("allowed"), 0o644)
+	if err := os.Symlink(filepath.Join(base, "secret.md"), filepath.Join(dir, "link.md")); err != nil {
+		t.Skip("symlinks are not supported:", err)
+	}
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer root.Close()
+
+	r := newBotRouter(&Config{}, root.FS())
+	client := telegram.NewSender(srv.Bot(), testSenderOptions)
+	for _, name := range []string{"../secret.md", filepath.Join(base, "secret.md"), "link.md", "./../secret.md", "ok.md/../../secret.md"} {
+		r.dispatch(context.Background(), client, &telegram.Message{Chat: telegram.Chat{ID: 42}, Text: "/file " + name})
+	}
+	r.dispatch(context.Background(), client, &telegram.Message{Chat: telegram.Chat{ID: 42}, Text: "/file ok.md"})
+
+	msgs := srv.Messages()
+	for _, msg := range msgs {
+		if strings.Contains(msg.PlainText, "SECRET") {
+			t.Errorf("secret leaked: %q", msg.PlainText)
+		}
+	}
+	if last := msgs[len(msgs)-1].PlainText; last != "allowed" {
+		t.Errorf("last message = %q", last)
+	}
+	// /files не отправляет ссылку за пределы директории
+	if names, err := getTestFiles(root.FS()); err != nil || len(names) != 1 || names[0] != "ok.md" {
+		t.Errorf("files = %q, %v", names, err)
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/eternalsad/markdownify/telegram"
+)
+
+// Offset для getUpdates. Запрос с offset подтверждает Telegram все
+// обновления до него, поэтому getUpdates вызывается с offset первого еще
+// не обработанного обновления, и он же сохраняется на диск: после
+// перезапуска необработанные обновления придут снова. Обновления
+// обрабатываются параллельно, и обработанные после первого
+// необработанного тоже придут снова
+type offsetStore struct {
+	// Файл с offset, без него offset хранится только в памяти
+	path string
+
+	mu sync.Mutex
+	// Offset после последнего полученного обновления. getUpdates
+	// возвращает обрабатываемые обновления снова, в пул они не передаются
+	next int
+	// Полученные, но еще не обработанные обновления
+	pending map[int]bool
+	// Последний сохраненный offset
+	saved int
+	// Закрывается, когда обработано очередное обновление
+	processed chan struct{}
+}
+
+// Читает offset из файла path. Если файла нет, offset начинается с 0
+func loadOffset(path string) (*offsetStore, error) {
+	s := &offsetStore{path: path, pending: map[int]bool{}, processed: make(chan struct{})}
+	if path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения offset: %w", err)
+	}
+	offset, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("неверный offset в файле %s: %w", path, err)
+	}
+	s.next, s.saved = offset, offset
+	return s, nil
+}
+
+// Offset следующего запроса getUpdates
+func (s *offsetStore) nextOffset() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.committed()
+}
+
+// Отмечает обновление полученным. Возвращает false, если оно уже было
+// получено и обрабатывается или обработано
+func (s *offsetStore) received(update telegram.Update) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if update.UpdateID < s.next {
+		return false
+	}
+	s.pending[update.UpdateID] = true
+	s.next = update.UpdateID + 1
+	return true
+}
+
+// Ждет, пока не будет обработано еще одно обновление, если offset
+// следующего запроса все еще offset, или пока не отменен ctx
+func (s *offsetStore) waitProcessed(ctx context.Context, offset int) {
+	s.mu.Lock()
+	processed := s.processed
+	moved := s.committed() != offset
+	s.mu.Unlock()
+	if moved {
+		return
+	}
+	select {
+	case <-processed:
+	case <-ctx.Done():
+	}
+}
+
+// Отмечает обновление обработанным и сохраняет offset, если он сдвинулся
+func (s *offsetStore) done(update telegram.Update) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.pending, update.UpdateID)
+	close(s.processed)
+	s.processed = make(chan struct{})
+	offset := s.committed()
+	if offset == s.saved || s.path == "" {
+		return nil
+	}
+	if err := writeFileAtomic(s.path, []byte(strconv.Itoa(offset)+"\n")); err != nil {
+		return fmt.Errorf("ошибка сохранения offset: %w", err)
+	}
+	s.saved = offset
+	return nil
+}
+
+// Offset, до которого все обновления обработаны
+func (s *offsetStore) committed() int {
+	offset := s.next
+	for id := range s.pending {
+		offset = min(offset, id)
+	}
+	return offset
+}
+
+// Записывает файл через временный файл и переименование, чтобы при сбое
+// на диске не остался обрезанный файл
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
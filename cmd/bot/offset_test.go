@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/eternalsad/markdownify/telegram"
+)
+
+func TestOffsetStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "offset")
+	s, err := loadOffset(path)
+	if err != nil || s.nextOffset() != 0 {
+		t.Fatalf("offset = %d, err = %v", s.nextOffset(), err)
+	}
+	saved := func() string {
+		data, _ := os.ReadFile(path)
+		return string(data)
+	}
+
+	for id := 10; id <= 12; id++ {
+		if !s.received(telegram.Update{UpdateID: id}) {
+			t.Errorf("update %d is not new", id)
+		}
+	}
+	// getUpdates вернет обрабатываемые обновления снова
+	if s.received(telegram.Update{UpdateID: 11}) {
+		t.Error("update 11 is received twice")
+	}
+	if s.nextOffset() != 10 {
+		t.Errorf("next offset = %d", s.nextOffset())
+	}
+	// 10 еще обрабатывается, сохраняется только offset 10
+	s.done(telegram.Update{UpdateID: 11})
+	if got := saved(); got != "10\n" {
+		t.Errorf("saved = %q", got)
+	}
+	s.done(telegram.Update{UpdateID: 10})
+	if got := saved(); got != "12\n" || s.nextOffset() != 12 {
+		t.Errorf("saved = %q, next offset = %d", got, s.nextOffset())
+	}
+	s.done(telegram.Update{UpdateID: 12})
+	if got := saved(); got != "13\n" {
+		t.Errorf("saved = %q", got)
+	}
+
+	s, err = loadOffset(path)
+	if err != nil || s.nextOffset() != 13 {
+		t.Errorf("loaded offset = %d, err = %v", s.nextOffset(), err)
+	}
+	os.WriteFile(path, []byte("bad"), 0o644)
+	if _, err := loadOffset(path); err == nil {
+		t.Error("expected an error for a bad offset file")
+	}
+}
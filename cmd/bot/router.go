@@ -0,0 +1,327 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/eternalsad/markdownify/telegram"
+)
+
+// Запрос к обработчику: сообщение пользователя и разобранная команда
+type request struct {
+	client telegram.Client
+	// Сообщение или inline-запрос, одно из двух
+	msg   *telegram.Message
+	query *telegram.InlineQuery
+	// Чат сообщения, для inline-запроса - пользователь
+	chatID int64
+	// Имя команды без "/" и имени бота, пустое для обычного текста
+	command string
+	// Аргументы команды, разделенные пробелами, с учетом кавычек
+	args []string
+	// Текст после команды как есть
+	rawArgs string
+}
+
+// Отправляет в чат запроса текст без разметки
+func (req *request) reply(ctx context.Context, text string) error {
+	return req.client.SendMarkdownMessage(ctx, req.chatID, escapeMarkdownV2(text))
+}
+
+// Обработчик команды. Возвращенная ошибка отправляется пользователю
+type handlerFunc func(ctx context.Context, req *request) error
+
+// Промежуточный обработчик, оборачивает следующий в цепочке
+type middleware func(next handlerFunc) handlerFunc
+
+// Зарегистрированная команда
+type command struct {
+	name string
+	// Аргументы из шаблона, например "<имя_файла>"
+	usage       string
+	description string
+	handler     handlerFunc
+	// Число аргументов, maxArgs < 0 - без ограничения
+	minArgs, maxArgs int
+}
+
+// Ошибка в аргументах команды, пользователю отправляется подсказка
+type usageError struct {
+	cmd *command
+}
+
+func (e *usageError) Error() string {
+	return "использование: " + e.cmd.String()
+}
+
+// Команда с аргументами, как ее нужно вводить
+func (c *command) String() string {
+	return strings.TrimSpace("/" + c.name + " " + c.usage)
+}
+
+// Маршрутизатор сообщений: команды вида /команда аргументы передаются
+// зарегистрированным обработчикам, остальной текст - обработчику текста.
+// /help строится из описаний команд
+type router struct {
+	// Имя бота без "@". Команды вида /команда@другой_бот из групп
+	// игнорируются. Если пусто, принимаются команды для любого бота
+	botName string
+	// Обработчик сообщений без команды
+	text handlerFunc
+	// Обработчик присланных файлов
+	document handlerFunc
+	// Обработчик inline-запросов. Ошибка отправляется пользователю как
+	// результат запроса
+	inline handlerFunc
+
+	commands   map[string]*command
+	order      []*command
+	middleware []middleware
+}
+
+// Создает маршрутизатор с командой /help
+func newRouter() *router {
+	r := &router{commands: map[string]*command{}}
+	r.handle("/help", "показать эту справку", func(ctx context.Context, req *request) error {
+		return req.reply(ctx, r.help())
+	})
+	return r
+}
+
+// Разбирает шаблоны аргументов: <имя> - обязательный, [имя] -
+// необязательный, "..." в конце последнего - любое число аргументов
+var patternRe = regexp.MustCompile(`^/([A-Za-z0-9_]{1,32})((?:\s+(?:<[^<>]+>|\[[^\[\]]+\]))*)$`)
+
+// Регистрирует обработчик команды. pattern - команда с аргументами,
+// например "/file <имя_файла...>". Повторная регистрация заменяет
+// обработчик
+func (r *router) handle(pattern, description string, handler handlerFunc) {
+	m := patternRe.FindStringSubmatch(strings.TrimSpace(pattern))
+	if m == nil {
+		panic(fmt.Sprintf("неверный шаблон команды %q", pattern))
+	}
+	cmd := &command{
+		name:        strings.ToLower(m[1]),
+		usage:       strings.Join(strings.Fields(m[2]), " "),
+		description: description,
+		handler:     handler,
+	}
+	for _, arg := range strings.Fields(m[2]) {
+		switch {
+		case cmd.maxArgs < 0:
+			panic(fmt.Sprintf("в шаблоне %q аргументы после \"...\"", pattern))
+		case strings.HasPrefix(arg, "<") && cmd.minArgs < cmd.maxArgs:
+			panic(fmt.Sprintf("в шаблоне %q обязательный аргумент после необязательного", pattern))
+		case strings.HasPrefix(arg, "<"):
+			cmd.minArgs++
+			cmd.maxArgs++
+		default:
+			cmd.maxArgs++
+		}
+		if strings.HasSuffix(arg[:len(arg)-1], "...") {
+			cmd.maxArgs = -1
+		}
+	}
+
+	if _, ok := r.commands[cmd.name]; !ok {
+		r.order = append(r.order, cmd)
+	} else {
+		for i, c := range r.order {
+			if c.name == cmd.name {
+				r.order[i] = cmd
+			}
+		}
+	}
+	r.commands[cmd.name] = cmd
+}
+
+// Добавляет промежуточные обработчики ко всем сообщениям. Первый
+// добавленный вызывается первым
+func (r *router) use(mw ...middleware) {
+	r.middleware = append(r.middleware, mw...)
+}
+
+// Текст справки из описаний команд в порядке регистрации, /help в конце
+func (r *router) help() string {
+	var b strings.Builder
+	b.WriteString("Доступные команды:\n")
+	cmds := append([]*command{}, r.order...)
+	for i, cmd := range cmds {
+		if cmd.name == "help" {
+			cmds = append(append(cmds[:i:i], cmds[i+1:]...), cmd)
+			break
+		}
+	}
+	for _, cmd := range cmds {
+		b.WriteString(cmd.String())
+		if cmd.description != "" {
+			b.WriteString(" - " + cmd.description)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// Команда в начале сообщения: имя и необязательное имя бота
+var commandRe = regexp.MustCompile(`^/([A-Za-z0-9_]{1,32})(?:@([A-Za-z0-9_]+))?(?:\s+|$)`)
+
+// Передает сообщение обработчику через промежуточные обработчики и
+// отправляет пользователю ошибку обработчика
+func (r *router) dispatch(ctx context.Context, client telegram.Client, msg *telegram.Message) {
+	req := &request{client: client, msg: msg, chatID: msg.Chat.ID}
+	handler := r.text
+	if msg.Document != nil {
+		handler = r.document
+	}
+
+	if m := commandRe.FindStringSubmatch(msg.Text); m != nil {
+		if m[2] != "" && r.botName != "" && !strings.EqualFold(m[2], r.botName) {
+			// команда для другого бота в группе
+			return
+		}
+		req.command = strings.ToLower(m[1])
+		req.rawArgs = strings.TrimSpace(msg.Text[len(m[0]):])
+		req.args = splitArgs(req.rawArgs)
+		handler = r.commandHandler(req.command)
+	}
+	if handler == nil {
+		return
+	}
+
+	if err := r.wrap(handler)(ctx, req); err != nil {
+		var usage *usageError
+		if errors.As(err, &usage) {
+			req.reply(ctx, "Использование: "+usage.cmd.String())
+			return
+		}
+		req.reply(ctx, fmt.Sprintf("Ошибка: %v", err))
+	}
+}
+
+// Передает inline-запрос обработчику через промежуточные обработчики.
+// Ошибка обработчика отправляется как результат запроса с объяснением
+func (r *router) dispatchInline(ctx context.Context, client telegram.Client, query *telegram.InlineQuery) {
+	if r.inline == nil {
+		return
+	}
+	req := &request{client: client, query: query, chatID: query.From.ID}
+	if err := r.wrap(r.inline)(ctx, req); err != nil {
+		err = client.AnswerInlineQuery(ctx, telegram.AnswerInlineQueryParams{
+			InlineQueryID: query.ID,
+			Results:       []telegram.InlineQueryResultArticle{inlineError("error", "Ошибка", err)},
+			IsPersonal:    true,
+		})
+		if err != nil {
+			log.Printf("Не удалось ответить на inline-запрос пользователя %d: %v", query.From.ID, err)
+		}
+	}
+}
+
+// Оборачивает обработчик промежуточными обработчиками
+func (r *router) wrap(handler handlerFunc) handlerFunc {
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		handler = r.middleware[i](handler)
+	}
+	return handler
+}
+
+// Возвращает обработчик команды, проверяющий число аргументов
+func (r *router) commandHandler(name string) handlerFunc {
+	cmd, ok := r.commands[name]
+	if !ok {
+		return func(ctx context.Context, req *request) error {
+			return req.reply(ctx, fmt.Sprintf("Неизвестная команда /%s. Список команд: /help", name))
+		}
+	}
+	return func(ctx context.Context, req *request) error {
+		if len(req.args) < cmd.minArgs || cmd.maxArgs >= 0 && len(req.args) > cmd.maxArgs {
+			return &usageError{cmd: cmd}
+		}
+		return cmd.handler(ctx, req)
+	}
+}
+
+// Разбивает аргументы по пробелам. Текст в двойных или одинарных кавычках
+// остается одним аргументом
+func splitArgs(s string) []string {
+	var args []string
+	var cur strings.Builder
+	var quote rune
+	inArg := false
+	for _, c := range s {
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			cur.WriteRune(c)
+		case c == '"' || c == '\'':
+			quote = c
+			inArg = true
+		case c == ' ' || c == '\t' || c == '\n':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(c)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args
+}
+
+// Записывает в лог каждое сообщение, время обработки и ошибку
+func logRequests(next handlerFunc) handlerFunc {
+	return func(ctx context.Context, req *request) error {
+		start := time.Now()
+		err := next(ctx, req)
+		what := "текст"
+		switch {
+		case req.command != "":
+			what = "/" + req.command
+		case req.query != nil:
+			what = "inline-запрос"
+		case req.msg.Document != nil:
+			what = "файл " + req.msg.Document.FileName
+		}
+		if err != nil {
+			log.Printf("Чат %d: %s за %v, ошибка: %v", req.chatID, what, time.Since(start).Round(time.Millisecond), err)
+		} else {
+			log.Printf("Чат %d: %s за %v", req.chatID, what, time.Since(start).Round(time.Millisecond))
+		}
+		return err
+	}
+}
+
+// Ошибка для inline-запросов пользователей не из списка разрешенных
+var errAccessDenied = errors.New("доступ запрещен")
+
+// Пропускает только сообщения из чатов allowed и inline-запросы
+// пользователей из allowed. Пустой список разрешает все чаты
+func allowChats(allowed []int64) middleware {
+	set := map[int64]bool{}
+	for _, id := range allowed {
+		set[id] = true
+	}
+	return func(next handlerFunc) handlerFunc {
+		return func(ctx context.Context, req *request) error {
+			if len(set) > 0 && !set[req.chatID] {
+				log.Printf("Чат %d не в списке разрешенных, сообщение пропущено", req.chatID)
+				if req.query != nil {
+					return errAccessDenied
+				}
+				return req.reply(ctx, "Доступ запрещен")
+			}
+			return next(ctx, req)
+		}
+	}
+}
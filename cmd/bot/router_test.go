@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/eternalsad/markdownify/telegram"
+	"github.com/eternalsad/markdownify/telegram/telegramtest"
+)
+
+// Отправляет text маршрутизатору и возвращает ответы бота
+func dispatchText(t *testing.T, r *router, chatID int64, text string) []string {
+	t.Helper()
+	srv := telegramtest.NewServer()
+	defer srv.Close()
+	client := telegram.NewSender(srv.Bot(), testSenderOptions)
+	r.dispatch(context.Background(), client, &telegram.Message{Chat: telegram.Chat{ID: chatID}, Text: text})
+	var replies []string
+	for _, msg := range srv.Messages() {
+		replies = append(replies, msg.PlainText)
+	}
+	return replies
+}
+
+func TestRouterArgs(t *testing.T) {
+	r := newRouter()
+	var got *request
+	r.handle("/echo <first> [second]", "повторить", func(ctx context.Context, req *request) error {
+		got = req
+		return req.reply(ctx, strings.Join(req.args, "|"))
+	})
+
+	if replies := dispatchText(t, r, 1, `/echo "a b" c`); !reflect.DeepEqual(replies, []string{"a b|c"}) {
+		t.Errorf("replies = %q", replies)
+	}
+	if got.command != "echo" || got.rawArgs != `"a b" c` {
+		t.Errorf("request = %+v", got)
+	}
+	if replies := dispatchText(t, r, 1, "/ECHO@bot one"); !reflect.DeepEqual(replies, []string{"one"}) {
+		t.Errorf("replies = %q", replies)
+	}
+	for _, text := range []string{"/echo", "/echo a b c"} {
+		replies := dispatchText(t, r, 1, text)
+		if !reflect.DeepEqual(replies, []string{"Использование: /echo <first> [second]"}) {
+			t.Errorf("%q: replies = %q", text, replies)
+		}
+	}
+}
+
+func TestRouterVariadic(t *testing.T) {
+	r := newRouter()
+	r.handle("/file <имя...>", "", func(ctx context.Context, req *request) error {
+		return req.reply(ctx, req.rawArgs)
+	})
+	if replies := dispatchText(t, r, 1, "/file my  file.md"); !reflect.DeepEqual(replies, []string{"my  file.md"}) {
+		t.Errorf("replies = %q", replies)
+	}
+}
+
+func TestRouterText(t *testing.T) {
+	r := newRouter()
+	r.text = func(ctx context.Context, req *request) error {
+		return req.reply(ctx, "text: "+req.msg.Text)
+	}
+	for text, expected := range map[string]string{
+		"hello":     "text: hello",
+		"/path/to":  "text: /path/to",
+		"/unknown":  "Неизвестная команда /unknown. Список команд: /help",
+		"/help@bot": "Доступные команды:\n/help - показать эту справку",
+	} {
+		if replies := dispatchText(t, r, 1, text); !reflect.DeepEqual(replies, []string{expected}) {
+			t.Errorf("%q: replies = %q", text, replies)
+		}
+	}
+}
+
+func TestRouterBotName(t *testing.T) {
+	r := newRouter()
+	r.botName = "MyBot"
+	if replies := dispatchText(t, r, -1, "/help@OtherBot"); len(replies) != 0 {
+		t.Errorf("replies = %q", replies)
+	}
+	if replies := dispatchText(t, r, -1, "/help@mybot"); len(replies) != 1 {
+		t.Errorf("replies = %q", replies)
+	}
+}
+
+func TestRouterHelp(t *testing.T) {
+	r := newBotRouter(&Config{}, os.DirFS("tests"))
+	expected := "Доступные команды:\n" +
+		"/start - отправить все тестовые файлы\n" +
+		"/files - отправить все тестовые файлы\n" +
+		"/file <имя_файла...> - отправить конкретный файл\n" +
+		"/help - показать эту справку\n"
+	if got := r.help(); got != expected {
+		t.Errorf("help = %q, expected %q", got, expected)
+	}
+}
+
+func TestRouterMiddleware(t *testing.T) {
+	r := newRouter()
+	var calls []string
+	mark := func(name string) middleware {
+		return func(next handlerFunc) handlerFunc {
+			return func(ctx context.Context, req *request) error {
+				calls = append(calls, name)
+				return next(ctx, req)
+			}
+		}
+	}
+	r.use(mark("first"), mark("second"), allowChats([]int64{1}))
+	r.handle("/ping", "", func(ctx context.Context, req *request) error {
+		calls = append(calls, "ping")
+		return req.reply(ctx, "pong")
+	})
+
+	if replies := dispatchText(t, r, 1, "/ping"); !reflect.DeepEqual(replies, []string{"pong"}) {
+		t.Errorf("replies = %q", replies)
+	}
+	if !reflect.DeepEqual(calls, []string{"first", "second", "ping"}) {
+		t.Errorf("calls = %q", calls)
+	}
+	if replies := dispatchText(t, r, 2, "/ping"); !reflect.DeepEqual(replies, []string{"Доступ запрещен"}) {
+		t.Errorf("replies = %q", replies)
+	}
+}
+
+func TestRouterBadPattern(t *testing.T) {
+	for _, pattern := range []string{"help", "/a [opt] <req>", "/a <rest...> <more>"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%q: expected a panic", pattern)
+				}
+			}()
+			newRouter().handle(pattern, "", nil)
+		}()
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	got := splitArgs(` one  "two three" 'fo"ur' `)
+	if expected := []string{"one", "two three", `fo"ur`}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("args = %q, expected %q", got, expected)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/eternalsad/markdownify/telegram"
+)
+
+// Возвращает обработчик HTTP-запросов вебхука. Запросы без секрета из
+// конфигурации отклоняются
+func webhookHandler(config *Config, handle telegram.UpdateHandler) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(config.WebhookPath, telegram.WebhookHandler(config.WebhookSecret, handle))
+	return mux
+}
+
+// Принимает обновления на config.ListenAddr, по TLS, если заданы
+// сертификат и ключ. После отмены ctx перестает принимать запросы и
+// дожидается уже начатых. Возвращает ошибку, если сервер остановился сам
+func runWebhook(ctx context.Context, config *Config, handle telegram.UpdateHandler) error {
+	server := &http.Server{
+		Addr:              config.ListenAddr,
+		Handler:           webhookHandler(config, handle),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	served := make(chan error, 1)
+	go func() {
+		if config.TLSCertFile != "" {
+			served <- server.ListenAndServeTLS(config.TLSCertFile, config.TLSKeyFile)
+		} else {
+			served <- server.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/eternalsad/markdownify/telegram"
+)
+
+func TestWebhook(t *testing.T) {
+	srv := newTestServer(t)
+	client := telegram.NewSender(srv.Bot(), testSenderOptions)
+	config := &Config{WebhookPath: "/webhook", WebhookSecret: "s3cret"}
+	webhook := httptest.NewServer(webhookHandler(config, newDispatcher(client, newBotRouter(config, srv.files))))
+	defer webhook.Close()
+
+	post := func(path, secret string) int {
+		body, _ := json.Marshal(telegram.Update{UpdateID: 1, Message: &telegram.Message{
+			Chat: telegram.Chat{ID: 42},
+			Text: "**bold**",
+		}})
+		req, _ := http.NewRequest(http.MethodPost, webhook.URL+path, bytes.NewReader(body))
+		req.Header.Set(telegram.SecretTokenHeader, secret)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := post("/webhook", "wrong"); code != http.StatusUnauthorized {
+		t.Errorf("wrong secret: code = %d", code)
+	}
+	if code := post("/other", "s3cret"); code != http.StatusNotFound {
+		t.Errorf("wrong path: code = %d", code)
+	}
+	if code := post("/webhook", "s3cret"); code != http.StatusOK {
+		t.Errorf("code = %d", code)
+	}
+	msgs := srv.WaitMessages(1, time.Second)
+	if len(msgs) != 1 || msgs[0].PlainText != "bold" || msgs[0].ChatID != 42 {
+		t.Errorf("messages = %+v", msgs)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("TELEGRAM_BOT_TOKEN", "123:abc")
+	t.Setenv("BOT_MODE", "")
+	t.Setenv("WEBHOOK_SECRET", "")
+	t.Setenv("BOT_WORKERS", "")
+
+	config, err := loadConfig(nil)
+	if err != nil || config.Mode != modePolling || config.Workers != defaultWorkers {
+		t.Fatalf("config = %+v, err = %v", config, err)
+	}
+
+	// режим вебхука без секрета не запускается
+	if _, err := loadConfig([]string{"-mode", "webhook"}); err == nil {
+		t.Error("expected an error without WEBHOOK_SECRET")
+	}
+
+	t.Setenv("BOT_MODE", modeWebhook)
+	t.Setenv("WEBHOOK_SECRET", "s3cret")
+	t.Setenv("WEBHOOK_LISTEN", ":9000")
+	config, err = loadConfig([]string{"-webhook-path", "hook"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Mode != modeWebhook || config.ListenAddr != ":9000" || config.WebhookPath != "/hook" {
+		t.Errorf("config = %+v", config)
+	}
+
+	// флаг важнее переменной окружения
+	if config, err := loadConfig([]string{"-mode", "polling"}); err != nil || config.Mode != modePolling {
+		t.Errorf("config = %+v, err = %v", config, err)
+	}
+	if _, err := loadConfig([]string{"-tls-cert", "cert.pem"}); err == nil {
+		t.Error("expected an error for a certificate without a key")
+	}
+	if _, err := loadConfig([]string{"-mode", "push"}); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+	t.Setenv("BOT_WORKERS", "0")
+	if _, err := loadConfig(nil); err == nil {
+		t.Error("expected an error for zero workers")
+	}
+	if config, err := loadConfig([]string{"-workers", "3"}); err != nil || config.Workers != 3 {
+		t.Errorf("config = %+v, err = %v", config, err)
+	}
+}
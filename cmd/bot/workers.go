@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"log"
+	"runtime/debug"
+	"sync"
+
+	"github.com/eternalsad/markdownify/telegram"
+)
+
+// Число обновлений, которые обрабатываются одновременно, по умолчанию
+const defaultWorkers = 8
+
+// Пул обработчиков обновлений. Обновления разных чатов обрабатываются
+// параллельно, не больше чем в workers горутинах, обновления одного чата -
+// строго по очереди, в порядке поступления
+type workerPool struct {
+	// Контекст обработчиков. Он не зависит от контекста получения
+	// обновлений, чтобы при остановке начатая обработка успела закончиться
+	ctx    context.Context
+	handle telegram.UpdateHandler
+	// Вызывается после обработки каждого обновления
+	done func(update telegram.Update)
+	// Наибольшее число горутин-обработчиков
+	workers int
+
+	mu sync.Mutex
+	// Очереди чатов. Чат есть в map, пока его очередь не обработана
+	chats map[int64][]telegram.Update
+	// Чаты, обновления которых ждут свободного обработчика, в порядке
+	// поступления. Чат, обновление которого обрабатывается, в ready не
+	// попадает, так что его обновления не обрабатываются параллельно
+	ready []int64
+	// Число запущенных обработчиков
+	running int
+	wg      sync.WaitGroup
+}
+
+// Создает пул из workers обработчиков, которые вызывают handle с ctx
+func newWorkerPool(ctx context.Context, workers int, handle telegram.UpdateHandler) *workerPool {
+	if workers < 1 {
+		workers = defaultWorkers
+	}
+	return &workerPool{
+		ctx:     ctx,
+		handle:  handle,
+		workers: workers,
+		chats:   map[int64][]telegram.Update{},
+	}
+}
+
+// Чат обновления, для inline-запроса - пользователь. Обновления без чата
+// попадают в общую очередь 0
+func updateChatID(update telegram.Update) int64 {
+	switch {
+	case update.Message != nil:
+		return update.Message.Chat.ID
+	case update.InlineQuery != nil:
+		return update.InlineQuery.From.ID
+	}
+	return 0
+}
+
+// Ставит обновление в очередь его чата и сразу возвращается. Обработчик
+// запускается, если очередь чата была пуста и запущено меньше workers
+// обработчиков
+func (p *workerPool) submit(update telegram.Update) {
+	chatID := updateChatID(update)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	queue, queued := p.chats[chatID]
+	p.chats[chatID] = append(queue, update)
+	if queued {
+		return
+	}
+	p.ready = append(p.ready, chatID)
+	if p.running < p.workers {
+		p.running++
+		p.wg.Add(1)
+		go p.run()
+	}
+}
+
+// Обрабатывает обновления чатов из ready по одному, пока они не кончатся.
+// После отмены контекста обработчиков оставшиеся очереди отбрасываются
+func (p *workerPool) run() {
+	defer p.wg.Done()
+	for {
+		p.mu.Lock()
+		if p.ctx.Err() != nil {
+			clear(p.chats)
+			p.ready = nil
+		}
+		if len(p.ready) == 0 {
+			p.running--
+			p.mu.Unlock()
+			return
+		}
+		chatID := p.ready[0]
+		p.ready = p.ready[1:]
+		queue := p.chats[chatID]
+		update := queue[0]
+		p.chats[chatID] = queue[1:]
+		p.mu.Unlock()
+
+		p.process(update)
+		// прерванная обработка не считается законченной
+		if p.done != nil && p.ctx.Err() == nil {
+			p.done(update)
+		}
+
+		// следующее обновление чата встает в конец ready, чтобы один чат
+		// не занимал обработчик, пока ждут другие
+		p.mu.Lock()
+		if len(p.chats[chatID]) == 0 {
+			delete(p.chats, chatID)
+		} else {
+			p.ready = append(p.ready, chatID)
+		}
+		p.mu.Unlock()
+	}
+}
+
+// Обрабатывает одно обновление. Паника в обработчике не останавливает
+// бота: обновление считается обработанным, чтобы не повторять его
+// бесконечно после перезапуска
+func (p *workerPool) process(update telegram.Update) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Паника при обработке обновления %d: %v\n%s", update.UpdateID, r, debug.Stack())
+		}
+	}()
+	p.handle(p.ctx, update)
+}
+
+// Ждет, пока будут обработаны все поставленные в очередь обновления.
+// Возвращает ошибку ctx, если он отменен раньше
+func (p *workerPool) wait(ctx context.Context) error {
+	finished := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
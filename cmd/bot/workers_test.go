@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eternalsad/markdownify/telegram"
+)
+
+// Обновление с сообщением из чата chatID
+func chatUpdate(id int, chatID int64) telegram.Update {
+	return telegram.Update{UpdateID: id, Message: &telegram.Message{Chat: telegram.Chat{ID: chatID}}}
+}
+
+func TestWorkerPoolOrder(t *testing.T) {
+	var mu sync.Mutex
+	got := map[int64][]int{}
+	pool := newWorkerPool(context.Background(), 3, func(ctx context.Context, update telegram.Update) {
+		// обновления с меньшим id обрабатываются дольше
+		time.Sleep(time.Duration(20-update.UpdateID%20) * 100 * time.Microsecond)
+		mu.Lock()
+		defer mu.Unlock()
+		chatID := updateChatID(update)
+		got[chatID] = append(got[chatID], update.UpdateID)
+	})
+	expected := map[int64][]int{}
+	for id := 0; id < 60; id++ {
+		chatID := int64(id % 4)
+		expected[chatID] = append(expected[chatID], id)
+		pool.submit(chatUpdate(id, chatID))
+	}
+	if err := pool.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("order = %v, expected %v", got, expected)
+	}
+}
+
+func TestWorkerPoolParallel(t *testing.T) {
+	release := make(chan struct{})
+	handled := make(chan int, 10)
+	pool := newWorkerPool(context.Background(), 2, func(ctx context.Context, update telegram.Update) {
+		if update.UpdateID == 1 {
+			<-release
+		}
+		handled <- update.UpdateID
+	})
+	pool.submit(chatUpdate(1, 1))
+	pool.submit(chatUpdate(2, 1))
+	pool.submit(chatUpdate(3, 2))
+
+	// другой чат не ждет первый
+	select {
+	case id := <-handled:
+		if id != 3 {
+			t.Fatalf("handled %d before the blocked update", id)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("chat 2 is blocked by chat 1")
+	}
+	// второе обновление первого чата ждет первое
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := pool.wait(ctx); err == nil {
+		t.Fatal("wait returned while an update is blocked")
+	}
+	close(release)
+	if err := pool.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if first, second := <-handled, <-handled; first != 1 || second != 2 {
+		t.Errorf("chat 1 order = %d, %d", first, second)
+	}
+}
+
+func TestWorkerPoolPanic(t *testing.T) {
+	var done []int
+	pool := newWorkerPool(context.Background(), 1, func(ctx context.Context, update telegram.Update) {
+		if update.UpdateID == 1 {
+			panic("boom")
+		}
+	})
+	pool.done = func(update telegram.Update) { done = append(done, update.UpdateID) }
+	pool.submit(chatUpdate(1, 1))
+	pool.submit(chatUpdate(2, 1))
+	pool.wait(context.Background())
+	if !reflect.DeepEqual(done, []int{1, 2}) {
+		t.Errorf("done = %v", done)
+	}
+}
+
+func TestWorkerPoolCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var mu sync.Mutex
+	var handled, done []int
+	pool := newWorkerPool(ctx, 2, func(ctx context.Context, update telegram.Update) {
+		mu.Lock()
+		handled = append(handled, update.UpdateID)
+		mu.Unlock()
+		if update.UpdateID == 2 {
+			<-ctx.Done()
+		}
+	})
+	pool.done = func(update telegram.Update) {
+		mu.Lock()
+		defer mu.Unlock()
+		done = append(done, update.UpdateID)
+	}
+	pool.submit(chatUpdate(1, 1))
+	pool.submit(chatUpdate(2, 1))
+	pool.submit(chatUpdate(3, 1))
+
+	// обработка не укладывается в отведенное время и прерывается
+	waitCtx, cancelWait := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancelWait()
+	if err := pool.wait(waitCtx); err == nil {
+		t.Fatal("wait returned while an update is blocked")
+	}
+	cancel()
+	if err := pool.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// прерванное обновление и очередь после него не отмечены обработанными
+	if !reflect.DeepEqual(handled, []int{1, 2}) || !reflect.DeepEqual(done, []int{1}) {
+		t.Errorf("handled = %v, done = %v", handled, done)
+	}
+}
+
+func TestWorkerPoolBounded(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan int, 10)
+	pool := newWorkerPool(ctx, 2, func(ctx context.Context, update telegram.Update) {
+		started <- update.UpdateID
+		<-ctx.Done()
+	})
+	for id := 1; id <= 10; id++ {
+		pool.submit(chatUpdate(id, int64(id)))
+	}
+	<-started
+	<-started
+	// чатов больше, чем обработчиков, остальные ждут в очереди
+	pool.mu.Lock()
+	running, ready := pool.running, len(pool.ready)
+	pool.mu.Unlock()
+	if running != 2 || ready != 8 {
+		t.Errorf("running = %d, ready = %d", running, ready)
+	}
+
+	// после отмены ожидающие очереди отбрасываются
+	cancel()
+	if err := pool.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(started) != 0 || len(pool.chats) != 0 {
+		t.Errorf("%d more updates handled, chats = %v", len(started), pool.chats)
+	}
+}
+
+func TestPollingShutdown(t *testing.T) {
+	srv := newTestServer(t)
+	client := telegram.NewSender(srv.Bot(), testSenderOptions)
+	offsetFile := t.TempDir() + "/offset"
+	offsets, err := loadOffset(offsetFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := newWorkerPool(context.Background(), 4, newDispatcher(client, newBotRouter(&Config{}, srv.files)))
+	pool.done = func(update telegram.Update) {
+		if err := offsets.done(update); err != nil {
+			t.Error(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		runPolling(ctx, client, offsets, pool)
+		close(stopped)
+	}()
+	srv.SendText(1, "first")
+	last := srv.SendText(2, "second")
+	if msgs := srv.WaitMessages(2, 5*time.Second); len(msgs) != 2 {
+		t.Fatalf("messages = %+v", msgs)
+	}
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("polling did not stop")
+	}
+	pool.wait(context.Background())
+
+	// после перезапуска обработанные обновления не запрашиваются снова
+	restarted, err := loadOffset(offsetFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset := restarted.nextOffset(); offset != last.UpdateID+1 {
+		t.Errorf("offset = %d, expected %d", offset, last.UpdateID+1)
+	}
+}
+
+func TestPollingConflict(t *testing.T) {
+	srv := newTestServer(t)
+	err := srv.Bot().SetWebhook(context.Background(), telegram.SetWebhookParams{URL: "https://example.com/hook"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := telegram.NewSender(srv.Bot(), testSenderOptions)
+	offsets, _ := loadOffset("")
+	pool := newWorkerPool(context.Background(), 1, newDispatcher(client, newBotRouter(&Config{}, srv.files)))
+
+	// с вебхуком getUpdates отвечает 409, опрос не повторяется
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := runPolling(ctx, client, offsets, pool); err == nil || ctx.Err() != nil {
+		t.Fatalf("err = %v", err)
+	}
+
+	// после deleteWebhook опрос работает
+	if err := srv.Bot().DeleteWebhook(ctx, telegram.DeleteWebhookParams{}); err != nil {
+		t.Fatal(err)
+	}
+	pollCtx, stop := context.WithCancel(ctx)
+	polled := make(chan error)
+	go func() { polled <- runPolling(pollCtx, client, offsets, pool) }()
+	srv.SendText(1, "text")
+	if msgs := srv.WaitMessages(1, 5*time.Second); len(msgs) != 1 {
+		t.Errorf("messages = %+v", msgs)
+	}
+	stop()
+	if err := <-polled; err != nil {
+		t.Error(err)
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"io/ioutil"
 	"os"
 
+	"github.com/eternalsad/markdownify"
+	"github.com/eternalsad/markdownify/ansi"
 	"github.com/eternalsad/markdownify/ast"
 	mdhtml "github.com/eternalsad/markdownify/html"
 	"github.com/eternalsad/markdownify/parser"
@@ -15,16 +17,20 @@ import (
 // Usage: printast <markdown-file>
 
 func usageAndExit() {
-	fmt.Printf("Usage: printast [-to-html] <markdown-file>\n")
+	fmt.Printf("Usage: printast [-to-html | -to-ansi [-width N]] <markdown-file>\n")
 	os.Exit(1)
 }
 
 func main() {
 	var (
 		flgToHTML bool
+		flgToANSI bool
+		flgWidth  int
 	)
 	{
 		flag.BoolVar(&flgToHTML, "to-html", false, "convert to HTML")
+		flag.BoolVar(&flgToANSI, "to-ansi", false, "render styled text for the terminal")
+		flag.IntVar(&flgWidth, "width", ansi.DefaultWidth, "wrap paragraphs at this width with -to-ansi")
 		flag.Parse()
 	}
 
@@ -56,6 +62,10 @@ func main() {
 			html := markdown.Render(doc, renderer)
 			fmt.Printf("HTML of file '%s':\n%s\n", fileName, string(html))
 
+		} else if flgToANSI {
+			renderer := ansi.NewRenderer(ansi.RendererOptions{Width: flgWidth})
+			os.Stdout.Write(markdown.Render(doc, renderer))
+
 		} else {
 			fmt.Printf("Ast of file '%s':\n", fileName)
 			ast.PrintWithPrefix(os.Stdout, doc, " ")
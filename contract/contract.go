@@ -1,36 +1,44 @@
+// Package contract converts Markdown, as written by people and chat
+// models, to the formats of messengers.
+//
+// The functions without options, like ConvertMD2 and ConvertSlack, rewrite
+// the input with NormalizeAll before parsing. The rules change the
+// document: \[ \] become a math block, a list right under a paragraph is
+// split off, an unclosed code block is closed, a table gets its missing
+// separator row and Unicode bullets become list items. To convert the
+// input as written, use the WithOptions variants with a zero
+// Options.Normalize.
 package contract
 
 import (
 	"bytes"
+
 	"github.com/eternalsad/markdownify/ast"
 	"github.com/eternalsad/markdownify/md2"
-	"github.com/eternalsad/markdownify/parser"
-)
-
-// Singleton instances for parser and renderer
-var (
-	mdParser    *parser.Parser
-	md2Renderer *md2.Renderer
 )
 
-// init initializes the parser and renderer once
-func init() {
-	// Initialize the renderer for Markdown V2
-	md2Renderer = md2.NewRenderer()
-}
-
-// ConvertMD2 converts regular Markdown to Telegram's Markdown V2 format
+// ConvertMD2 converts regular Markdown to Telegram's Markdown V2 format.
+// The input is rewritten with NormalizeAll first, see Normalize, where
+// earlier versions converted it as written. Use ConvertMD2WithOptions with
+// NormalizeNone to convert it as written. If the conversion fails,
+// ConvertMD2 returns an empty string, ConvertMD2WithOptions the error.
 func ConvertMD2(md string) string {
-	extensions := parser.CommonExtensions | parser.AutoHeadingIDs | parser.NoEmptyLineBeforeBlock
-	p := parser.NewWithExtensions(extensions)
-
-	// Parse the markdown input
-	doc := p.Parse([]byte(md))
-
-	// Render to Markdown V2
-	output := render(doc, md2Renderer)
+	opts := Options{Normalize: NormalizeAll}
+	output, _ := safely(md, opts, func() []byte {
+		output, _ := convert(md, opts)
+		return output
+	})
+	return output
+}
 
-	return string(output)
+// ConvertMD2Messages converts regular Markdown to Telegram's Markdown V2
+// format and splits the result into messages that fit MessageLimit.
+// Code blocks taken out of the text (see md2.CodeOverflowAttach) are
+// returned as attachments. Like ConvertMD2, the input is rewritten with
+// NormalizeAll first.
+func ConvertMD2Messages(md string, opts md2.RendererOptions) ([]string, []md2.Attachment) {
+	output, attachments := convert(md, Options{Renderer: opts, Normalize: NormalizeAll})
+	return SplitMessages(string(output), MessageLimit), attachments
 }
 
 func render(doc ast.Node, renderer *md2.Renderer) []byte {
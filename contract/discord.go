@@ -0,0 +1,37 @@
+package contract
+
+import (
+	"github.com/eternalsad/markdownify"
+	"github.com/eternalsad/markdownify/discord"
+)
+
+// ConvertDiscord converts regular Markdown to Discord markdown. The input is
+// normalized with all rules first, see Normalize.
+func ConvertDiscord(md string) string {
+	return string(convertDiscord(md, Options{Normalize: NormalizeAll}))
+}
+
+// ConvertDiscordWithOptions converts regular Markdown to Discord markdown.
+// The renderer is configured by opts.Discord.
+func ConvertDiscordWithOptions(md string, opts Options) (string, error) {
+	return safely(md, opts, func() []byte {
+		return convertDiscord(md, opts)
+	})
+}
+
+// ConvertDiscordMessages converts regular Markdown to Discord markdown and
+// splits the result into messages that fit discord.MessageLimit. Code
+// blocks that have to be broken are reopened in the next message.
+func ConvertDiscordMessages(md string, opts Options) ([]string, error) {
+	text, err := ConvertDiscordWithOptions(md, opts)
+	if err != nil {
+		return nil, err
+	}
+	return SplitMessages(text, discord.MessageLimit), nil
+}
+
+func convertDiscord(md string, opts Options) []byte {
+	doc := parse(md, opts)
+	opts.Discord.RawMath = opts.Discord.RawMath || opts.LaTeX == LaTeXRaw
+	return markdown.Render(doc, discord.NewRenderer(opts.Discord))
+}
@@ -0,0 +1,29 @@
+package contract
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/eternalsad/markdownify/discord"
+)
+
+func TestConvertDiscordMessages(t *testing.T) {
+	md := strings.Repeat("Some text that is long enough.\n\n", 50) +
+		"```go\n" + strings.Repeat("fmt.Println(\"line\")\n", 100) + "```\n"
+	messages, err := ConvertDiscordMessages(md, LLMOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) < 2 {
+		t.Fatalf("got %d messages, expected more than one", len(messages))
+	}
+	for i, msg := range messages {
+		if n := utf8.RuneCountInString(msg); n > discord.MessageLimit {
+			t.Errorf("message %d has %d characters", i, n)
+		}
+		if strings.Count(msg, "```")%2 != 0 {
+			t.Errorf("message %d has an unclosed code block:\n%s", i, msg)
+		}
+	}
+}
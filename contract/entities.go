@@ -0,0 +1,59 @@
+package contract
+
+import (
+	"github.com/eternalsad/markdownify"
+	"github.com/eternalsad/markdownify/entities"
+	"github.com/eternalsad/markdownify/md"
+)
+
+// ConvertEntitiesToMD converts a Telegram message, text with formatting
+// entities, to CommonMark. Formatting that CommonMark lacks, like underline
+// and spoilers, is written as inline HTML.
+func ConvertEntitiesToMD(text string, ents []entities.MessageEntity) string {
+	doc := entities.Parse(text, ents)
+	return string(markdown.Render(doc, md.NewRenderer()))
+}
+
+// ConvertMDToEntities converts regular Markdown to the text and formatting
+// entities of a Telegram message. Unlike MarkdownV2, such a message can't
+// be rejected for its markup. The input is normalized with all rules
+// first, see Normalize.
+func ConvertMDToEntities(md string) (string, []entities.MessageEntity) {
+	return convertMDToEntities(md, Options{Normalize: NormalizeAll})
+}
+
+// ConvertMDToEntitiesWithOptions converts regular Markdown to the text and
+// entities of a Telegram message. opts.Renderer is not used, the renderer
+// is configured by opts.Entities.
+func ConvertMDToEntitiesWithOptions(md string, opts Options) (string, []entities.MessageEntity, error) {
+	var text string
+	var ents []entities.MessageEntity
+	_, err := safely(md, opts, func() []byte {
+		text, ents = convertMDToEntities(md, opts)
+		return nil
+	})
+	return text, ents, err
+}
+
+func convertMDToEntities(md string, opts Options) (string, []entities.MessageEntity) {
+	doc := parse(md, opts)
+	opts.Entities.RawMath = opts.Entities.RawMath || opts.LaTeX == LaTeXRaw
+	return entities.Render(doc, opts.Entities)
+}
+
+// ConvertTelegramHTML converts regular Markdown to HTML for the HTML parse
+// mode of the Bot API, which supports only a few tags. The document is
+// formatted the same way as by ConvertMDToEntities. The input is normalized
+// with all rules first, see Normalize.
+func ConvertTelegramHTML(md string) string {
+	return entities.HTML(convertMDToEntities(md, Options{Normalize: NormalizeAll}))
+}
+
+// ConvertTelegramHTMLWithOptions converts regular Markdown to HTML for the
+// Bot API. Like for ConvertMDToEntitiesWithOptions, the renderer is
+// configured by opts.Entities.
+func ConvertTelegramHTMLWithOptions(md string, opts Options) (string, error) {
+	return safely(md, opts, func() []byte {
+		return []byte(entities.HTML(convertMDToEntities(md, opts)))
+	})
+}
@@ -0,0 +1,46 @@
+package contract
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+func TestConvertMDToEntities(t *testing.T) {
+	text, ents := ConvertMDToEntities(readSample(t, "sample9.md"))
+	for _, expected := range []string{"Основные шаги:", "1. Установить", "- Быстро", "Go | 2009"} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("expected %q in:\n%s", expected, text)
+		}
+	}
+	length := len(utf16.Encode([]rune(text)))
+	var bold bool
+	for _, e := range ents {
+		if e.Offset < 0 || e.Length <= 0 || e.Offset+e.Length > length {
+			t.Errorf("entity %+v outside of the text of length %d", e, length)
+			continue
+		}
+		units := utf16.Encode([]rune(text))[e.Offset : e.Offset+e.Length]
+		if e.Type == "bold" && string(utf16.Decode(units)) == "Основные шаги:" {
+			bold = true
+		}
+	}
+	if !bold {
+		t.Errorf("expected a bold entity for \"Основные шаги:\" in %+v", ents)
+	}
+	if _, _, err := ConvertMDToEntitiesWithOptions("too long", Options{MaxInputSize: 3}); !errors.Is(err, ErrInputTooLarge) {
+		t.Errorf("err = %v, expected ErrInputTooLarge", err)
+	}
+}
+
+func TestConvertTelegramHTML(t *testing.T) {
+	got := ConvertTelegramHTML("# Title\n\n**a < b** and [link](https://example.com/?a&b)")
+	expected := `<b>Title</b>` + "\n\n" + `<b>a &lt; b</b> and <a href="https://example.com/?a&amp;b">link</a>`
+	if got != expected {
+		t.Errorf("got %q, expected %q", got, expected)
+	}
+	if _, err := ConvertTelegramHTMLWithOptions("too long", Options{MaxInputSize: 3}); !errors.Is(err, ErrInputTooLarge) {
+		t.Errorf("err = %v, expected ErrInputTooLarge", err)
+	}
+}
@@ -0,0 +1,30 @@
+package contract
+
+import (
+	"github.com/eternalsad/markdownify/matrix"
+)
+
+// ConvertMatrix converts regular Markdown to the content of a Matrix
+// message with the org.matrix.custom.html formatted body and the plain
+// body. The input is normalized with all rules first, see Normalize.
+func ConvertMatrix(md string) matrix.Message {
+	return convertMatrix(md, Options{Normalize: NormalizeAll})
+}
+
+// ConvertMatrixWithOptions converts regular Markdown to the content of a
+// Matrix message. opts.Renderer is not used, the renderer is configured by
+// opts.Matrix.
+func ConvertMatrixWithOptions(md string, opts Options) (matrix.Message, error) {
+	var msg matrix.Message
+	_, err := safely(md, opts, func() []byte {
+		msg = convertMatrix(md, opts)
+		return nil
+	})
+	return msg, err
+}
+
+func convertMatrix(md string, opts Options) matrix.Message {
+	doc := parse(md, opts)
+	opts.Matrix.RawMath = opts.Matrix.RawMath || opts.LaTeX == LaTeXRaw
+	return matrix.Render(doc, opts.Matrix)
+}
@@ -0,0 +1,29 @@
+package contract
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/eternalsad/markdownify/matrix"
+)
+
+func TestConvertMatrix(t *testing.T) {
+	msg := ConvertMatrix(readSample(t, "sample9.md"))
+	if msg.Format != matrix.Format {
+		t.Errorf("format = %q", msg.Format)
+	}
+	for _, expected := range []string{"<strong>Основные шаги:</strong>", "<li>Быстро", "<td>Go</td>"} {
+		if !strings.Contains(msg.FormattedBody, expected) {
+			t.Errorf("expected %q in:\n%s", expected, msg.FormattedBody)
+		}
+	}
+	for _, expected := range []string{"1. Установить", "- Быстро", "Go | 2009"} {
+		if !strings.Contains(msg.Body, expected) {
+			t.Errorf("expected %q in:\n%s", expected, msg.Body)
+		}
+	}
+	if _, err := ConvertMatrixWithOptions("too long", Options{MaxInputSize: 3}); !errors.Is(err, ErrInputTooLarge) {
+		t.Errorf("err = %v, expected ErrInputTooLarge", err)
+	}
+}
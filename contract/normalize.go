@@ -0,0 +1,199 @@
+package contract
+
+import (
+	"regexp"
+	"strings"
+)
+
+// NormalizeRules is a set of rewrites that Normalize applies to Markdown
+// written by chat models, to fix patterns the parser would otherwise
+// mishandle.
+type NormalizeRules int
+
+// Normalization rules. They can be combined with bitwise OR.
+const (
+	NormalizeDisplayMath    NormalizeRules = 1 << iota // \[ and \] on their own lines become a $$ math block
+	NormalizeListSpacing                               // Put an empty line between a paragraph and a list right under it
+	NormalizeUnclosedFences                            // Close a code block left open at the end of the text
+	NormalizeTableSeparator                            // Add the missing separator row under the header of a table
+	NormalizeBullets                                   // Turn •, ◦, ▪ and ‣ bullets into Markdown list items
+
+	NormalizeNone NormalizeRules = 0
+	NormalizeAll                 = NormalizeDisplayMath | NormalizeListSpacing |
+		NormalizeUnclosedFences | NormalizeTableSeparator | NormalizeBullets
+)
+
+var (
+	// bulletRe matches a line that starts with a Unicode bullet.
+	bulletRe = regexp.MustCompile(`^(\s*)[•◦▪‣][ \t]+`)
+	// tableSeparatorRe matches the separator row of a table.
+	tableSeparatorRe = regexp.MustCompile(`^\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?$`)
+)
+
+// Normalize rewrites md according to rules. Code blocks are left as they
+// are.
+func Normalize(md string, rules NormalizeRules) string {
+	if rules == NormalizeNone {
+		return md
+	}
+	lines := strings.Split(md, "\n")
+	out := make([]string, 0, len(lines))
+	// last line written to out, "" at the start of a block
+	prev := func() string {
+		if len(out) == 0 {
+			return ""
+		}
+		return out[len(out)-1]
+	}
+	blankLine := func() {
+		if strings.TrimSpace(prev()) != "" {
+			out = append(out, "")
+		}
+	}
+
+	fence := "" // opening fence of the code block we are in
+	inList := false
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		if fence != "" {
+			if closesFence(trimmed, fence) {
+				fence = ""
+			}
+			out = append(out, line)
+			continue
+		}
+		if isFenceLine(line) {
+			fence = fenceMarker(trimmed)
+			out = append(out, line)
+			continue
+		}
+
+		if rules&NormalizeDisplayMath != 0 && strings.HasPrefix(trimmed, `\[`) {
+			if end, math := displayMath(lines, i); end >= 0 {
+				indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+				blankLine()
+				out = append(out, indent+"$$")
+				for _, l := range math {
+					out = append(out, indent+l)
+				}
+				out = append(out, indent+"$$")
+				if end+1 < len(lines) && strings.TrimSpace(lines[end+1]) != "" {
+					out = append(out, "")
+				}
+				i = end
+				inList = false
+				continue
+			}
+		}
+
+		if rules&NormalizeBullets != 0 {
+			if m := bulletRe.FindStringSubmatch(line); m != nil {
+				line = m[1] + "- " + line[len(m[0]):]
+			}
+		}
+
+		if trimmed == "" {
+			out = append(out, line)
+			continue
+		}
+		unindented := line[0] != ' ' && line[0] != '\t'
+		isItem := unindented && listItemRe.MatchString(line)
+
+		if rules&NormalizeListSpacing != 0 && isItem && !inList {
+			p := strings.TrimSpace(prev())
+			if p != "" && !strings.HasPrefix(p, "#") && !strings.HasPrefix(p, "|") {
+				out = append(out, "")
+			}
+		}
+		if isItem {
+			inList = true
+		} else if unindented && strings.TrimSpace(prev()) == "" {
+			inList = false
+		}
+
+		if rules&NormalizeTableSeparator != 0 && isTableRow(trimmed) && !isTableRow(strings.TrimSpace(prev())) &&
+			i+1 < len(lines) && isTableRow(strings.TrimSpace(lines[i+1])) &&
+			!tableSeparatorRe.MatchString(strings.TrimSpace(lines[i+1])) {
+			out = append(out, line, tableSeparator(trimmed))
+			continue
+		}
+
+		out = append(out, line)
+	}
+
+	if rules&NormalizeUnclosedFences != 0 && fence != "" {
+		if prev() != "" {
+			out = append(out, fence)
+		} else {
+			out[len(out)-1] = fence
+			out = append(out, "")
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// displayMath returns the index of the line that ends the display math
+// starting at lines[start] and the lines of the formula, without empty ones.
+// It returns -1 if the math isn't closed or doesn't stand on its own lines.
+func displayMath(lines []string, start int) (int, []string) {
+	first := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(lines[start]), `\[`))
+	// \[ ... \] on a single line
+	if strings.HasSuffix(first, `\]`) {
+		math := strings.TrimSpace(strings.TrimSuffix(first, `\]`))
+		if math == "" || strings.Contains(math, `\]`) {
+			return -1, nil
+		}
+		return start, []string{math}
+	}
+	var math []string
+	if first != "" {
+		math = append(math, first)
+	}
+	for i := start + 1; i < len(lines); i++ {
+		l := strings.TrimSpace(lines[i])
+		if isFenceLine(lines[i]) {
+			return -1, nil
+		}
+		if strings.HasSuffix(l, `\]`) {
+			if l = strings.TrimSpace(strings.TrimSuffix(l, `\]`)); l != "" {
+				math = append(math, l)
+			}
+			return i, math
+		}
+		if l != "" {
+			math = append(math, l)
+		}
+	}
+	return -1, nil
+}
+
+// fenceMarker returns the backticks or tildes that open a code block.
+func fenceMarker(line string) string {
+	n := 0
+	for n < len(line) && line[n] == line[0] {
+		n++
+	}
+	return line[:n]
+}
+
+// closesFence reports whether line closes the code block opened by fence.
+func closesFence(line, fence string) bool {
+	marker := fenceMarker(line)
+	return marker != "" && marker[0] == fence[0] && len(marker) >= len(fence) &&
+		strings.TrimSpace(line[len(marker):]) == ""
+}
+
+// isTableRow reports whether line looks like a row of a table with at least
+// two cells.
+func isTableRow(line string) bool {
+	return len(line) > 1 && strings.HasPrefix(line, "|") && strings.HasSuffix(line, "|") &&
+		strings.Count(line, "|") >= 3
+}
+
+// tableSeparator returns the separator row for the header row.
+func tableSeparator(header string) string {
+	cells := strings.Count(strings.ReplaceAll(header, `\|`, ""), "|") - 1
+	return "|" + strings.Repeat(" --- |", cells)
+}
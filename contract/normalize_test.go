@@ -0,0 +1,86 @@
+package contract
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readSample(t *testing.T, name string) string {
+	t.Helper()
+	d, err := os.ReadFile(filepath.Join("..", "cmd", "bot", "tests", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(d)
+}
+
+func TestNormalizeRules(t *testing.T) {
+	var tests = []struct {
+		rule     NormalizeRules
+		sample   string
+		expected string
+	}{
+		{NormalizeDisplayMath, "input.txt", "неравенство\n\n$$\n\\sum_{i=1}^{n} \\sum_{j=1}^{n} \\sqrt{|x_i - x_j|} \\leq \\sum_{i=1}^{n} \\sum_{j=1}^{n} \\sqrt{|x_i + x_j|},\n$$\n\nмы"},
+		{NormalizeDisplayMath, "sample9.md", "круга:\n\n$$\nS = \\pi r^2\n$$\n\nПример"},
+		{NormalizeListSpacing, "sample9.md", "**Основные шаги:**\n\n1. Установить"},
+		{NormalizeUnclosedFences, "sample9.md", "fmt.Println(\"• не список\")\n```\n"},
+		{NormalizeTableSeparator, "sample9.md", "| Язык | Год |\n| --- | --- |\n| Go | 2009 |"},
+		{NormalizeBullets, "sample9.md", "- Быстро\n- Просто\n  - Без настройки"},
+	}
+	for _, test := range tests {
+		md := readSample(t, test.sample)
+		if got := Normalize(md, test.rule); !strings.Contains(got, test.expected) {
+			t.Errorf("Normalize(%s, %d) doesn't contain %q:\n%s", test.sample, test.rule, test.expected, got)
+		}
+		// every rule can be switched off on its own
+		if got := Normalize(md, NormalizeAll&^test.rule); strings.Contains(got, test.expected) {
+			t.Errorf("Normalize(%s, all but %d) contains %q", test.sample, test.rule, test.expected)
+		}
+	}
+}
+
+func TestNormalizeKeepsCode(t *testing.T) {
+	md := readSample(t, "sample9.md")
+	got := Normalize(md, NormalizeAll)
+	if !strings.Contains(got, "\tfmt.Println(\"• не список\")") {
+		t.Errorf("bullet in code was changed:\n%s", got)
+	}
+	md = "```\n\\[\nx\n\\]\n| a | b |\n| 1 | 2 |\n```\n"
+	if got := Normalize(md, NormalizeAll); got != md {
+		t.Errorf("Normalize(%q) = %q", md, got)
+	}
+}
+
+func TestNormalizeList(t *testing.T) {
+	var tests = []string{
+		// lazy continuation lines stay in the list
+		"- a\nmore\n- b", "- a\nmore\n- b",
+		"Text:\n- a\n- b", "Text:\n\n- a\n- b",
+		"# Title\n- a", "# Title\n- a",
+		"Text:\n\n- a", "Text:\n\n- a",
+	}
+	for i := 0; i+1 < len(tests); i += 2 {
+		if got := Normalize(tests[i], NormalizeListSpacing); got != tests[i+1] {
+			t.Errorf("Normalize(%q) = %q, expected %q", tests[i], got, tests[i+1])
+		}
+	}
+}
+
+func TestNormalizeSamples(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "cmd", "bot", "tests", "*"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no samples: %v", err)
+	}
+	for _, file := range files {
+		d, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		once := Normalize(string(d), NormalizeAll)
+		if twice := Normalize(once, NormalizeAll); twice != once {
+			t.Errorf("%s: Normalize is not idempotent\nonce: %q\ntwice: %q", file, once, twice)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package contract
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/eternalsad/markdownify/ast"
+	"github.com/eternalsad/markdownify/discord"
+	"github.com/eternalsad/markdownify/entities"
+	"github.com/eternalsad/markdownify/matrix"
+	"github.com/eternalsad/markdownify/md2"
+	"github.com/eternalsad/markdownify/parser"
+	"github.com/eternalsad/markdownify/slack"
+	"github.com/eternalsad/markdownify/whatsapp"
+)
+
+// DefaultExtensions are the parser extensions used by ConvertMD2 and for
+// blank Options.Extensions.
+const DefaultExtensions = parser.CommonExtensions | parser.AutoHeadingIDs | parser.NoEmptyLineBeforeBlock |
+	parser.OrderedListStart | parser.TaskLists
+
+// ErrInputTooLarge is returned for input longer than Options.MaxInputSize.
+var ErrInputTooLarge = errors.New("contract: input is too large")
+
+// LaTeXMode tells how LaTeX formulas are converted.
+type LaTeXMode int
+
+const (
+	// LaTeXUnicode replaces LaTeX commands in formulas with Unicode symbols,
+	// \sum becomes ∑.
+	LaTeXUnicode LaTeXMode = iota
+	// LaTeXRaw keeps formulas as written.
+	LaTeXRaw
+)
+
+// Options configures the conversions of this package, e.g.
+// ConvertMD2WithOptions.
+type Options struct {
+	// Extensions are the parser extensions, DefaultExtensions if 0.
+	Extensions parser.Extensions
+	// Renderer configures the MarkdownV2 renderer.
+	Renderer md2.RendererOptions
+	// Slack configures the Slack mrkdwn renderer.
+	Slack slack.RendererOptions
+	// Discord configures the Discord renderer.
+	Discord discord.RendererOptions
+	// Matrix configures the Matrix renderer.
+	Matrix matrix.RendererOptions
+	// WhatsApp configures the WhatsApp renderer.
+	WhatsApp whatsapp.RendererOptions
+	// Entities configures the renderer of plain text with entities.
+	Entities entities.RendererOptions
+	// Normalize are the rules applied to the input before parsing.
+	Normalize NormalizeRules
+	// MaxInputSize, if > 0, is the maximum length of the input in bytes.
+	MaxInputSize int
+	// LaTeX tells how formulas are converted.
+	LaTeX LaTeXMode
+	// PropagatePanics lets panics of the parser and the renderer, e.g. in a
+	// RenderNodeHook, through. By default they are returned as errors.
+	PropagatePanics bool
+}
+
+// Presets for common kinds of input.
+var (
+	// ChatOptions suit short messages typed by people.
+	ChatOptions = Options{
+		MaxInputSize: 16 << 10,
+	}
+	// DocumentOptions suit long Markdown files. Long code blocks are split
+	// so that they can be sent in several messages.
+	DocumentOptions = Options{
+		Renderer: md2.RendererOptions{
+			CodeBlockMaxLines: 100,
+			CodeBlockOverflow: md2.CodeOverflowSplit,
+		},
+		Normalize:    NormalizeUnclosedFences,
+		MaxInputSize: 1 << 20,
+	}
+	// LLMOptions suit replies of chat models, see Normalize.
+	LLMOptions = Options{
+		Normalize:    NormalizeAll,
+		MaxInputSize: 256 << 10,
+	}
+)
+
+// ConvertMD2WithOptions converts regular Markdown to Telegram's Markdown V2
+// format. Code blocks taken out of the text with md2.CodeOverflowAttach are
+// dropped, use ConvertMD2Messages to get them.
+func ConvertMD2WithOptions(md string, opts Options) (string, error) {
+	return safely(md, opts, func() []byte {
+		output, _ := convert(md, opts)
+		return output
+	})
+}
+
+// safely checks md against opts.MaxInputSize and runs conv. Panics of conv
+// are returned as errors unless opts.PropagatePanics is set.
+func safely(md string, opts Options, conv func() []byte) (out string, err error) {
+	if opts.MaxInputSize > 0 && len(md) > opts.MaxInputSize {
+		return "", fmt.Errorf("%w: %d bytes, the limit is %d", ErrInputTooLarge, len(md), opts.MaxInputSize)
+	}
+	if !opts.PropagatePanics {
+		defer func() {
+			if r := recover(); r != nil {
+				out, err = "", fmt.Errorf("contract: converting markdown: %v", r)
+			}
+		}()
+	}
+	return string(conv()), nil
+}
+
+// parse normalizes and parses md.
+func parse(md string, opts Options) ast.Node {
+	extensions := opts.Extensions
+	if extensions == 0 {
+		extensions = DefaultExtensions
+	}
+	if opts.LaTeX == LaTeXRaw {
+		extensions |= parser.NoLaTeXUnicode
+	}
+	p := parser.NewWithExtensions(extensions)
+	return p.Parse([]byte(Normalize(md, opts.Normalize)))
+}
+
+// convert parses and renders md, returning the output and the attachments.
+func convert(md string, opts Options) ([]byte, []md2.Attachment) {
+	doc := parse(md, opts)
+	opts.Renderer.RawMath = opts.Renderer.RawMath || opts.LaTeX == LaTeXRaw
+	renderer := md2.NewRenderer(opts.Renderer)
+	return render(doc, renderer), renderer.Attachments
+}
@@ -0,0 +1,80 @@
+package contract
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/eternalsad/markdownify/ast"
+	"github.com/eternalsad/markdownify/md2"
+	"github.com/eternalsad/markdownify/parser"
+)
+
+func TestConvertMD2WithOptions(t *testing.T) {
+	md := readSample(t, "sample9.md")
+	got, err := ConvertMD2WithOptions(md, LLMOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := ConvertMD2(md); got != expected {
+		t.Errorf("\nExpected[%#v]\nGot     [%#v]\n", expected, got)
+	}
+}
+
+func TestConvertMD2WithOptionsMaxInputSize(t *testing.T) {
+	_, err := ConvertMD2WithOptions(strings.Repeat("a", 11), Options{MaxInputSize: 10})
+	if !errors.Is(err, ErrInputTooLarge) {
+		t.Errorf("err = %v, expected ErrInputTooLarge", err)
+	}
+	if _, err := ConvertMD2WithOptions(strings.Repeat("a", 10), Options{MaxInputSize: 10}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestConvertMD2WithOptionsRecoverPanics(t *testing.T) {
+	opts := Options{
+		Renderer: md2.RendererOptions{
+			RenderNodeHook: func(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+				panic("boom")
+			},
+		},
+	}
+	if _, err := ConvertMD2WithOptions("text", opts); err == nil {
+		t.Error("expected an error")
+	}
+	opts.PropagatePanics = true
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected a panic")
+			}
+		}()
+		ConvertMD2WithOptions("text", opts)
+	}()
+	// document matter of mmark is supported
+	opts = Options{Extensions: DefaultExtensions | parser.Mmark}
+	if out, err := ConvertMD2WithOptions("{frontmatter}\ntext\n", opts); err != nil || out != "text\n\n" {
+		t.Errorf("out = %q, err = %v", out, err)
+	}
+}
+
+func TestConvertMD2WithOptionsLaTeX(t *testing.T) {
+	md := "\\[\n\\alpha + \\beta\n\\]\n"
+	var tests = []struct {
+		latex    LaTeXMode
+		expected string
+	}{
+		{LaTeXUnicode, "```\nα + β\n```"},
+		{LaTeXRaw, "```\n\\\\alpha + \\\\beta\n```"},
+	}
+	for _, test := range tests {
+		got, err := ConvertMD2WithOptions(md, Options{Normalize: NormalizeDisplayMath, LaTeX: test.latex})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(got, test.expected) {
+			t.Errorf("LaTeX %d: expected %q in %q", test.latex, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package contract
+
+import (
+	"github.com/eternalsad/markdownify"
+	"github.com/eternalsad/markdownify/slack"
+)
+
+// ConvertSlack converts regular Markdown to Slack's mrkdwn format. The input
+// is normalized with all rules first, see Normalize.
+func ConvertSlack(md string) string {
+	return string(convertSlack(md, Options{Normalize: NormalizeAll}))
+}
+
+// ConvertSlackWithOptions converts regular Markdown to Slack's mrkdwn
+// format. opts.Renderer is not used, the renderer is configured by
+// opts.Slack.
+func ConvertSlackWithOptions(md string, opts Options) (string, error) {
+	return safely(md, opts, func() []byte {
+		return convertSlack(md, opts)
+	})
+}
+
+func convertSlack(md string, opts Options) []byte {
+	doc := parse(md, opts)
+	opts.Slack.RawMath = opts.Slack.RawMath || opts.LaTeX == LaTeXRaw
+	return markdown.Render(doc, slack.NewRenderer(opts.Slack))
+}
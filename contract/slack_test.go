@@ -0,0 +1,19 @@
+package contract
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestConvertSlack(t *testing.T) {
+	got := ConvertSlack(readSample(t, "sample9.md"))
+	for _, expected := range []string{"*Основные шаги:*\n\n1. Установить", "• Быстро", "Go   | 2009"} {
+		if !strings.Contains(got, expected) {
+			t.Errorf("expected %q in:\n%s", expected, got)
+		}
+	}
+	if _, err := ConvertSlackWithOptions("too long", Options{MaxInputSize: 3}); !errors.Is(err, ErrInputTooLarge) {
+		t.Errorf("err = %v, expected ErrInputTooLarge", err)
+	}
+}
@@ -0,0 +1,168 @@
+package contract
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// MessageLimit is the maximum length of a Telegram message.
+const MessageLimit = 4096
+
+// SplitMessages splits MarkdownV2 text into messages of at most limit
+// characters. It breaks between blocks (at empty lines) where it can, then
+// between lines. Code blocks that have to be broken are closed at the end of
+// one message and reopened, with their language, in the next one.
+func SplitMessages(text string, limit int) []string {
+	if limit <= 0 {
+		limit = MessageLimit
+	}
+	var messages []string
+	var cur strings.Builder
+	flush := func() {
+		if s := strings.Trim(cur.String(), "\n"); s != "" {
+			messages = append(messages, s)
+		}
+		cur.Reset()
+	}
+
+	for _, block := range splitBlocks(text) {
+		sep := ""
+		if cur.Len() > 0 {
+			sep = "\n\n"
+		}
+		if runeLen(cur.String())+runeLen(sep)+runeLen(block) <= limit {
+			cur.WriteString(sep)
+			cur.WriteString(block)
+			continue
+		}
+		flush()
+		if runeLen(block) <= limit {
+			cur.WriteString(block)
+			continue
+		}
+		for _, part := range splitBlock(block, limit) {
+			messages = append(messages, part)
+		}
+	}
+	flush()
+	return messages
+}
+
+// splitBlocks splits text at empty lines, keeping code blocks whole.
+func splitBlocks(text string) []string {
+	var blocks []string
+	var cur []string
+	inFence := false
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(line, "```") {
+			inFence = !inFence
+		}
+		if line == "" && !inFence {
+			if len(cur) > 0 {
+				blocks = append(blocks, strings.Join(cur, "\n"))
+				cur = nil
+			}
+			continue
+		}
+		cur = append(cur, line)
+	}
+	if len(cur) > 0 {
+		blocks = append(blocks, strings.Join(cur, "\n"))
+	}
+	return blocks
+}
+
+// splitBlock splits a single block that is longer than limit by lines, and
+// lines that are still too long by characters.
+func splitBlock(block string, limit int) []string {
+	var parts []string
+	var cur strings.Builder
+	fence := "" // opening line of the code block we are in
+	closing := func() string {
+		if fence != "" {
+			return "\n```"
+		}
+		return ""
+	}
+	flush := func() {
+		if cur.Len() == 0 {
+			return
+		}
+		parts = append(parts, cur.String()+closing())
+		cur.Reset()
+		if fence != "" {
+			cur.WriteString(fence)
+		}
+	}
+
+	for _, line := range strings.Split(block, "\n") {
+		isFence := strings.HasPrefix(line, "```")
+		if isFence && fence != "" {
+			// closing fence, it always fits because we reserve room for it
+			cur.WriteString("\n")
+			cur.WriteString(line)
+			fence = ""
+			continue
+		}
+		// leave room to close the code block this line opens or is in
+		reserve := runeLen(closing())
+		if isFence {
+			reserve = len("\n```")
+		}
+		for {
+			sep := ""
+			if cur.Len() > 0 {
+				sep = "\n"
+			}
+			room := limit - runeLen(cur.String()) - len(sep) - reserve
+			if runeLen(line) <= room {
+				cur.WriteString(sep)
+				cur.WriteString(line)
+				break
+			}
+			if cur.Len() > len(fence) {
+				flush()
+				continue
+			}
+			// the line doesn't fit even in an empty message
+			head := cutRunes(line, room)
+			cur.WriteString(sep)
+			cur.WriteString(head)
+			line = line[len(head):]
+			flush()
+		}
+		if isFence {
+			fence = line
+		}
+	}
+	if cur.Len() > len(fence) {
+		parts = append(parts, cur.String()+closing())
+	}
+	return parts
+}
+
+// cutRunes returns the longest prefix of s with at most n runes that doesn't
+// end in the middle of a backslash escape.
+func cutRunes(s string, n int) string {
+	if n < 1 {
+		n = 1
+	}
+	i := 0
+	for count := 0; i < len(s) && count < n; count++ {
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+	}
+	// don't separate a backslash from the character it escapes
+	backslashes := 0
+	for j := i - 1; j >= 0 && s[j] == '\\'; j-- {
+		backslashes++
+	}
+	if backslashes%2 == 1 && i > 1 {
+		i--
+	}
+	return s[:i]
+}
+
+func runeLen(s string) int {
+	return utf8.RuneCountInString(s)
+}
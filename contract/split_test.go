@@ -0,0 +1,96 @@
+package contract
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/eternalsad/markdownify/md2"
+)
+
+func TestSplitMessagesShort(t *testing.T) {
+	got := SplitMessages("aaa\n\nbbb\n", 100)
+	expected := []string{"aaa\n\nbbb"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("\nExpected[%#v]\nGot     [%#v]\n", expected, got)
+	}
+}
+
+func TestSplitMessagesBlocks(t *testing.T) {
+	got := SplitMessages("aaaa\n\nbbbb\n\ncccc\n", 10)
+	expected := []string{"aaaa\n\nbbbb", "cccc"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("\nExpected[%#v]\nGot     [%#v]\n", expected, got)
+	}
+}
+
+func TestSplitMessagesCodeBlock(t *testing.T) {
+	text := "```go\nline 1\n\nline 2\nline 3\n```\n"
+	got := SplitMessages(text, 20)
+	expected := []string{
+		"```go\nline 1\n\n```",
+		"```go\nline 2\n```",
+		"```go\nline 3\n```",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("\nExpected[%#v]\nGot     [%#v]\n", expected, got)
+	}
+}
+
+func TestSplitMessagesLongLine(t *testing.T) {
+	text := strings.Repeat("a", 9) + `\.` + strings.Repeat("b", 5)
+	got := SplitMessages(text, 10)
+	expected := []string{strings.Repeat("a", 9), `\.` + strings.Repeat("b", 5)}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("\nExpected[%#v]\nGot     [%#v]\n", expected, got)
+	}
+}
+
+func TestSplitMessagesLimit(t *testing.T) {
+	var sb strings.Builder
+	for i := 0; i < 500; i++ {
+		sb.WriteString("Строка текста номер *")
+		sb.WriteString(strings.Repeat("x", i%50))
+		sb.WriteString("*\n")
+		if i%40 == 0 {
+			sb.WriteString("\n```python\nprint(1)\n\nprint(2)\n```\n\n")
+		}
+	}
+	for _, msg := range SplitMessages(sb.String(), 300) {
+		if n := utf8.RuneCountInString(msg); n > 300 {
+			t.Fatalf("message of %d characters: %q", n, msg)
+		}
+		if strings.Count(msg, "```")%2 != 0 {
+			t.Fatalf("unbalanced code block: %q", msg)
+		}
+	}
+}
+
+func TestConvertMD2MessagesCode(t *testing.T) {
+	md := "```golang\nfmt.Println(`a\\b`)\n```\n"
+	messages, _ := ConvertMD2Messages(md, md2.RendererOptions{})
+	expected := []string{"```go\nfmt.Println(\\`a\\\\b\\`)\n```"}
+	if !reflect.DeepEqual(messages, expected) {
+		t.Errorf("\nExpected[%#v]\nGot     [%#v]\n", expected, messages)
+	}
+
+	md = "```sh\n1\n2\n3\n```\n"
+	messages, _ = ConvertMD2Messages(md, md2.RendererOptions{CodeBlockMaxLines: 2})
+	expected = []string{"```bash\n1\n2\n```\n\n```bash\n3\n```"}
+	if !reflect.DeepEqual(messages, expected) {
+		t.Errorf("\nExpected[%#v]\nGot     [%#v]\n", expected, messages)
+	}
+
+	messages, attachments := ConvertMD2Messages("```go main.go\n1\n2\n3\n```\n", md2.RendererOptions{
+		CodeBlockMaxLines: 2,
+		CodeBlockOverflow: md2.CodeOverflowAttach,
+	})
+	expected = []string{"📎 main\\.go \\(3 lines\\)"}
+	if !reflect.DeepEqual(messages, expected) {
+		t.Errorf("\nExpected[%#v]\nGot     [%#v]\n", expected, messages)
+	}
+	if len(attachments) != 1 || attachments[0].FileName != "main.go" || string(attachments[0].Content) != "1\n2\n3\n" {
+		t.Errorf("unexpected attachments %#v", attachments)
+	}
+}
@@ -0,0 +1,218 @@
+package contract
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/eternalsad/markdownify/md2"
+)
+
+// Stream converts Markdown that arrives in pieces, e.g. tokens of an LLM
+// reply, to Telegram's Markdown V2 format.
+//
+// Snapshot can be called after every Write: constructs that are still open
+// at the end of the text (code blocks, bold, inline code) are closed in
+// the snapshot, so it is always valid Markdown V2. Blocks that can no longer
+// change are converted once and reused by later snapshots. Snapshot never
+// panics: a block the renderer fails on is shown as plain text.
+type Stream struct {
+	text strings.Builder
+	opts md2.RendererOptions
+
+	// text[:stable] has been converted to done
+	stable int
+	done   strings.Builder
+}
+
+// NewStream returns a Stream that renders with the default md2 options.
+func NewStream() *Stream {
+	return NewStreamWithOptions(md2.RendererOptions{})
+}
+
+// NewStreamWithOptions returns a Stream that renders with the given options.
+func NewStreamWithOptions(opts md2.RendererOptions) *Stream {
+	return &Stream{opts: opts}
+}
+
+// Write appends a piece of Markdown to the stream. It never fails.
+func (s *Stream) Write(delta []byte) (int, error) {
+	s.text.Write(delta)
+	return len(delta), nil
+}
+
+// WriteString appends a piece of Markdown to the stream. It never fails.
+func (s *Stream) WriteString(delta string) (int, error) {
+	s.text.WriteString(delta)
+	return len(delta), nil
+}
+
+// Text returns the Markdown written so far.
+func (s *Stream) Text() string {
+	return s.text.String()
+}
+
+// Snapshot returns Markdown V2 for the text written so far.
+func (s *Stream) Snapshot() string {
+	text := s.text.String()
+
+	if end := stableEnd(text, s.stable); end > s.stable {
+		s.done.WriteString(s.convert(text[s.stable:end]))
+		s.stable = end
+	}
+
+	tail := closePending(text[s.stable:])
+	return s.done.String() + s.convert(tail)
+}
+
+func (s *Stream) convert(md string) string {
+	if strings.TrimSpace(md) == "" {
+		return ""
+	}
+	opts := Options{Renderer: s.opts, Normalize: NormalizeAll}
+	output, err := safely(md, opts, func() []byte {
+		output, _ := convert(md, opts)
+		return output
+	})
+	if err != nil {
+		return md2.Escape(md)
+	}
+	return output
+}
+
+// stableEnd returns the end of the longest prefix of text that is made of
+// complete blocks, which later text can't change. It is the start of a line
+// that follows an empty line outside of code blocks and starts a new block
+// at the top level. The prefix never gets shorter than from.
+func stableEnd(text string, from int) int {
+	end := from
+	inFence := false
+	blank := false
+	// the last top level block is a list, which later items can continue
+	inList := false
+	pos := 0
+	for pos < len(text) {
+		nl := strings.IndexByte(text[pos:], '\n')
+		if nl < 0 {
+			// the last line may still be incomplete
+			break
+		}
+		line := text[pos : pos+nl]
+		next := pos + nl + 1
+
+		switch {
+		case inFence:
+			if isFenceLine(line) {
+				inFence = false
+			}
+		case strings.TrimSpace(line) == "":
+			blank = true
+		default:
+			if blank && pos > from && startsBlock(line, inList) {
+				end = pos
+			}
+			if line[0] != ' ' && line[0] != '\t' {
+				inList = listItemRe.MatchString(line)
+			}
+			inFence = isFenceLine(line)
+			blank = false
+		}
+		pos = next
+	}
+	return end
+}
+
+// startsBlock reports whether line, that follows an empty line, starts a
+// new top level block instead of continuing the one before it.
+func startsBlock(line string, inList bool) bool {
+	if line[0] == ' ' || line[0] == '\t' {
+		// indented lines continue lists
+		return false
+	}
+	// list items after an empty line make a loose list with the ones before
+	if inList && listItemRe.MatchString(line) {
+		return false
+	}
+	// definitions belong to the term above them
+	if strings.HasPrefix(line, ":") {
+		return false
+	}
+	// the link reference definitions and footnotes are resolved across the
+	// whole document
+	if strings.HasPrefix(line, "[") && strings.Contains(line, "]:") {
+		return false
+	}
+	return true
+}
+
+// listItemRe matches the start of a bullet or numbered list item.
+var listItemRe = regexp.MustCompile(`^([-*+]|\d{1,9}[.)])(\s|$)`)
+
+func isFenceLine(line string) bool {
+	line = strings.TrimLeft(line, " ")
+	return strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~")
+}
+
+// closePending closes constructs left open at the end of md, so that the
+// text converts as if they were complete.
+func closePending(md string) string {
+	fence := ""
+	inFence := false
+	// end of the last code block
+	afterFence := 0
+	pos := 0
+	for _, line := range strings.Split(md, "\n") {
+		pos += len(line) + 1
+		if isFenceLine(line) {
+			if !inFence {
+				trimmed := strings.TrimLeft(line, " ")
+				fence = trimmed[:3]
+			}
+			inFence = !inFence
+			afterFence = pos
+		}
+	}
+	if inFence {
+		if !strings.HasSuffix(md, "\n") {
+			md += "\n"
+		}
+		return md + fence + "\n"
+	}
+
+	// inline constructs can only be open in the last paragraph
+	if afterFence > len(md) {
+		return md
+	}
+	last := md[afterFence:]
+	if i := strings.LastIndex(last, "\n\n"); i >= 0 {
+		last = last[i:]
+	}
+	// nothing else is parsed in open math and code spans
+	if strings.Count(last, "$$")%2 == 1 {
+		return md + "$$"
+	}
+	if strings.Count(last, "`")%2 == 1 {
+		return md + "`"
+	}
+	var closing string
+	// markers inside code spans don't count
+	last = withoutCodeSpans(last)
+	for _, marker := range []string{"**", "__", "~~"} {
+		if strings.Count(last, marker)%2 == 1 {
+			closing = marker + closing
+		}
+	}
+	if closing == "" {
+		return md
+	}
+	return strings.TrimRight(md, " \t\n") + closing
+}
+
+// withoutCodeSpans removes the code spans from s.
+func withoutCodeSpans(s string) string {
+	parts := strings.Split(s, "`")
+	var b strings.Builder
+	for i := 0; i < len(parts); i += 2 {
+		b.WriteString(parts[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,99 @@
+package contract
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/eternalsad/markdownify/ast"
+	"github.com/eternalsad/markdownify/md2"
+)
+
+func TestClosePending(t *testing.T) {
+	var tests = []string{
+		"plain text", "plain text",
+		"```go\nfunc main() {", "```go\nfunc main() {\n```\n",
+		"~~~\ncode\n", "~~~\ncode\n~~~\n",
+		"some **bold", "some **bold**",
+		"some **bold ~~strike ", "some **bold ~~strike~~**",
+		"`code **", "`code **`",
+		"**done** and `x`", "**done** and `x`",
+		"$$x^2", "$$x^2$$",
+		"```\na `b\n```\nafter", "```\na `b\n```\nafter",
+	}
+	for i := 0; i+1 < len(tests); i += 2 {
+		if got := closePending(tests[i]); got != tests[i+1] {
+			t.Errorf("closePending(%q) = %q, expected %q", tests[i], got, tests[i+1])
+		}
+	}
+}
+
+func TestStreamStable(t *testing.T) {
+	s := NewStream()
+	s.WriteString("# Title\n\nFirst paragraph.\n\n- a\n\n- b\n")
+	s.Snapshot()
+	// the list may still get more items
+	if expected := len("# Title\n\nFirst paragraph.\n\n"); s.stable != expected {
+		t.Errorf("stable = %d, expected %d", s.stable, expected)
+	}
+	s.WriteString("\nLast")
+	got := s.Snapshot()
+	if expected := ConvertMD2(s.Text()); got != expected {
+		t.Errorf("\nExpected[%#v]\nGot     [%#v]\n", expected, got)
+	}
+}
+
+func TestStreamNoPanic(t *testing.T) {
+	s := NewStream()
+	s.WriteString("line one  \nline two\n\nH~2~O ")
+	if got := s.Snapshot(); got != "line one\nline two\n\nH\\~2\\~O\n\n" {
+		t.Errorf("snapshot = %q", got)
+	}
+
+	// a renderer that fails falls back to plain text
+	s = NewStreamWithOptions(md2.RendererOptions{
+		RenderNodeHook: func(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+			panic("boom")
+		},
+	})
+	s.WriteString("**a.b**")
+	if got := s.Snapshot(); got != "\\*\\*a\\.b\\*\\*" {
+		t.Errorf("snapshot = %q", got)
+	}
+}
+
+func TestStreamSamples(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "cmd", "bot", "tests", "*.md"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no samples: %v", err)
+	}
+	for _, file := range files {
+		d, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := NewStream()
+		for i := 0; i < len(d); i += 13 {
+			end := i + 13
+			if end > len(d) {
+				end = len(d)
+			}
+			s.Write(d[i:end])
+			snapshot := s.Snapshot()
+			fences := 0
+			for _, line := range strings.Split(snapshot, "\n") {
+				if strings.HasPrefix(line, "```") {
+					fences++
+				}
+			}
+			if fences%2 != 0 {
+				t.Fatalf("%s: unbalanced code block after %d bytes:\n%s", file, end, snapshot)
+			}
+		}
+		if got, expected := s.Snapshot(), ConvertMD2(string(d)); got != expected {
+			t.Errorf("%s: final snapshot differs from ConvertMD2\nExpected[%#v]\nGot     [%#v]\n", file, expected, got)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package contract
+
+import (
+	"github.com/eternalsad/markdownify"
+	"github.com/eternalsad/markdownify/whatsapp"
+)
+
+// ConvertWhatsApp converts regular Markdown to WhatsApp's text formatting.
+// The input is normalized with all rules first, see Normalize.
+func ConvertWhatsApp(md string) string {
+	return string(convertWhatsApp(md, Options{Normalize: NormalizeAll}))
+}
+
+// ConvertWhatsAppWithOptions converts regular Markdown to WhatsApp's text
+// formatting. opts.Renderer is not used, the renderer is configured by
+// opts.WhatsApp.
+func ConvertWhatsAppWithOptions(md string, opts Options) (string, error) {
+	return safely(md, opts, func() []byte {
+		return convertWhatsApp(md, opts)
+	})
+}
+
+func convertWhatsApp(md string, opts Options) []byte {
+	doc := parse(md, opts)
+	opts.WhatsApp.RawMath = opts.WhatsApp.RawMath || opts.LaTeX == LaTeXRaw
+	return markdown.Render(doc, whatsapp.NewRenderer(opts.WhatsApp))
+}
@@ -0,0 +1,399 @@
+// Package discord renders a Markdown AST to the Markdown flavour of Discord
+// messages.
+//
+// Discord has no tables, which are written as code blocks, and only three
+// levels of headings. Spoilers (<tg-spoiler> or <spoiler> in the source)
+// become ||spoiler|| and paragraphs that start with <small> or <sub> become
+// -# subtext. Messages are limited to MessageLimit characters,
+// contract.ConvertDiscordMessages splits longer text.
+package discord
+
+import (
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/eternalsad/markdownify/ast"
+	"github.com/eternalsad/markdownify/internal/plain"
+	"github.com/eternalsad/markdownify/parser/latex"
+)
+
+// Default styling used for the blank fields of RendererOptions.
+var (
+	// DefaultListIndent is written for each level of list nesting.
+	DefaultListIndent = "  "
+	// DefaultHorizontalRule is the separator line written for thematic breaks.
+	DefaultHorizontalRule = "──────────"
+	// DefaultTaskUnchecked and DefaultTaskChecked mark task list items.
+	DefaultTaskUnchecked = "☐"
+	DefaultTaskChecked   = "☑"
+)
+
+// MessageLimit is the maximum length of a Discord message.
+const MessageLimit = 2000
+
+// MaxHeadingLevel is the deepest heading Discord shows, deeper headings are
+// written at this level.
+const MaxHeadingLevel = 3
+
+// RenderNodeFunc allows reusing most of Renderer logic and replacing
+// rendering of some nodes. If it returns false, Renderer.RenderNode
+// will execute its logic. If it returns true, Renderer.RenderNode will
+// skip rendering this node and will return WalkStatus
+type RenderNodeFunc func(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool)
+
+// RendererOptions is a collection of supplementary parameters tweaking
+// the behavior of various parts of the Discord renderer.
+// Blank fields are replaced by the matching Default* value.
+type RendererOptions struct {
+	// ListIndent is written for each level of list nesting.
+	ListIndent string
+
+	// HorizontalRule is the separator line written for thematic breaks.
+	HorizontalRule string
+
+	// TaskUnchecked and TaskChecked are written after the bullet of task
+	// list items.
+	TaskUnchecked string
+	TaskChecked   string
+
+	// SuppressEmbeds wraps URLs in <>, so that Discord doesn't show a
+	// preview of the linked page.
+	SuppressEmbeds bool
+
+	// RawMath renders formulas as written instead of replacing LaTeX
+	// commands with Unicode symbols.
+	RawMath bool
+
+	// if set, called at the start of RenderNode(). Allows replacing
+	// rendering of some nodes
+	RenderNodeHook RenderNodeFunc
+}
+
+// Renderer renders to Discord markdown.
+//
+// Do not create this directly, instead use the NewRenderer function.
+type Renderer struct {
+	Opts RendererOptions
+
+	base  *plain.Renderer
+	latex *latex.LaTeXToMarkdownV2
+	// inline HTML tags left open, innermost last
+	spans []openSpan
+}
+
+// openSpan is an open inline HTML tag and the marker that closes it.
+type openSpan struct {
+	name   string
+	marker string
+}
+
+// NewRenderer returns a Discord markdown renderer.
+func NewRenderer(opts RendererOptions) *Renderer {
+	if opts.ListIndent == "" {
+		opts.ListIndent = DefaultListIndent
+	}
+	if opts.HorizontalRule == "" {
+		opts.HorizontalRule = DefaultHorizontalRule
+	}
+	if opts.TaskUnchecked == "" {
+		opts.TaskUnchecked = DefaultTaskUnchecked
+	}
+	if opts.TaskChecked == "" {
+		opts.TaskChecked = DefaultTaskChecked
+	}
+	r := &Renderer{
+		Opts:  opts,
+		latex: latex.NewLaTeXToMarkdownV2(),
+	}
+	markers := plain.Markers{
+		Bold:          "**",
+		Bullets:       []string{"-"},
+		ListIndent:    opts.ListIndent,
+		TaskUnchecked: opts.TaskUnchecked,
+		TaskChecked:   opts.TaskChecked,
+	}
+	r.base = plain.New(markers, escape, r.RenderNode)
+	return r
+}
+
+func (r *Renderer) outs(w io.Writer, s string) {
+	r.base.Outs(w, s)
+}
+
+// escape escapes the characters of text that Discord would read as
+// formatting. lineStart tells if text starts a line, where headings, lists
+// and quotes start.
+func escape(text string, lineStart bool) string {
+	var b strings.Builder
+	if lineStart {
+		trimmed := strings.TrimLeft(text, " ")
+		b.WriteString(text[:len(text)-len(trimmed)])
+		text = trimmed
+		digits := 0
+		for digits < len(text) && text[digits] >= '0' && text[digits] <= '9' {
+			digits++
+		}
+		switch {
+		case text != "" && strings.IndexByte("#->", text[0]) >= 0:
+			b.WriteString(`\`)
+		case digits > 0 && digits < len(text) && text[digits] == '.':
+			b.WriteString(text[:digits])
+			b.WriteString(`\`)
+			text = text[digits:]
+		}
+	}
+	for i := 0; i < len(text); i++ {
+		if strings.IndexByte("\\*_~`|[]<", text[i]) >= 0 {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(text[i])
+	}
+	// keep @everyone and @here from notifying the whole server
+	s := strings.ReplaceAll(b.String(), "@everyone", "@\u200beveryone")
+	return strings.ReplaceAll(s, "@here", "@\u200bhere")
+}
+
+func (r *Renderer) heading(w io.Writer, node *ast.Heading, entering bool) {
+	if !entering {
+		// tags left open don't leak out of the heading
+		r.closeSpans(w, 0)
+		r.outs(w, "\n\n")
+		return
+	}
+	level := node.Level
+	if level > MaxHeadingLevel {
+		level = MaxHeadingLevel
+	}
+	r.outs(w, strings.Repeat("#", level)+" ")
+}
+
+// url returns dest to be written as a link target.
+func (r *Renderer) url(dest []byte) string {
+	url := strings.NewReplacer("(", "%28", ")", "%29", " ", "%20").Replace(string(dest))
+	if r.Opts.SuppressEmbeds {
+		return "<" + url + ">"
+	}
+	return url
+}
+
+func (r *Renderer) link(w io.Writer, dest []byte, node ast.Node) {
+	text := strings.TrimSpace(r.base.RenderChildren(node))
+	if text == "" || text == escape(string(dest), false) {
+		r.outs(w, r.url(dest))
+		return
+	}
+	r.outs(w, "["+text+"]("+r.url(dest)+")")
+}
+
+func (r *Renderer) code(w io.Writer, literal string) {
+	if literal == "" {
+		return
+	}
+	// code can't be escaped, double backticks allow single ones inside
+	delim := "`"
+	if strings.Contains(literal, "`") {
+		delim = "``"
+		literal = strings.ReplaceAll(literal, "``", "`\u200b`")
+		literal = " " + literal + " "
+	}
+	r.outs(w, delim+literal+delim)
+}
+
+func (r *Renderer) codeBlock(w io.Writer, lang string, literal string) {
+	literal = strings.TrimSuffix(literal, "\n")
+	// a fence inside the code would end the block
+	literal = strings.ReplaceAll(literal, "```", "``\u200b`")
+	if fields := strings.Fields(lang); len(fields) > 0 {
+		lang = fields[0]
+	}
+	r.outs(w, r.base.ListIndent())
+	r.outs(w, "```"+lang+"\n"+literal+"\n```\n")
+	if r.base.ListDepth() == 0 {
+		r.outs(w, "\n")
+	}
+}
+
+func (r *Renderer) blockQuote(w io.Writer, node *ast.BlockQuote) {
+	// Discord quotes can't be nested, inner quotes are part of the outer one
+	text := strings.Trim(r.base.RenderChildren(node), "\n")
+	text = strings.ReplaceAll(text, "\n> ", "\n")
+	for _, line := range strings.Split(text, "\n") {
+		if line == "" {
+			r.outs(w, ">\n")
+		} else {
+			r.outs(w, "> "+strings.TrimPrefix(line, "> ")+"\n")
+		}
+	}
+	r.outs(w, "\n")
+}
+
+// mathText returns the text of a formula as it is shown, see
+// RendererOptions.RawMath.
+func (r *Renderer) mathText(literal []byte) string {
+	if r.Opts.RawMath {
+		return string(literal)
+	}
+	return r.latex.ToUnicode(string(literal))
+}
+
+// htmlNameRe extracts the name of a tag.
+var htmlNameRe = regexp.MustCompile(`^</?([a-zA-Z][a-zA-Z0-9-]*)`)
+
+// htmlSpan writes the Discord formatting for the inline HTML tags that have
+// one, other tags are dropped. A closing tag also closes the tags left open
+// inside of it, stray closing tags are dropped.
+func (r *Renderer) htmlSpan(w io.Writer, node *ast.HTMLSpan) {
+	tag := string(node.Literal)
+	m := htmlNameRe.FindStringSubmatch(tag)
+	if m == nil {
+		return
+	}
+	name := strings.ToLower(m[1])
+	if strings.HasPrefix(tag, "</") {
+		i := len(r.spans) - 1
+		for i >= 0 && r.spans[i].name != name {
+			i--
+		}
+		if i >= 0 {
+			r.closeSpans(w, i)
+		}
+		return
+	}
+	marker := ""
+	switch name {
+	case "tg-spoiler", "spoiler":
+		marker = "||"
+	case "u", "ins":
+		marker = "__"
+	case "b", "strong":
+		marker = "**"
+	case "i", "em":
+		marker = "*"
+	case "s", "del", "strike":
+		marker = "~~"
+	case "code":
+		marker = "`"
+	case "br":
+		r.outs(w, "\n")
+		return
+	case "small", "sub":
+		// subtext is a whole line
+		if r.base.LineStart() && r.base.ListDepth() == 0 {
+			r.outs(w, "-# ")
+		}
+		return
+	case "span":
+		if strings.Contains(tag, "spoiler") {
+			marker = "||"
+		}
+	default:
+		return
+	}
+	r.outs(w, marker)
+	r.spans = append(r.spans, openSpan{name: name, marker: marker})
+}
+
+// closeSpans closes the inline HTML tags opened since the n-th open one.
+func (r *Renderer) closeSpans(w io.Writer, n int) {
+	for len(r.spans) > n {
+		r.outs(w, r.spans[len(r.spans)-1].marker)
+		r.spans = r.spans[:len(r.spans)-1]
+	}
+}
+
+// table writes the table as a code block with aligned columns.
+func (r *Renderer) table(w io.Writer, node *ast.Table) {
+	if text := plain.Table(node); text != "" {
+		r.codeBlock(w, "", text)
+	}
+}
+
+// RenderNode renders a markdown node to Discord markdown.
+func (r *Renderer) RenderNode(w io.Writer, node ast.Node, entering bool) ast.WalkStatus {
+	if r.Opts.RenderNodeHook != nil {
+		status, didHandle := r.Opts.RenderNodeHook(w, node, entering)
+		if didHandle {
+			return status
+		}
+	}
+	switch node := node.(type) {
+	case *ast.Text:
+		r.base.Text(w, string(node.Literal))
+	case *ast.Softbreak:
+		r.outs(w, " ")
+	case *ast.Hardbreak:
+		r.outs(w, "\n")
+	case *ast.NonBlockingSpace:
+		r.outs(w, " ")
+	case *ast.Emph:
+		r.outs(w, "*")
+	case *ast.Strong:
+		r.base.Bold(w, entering)
+	case *ast.Del:
+		r.outs(w, "~~")
+	case *ast.BlockQuote:
+		if entering {
+			r.blockQuote(w, node)
+		}
+		return ast.SkipChildren
+	case *ast.Link:
+		if entering {
+			r.link(w, node.Destination, node)
+		}
+		return ast.SkipChildren
+	case *ast.Image:
+		// Discord shows a preview of images by their URL
+		if entering {
+			r.outs(w, string(node.Destination))
+		}
+		return ast.SkipChildren
+	case *ast.Code:
+		r.code(w, string(node.Literal))
+	case *ast.CodeBlock:
+		r.codeBlock(w, string(node.Info), string(node.Literal))
+	case *ast.Paragraph:
+		if !entering {
+			// tags left open don't leak out of the paragraph
+			r.closeSpans(w, 0)
+		}
+		r.base.Para(w, node, entering)
+	case *ast.HTMLSpan:
+		r.htmlSpan(w, node)
+	case *ast.HTMLBlock:
+		r.base.HTMLBlock(w, node)
+	case *ast.Heading:
+		r.heading(w, node, entering)
+	case *ast.HorizontalRule:
+		r.outs(w, r.Opts.HorizontalRule+"\n\n")
+	case *ast.List:
+		r.base.List(w, node, entering)
+	case *ast.ListItem:
+		r.base.ListItem(w, node, entering)
+	case *ast.Table:
+		if entering {
+			r.table(w, node)
+		}
+		return ast.SkipChildren
+	case *ast.Math:
+		r.code(w, r.mathText(node.Literal))
+	case *ast.MathBlock:
+		if entering {
+			r.codeBlock(w, "", strings.TrimSpace(r.mathText(node.Literal)))
+		}
+	default:
+		// the other nodes have no Discord equivalent, their children are
+		// rendered as they are
+	}
+	return ast.GoToNext
+}
+
+// RenderHeader renders header
+func (r *Renderer) RenderHeader(w io.Writer, ast ast.Node) {
+	// do nothing
+}
+
+// RenderFooter renders footer
+func (r *Renderer) RenderFooter(w io.Writer, ast ast.Node) {
+	r.closeSpans(w, 0)
+}
@@ -0,0 +1,62 @@
+package discord
+
+import (
+	"testing"
+
+	"github.com/eternalsad/markdownify/internal/rendertest"
+)
+
+func testRendering(t *testing.T, source string, expected string) {
+	t.Helper()
+	rendertest.Expect(t, source, expected, rendertest.Render(NewRenderer(RendererOptions{}), source))
+}
+
+func TestRenderEscaping(t *testing.T) {
+	testRendering(t, "a\\_b 2\\*3 \\[x\\] a|b a < b\n", "a\\_b 2\\*3 \\[x\\] a\\|b a \\< b\n\n")
+	testRendering(t, "\\# not a heading\n", "\\# not a heading\n\n")
+	testRendering(t, "hi @everyone\n", "hi @\u200beveryone\n\n")
+}
+
+func TestRenderInline(t *testing.T) {
+	testRendering(t, "**bold** *italic* ~~strike~~ `code`\n", "**bold** *italic* ~~strike~~ `code`\n\n")
+	testRendering(t, "``a ` b``\n", "`` a ` b ``\n\n")
+	testRendering(t, "<u>under</u> <tg-spoiler>secret</tg-spoiler>\n", "__under__ ||secret||\n\n")
+}
+
+func TestRenderUnclosedHTML(t *testing.T) {
+	testRendering(t, "<b>x\n", "**x**\n\n")
+	testRendering(t, "# <i>a\n\nb</i>\n", "# *a*\n\nb\n\n")
+	testRendering(t, "<b><i>a</b> b</i>\n", "***a*** b\n\n")
+}
+
+func TestRenderHeadings(t *testing.T) {
+	testRendering(t, "# One\n\n#### Four\n", "# One\n\n### Four\n\n")
+}
+
+func TestRenderSubtext(t *testing.T) {
+	testRendering(t, "<small>fine print</small>\n", "-# fine print\n\n")
+}
+
+func TestRenderLinks(t *testing.T) {
+	testRendering(t, "[docs](https://go.dev/doc)\n", "[docs](https://go.dev/doc)\n\n")
+	testRendering(t, "see https://go.dev\n", "see https://go.dev\n\n")
+
+	r := NewRenderer(RendererOptions{SuppressEmbeds: true})
+	if got, expected := rendertest.Render(r, "[docs](https://go.dev/doc)\n"), "[docs](<https://go.dev/doc>)\n\n"; got != expected {
+		t.Errorf("\nExpected[%#v]\nGot     [%#v]\n", expected, got)
+	}
+}
+
+func TestRenderBlockQuote(t *testing.T) {
+	testRendering(t, "> first\n>\n> > nested\n", "> first\n>\n> nested\n\n")
+}
+
+func TestRenderCodeBlock(t *testing.T) {
+	testRendering(t, "~~~go\nx := \"```\"\n~~~\n", "```go\nx := \"``\u200b`\"\n```\n\n")
+}
+
+func TestRenderTable(t *testing.T) {
+	source := "| Name | Year |\n|---|---|\n| Go | 2009 |\n"
+	expected := "```\nName | Year\n-----+-----\nGo   | 2009\n```\n\n"
+	testRendering(t, source, expected)
+}
@@ -0,0 +1,499 @@
+// Package entities converts Telegram messages, which arrive as plain text
+// with a list of formatting entities, to a Markdown AST.
+//
+// The resulting document can be rendered back to Markdown with the md
+// renderer:
+//
+//	doc := entities.Parse(msg.Text, msg.Entities)
+//	out := markdown.Render(doc, md.NewRenderer())
+//
+// Render goes the other way, from a document to plain text with entities.
+// Such messages can't be rejected for their markup, which makes them a
+// fallback when MarkdownV2 text is refused by the Bot API. HTML formats
+// text with entities for the HTML parse mode of the Bot API.
+package entities
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/eternalsad/markdownify/ast"
+)
+
+// MessageEntity is a special entity in the text of a message, as described
+// in https://core.telegram.org/bots/api#messageentity. Offset and Length are
+// in UTF-16 code units.
+type MessageEntity struct {
+	Type     string `json:"type"`
+	Offset   int    `json:"offset"`
+	Length   int    `json:"length"`
+	URL      string `json:"url,omitempty"`      // for "text_link"
+	User     *User  `json:"user,omitempty"`     // for "text_mention"
+	Language string `json:"language,omitempty"` // for "pre"
+}
+
+// User is the user mentioned by a "text_mention" entity.
+type User struct {
+	ID int64 `json:"id"`
+}
+
+// span is an entity with byte offsets into the text.
+type span struct {
+	MessageEntity
+	start, end int
+	// position in the entity list, keeps the order of equal entities stable
+	order int
+}
+
+// htmlTags are the tags used for entities that have no Markdown syntax, for
+// bold and italic inside each other, and for the continuation of entities
+// that had to be split because they overlap other entities. Delimiters of
+// the split parts would run into each other.
+var htmlTags = map[string]string{
+	"bold":          "b",
+	"italic":        "i",
+	"strikethrough": "s",
+	"underline":     "u",
+	"spoiler":       "tg-spoiler",
+}
+
+// Parse returns the document for text formatted with entities. Entities
+// may be nested and may overlap. Unknown entities, and entities that don't
+// change how the text looks (mentions, hashtags and the like), are ignored.
+func Parse(text string, entities []MessageEntity) *ast.Document {
+	doc := &ast.Document{}
+	b := &builder{text: text}
+	b.blocks(doc, 0, len(text), toSpans(text, entities))
+	return doc
+}
+
+// toSpans converts the UTF-16 offsets of entities to byte offsets into text.
+// Entities that are empty or outside of text are dropped.
+func toSpans(text string, entities []MessageEntity) []span {
+	// byte offset of every UTF-16 code unit, and of the end of text
+	offsets := make([]int, 0, len(text)+1)
+	for i, r := range text {
+		offsets = append(offsets, i)
+		if r >= 0x10000 {
+			// the second half of a surrogate pair
+			offsets = append(offsets, i)
+		}
+	}
+	offsets = append(offsets, len(text))
+	byteOffset := func(units int) int {
+		if units < 0 {
+			return 0
+		}
+		if units >= len(offsets) {
+			return len(text)
+		}
+		return offsets[units]
+	}
+
+	var spans []span
+	for i, e := range entities {
+		if !isFormatting(e.Type) {
+			continue
+		}
+		s := span{
+			MessageEntity: e,
+			start:         byteOffset(e.Offset),
+			end:           byteOffset(e.Offset + e.Length),
+			order:         i,
+		}
+		if s.start < s.end {
+			spans = append(spans, s)
+		}
+	}
+	sortSpans(spans)
+	return spans
+}
+
+func isFormatting(typ string) bool {
+	switch typ {
+	case "bold", "italic", "underline", "strikethrough", "spoiler", "code", "pre",
+		"blockquote", "expandable_blockquote", "text_link", "text_mention", "url", "email":
+		return true
+	}
+	return false
+}
+
+func isBlock(s *span) bool {
+	return s.Type == "pre" || s.Type == "blockquote" || s.Type == "expandable_blockquote"
+}
+
+// sortSpans sorts spans by start, longer ones first.
+func sortSpans(spans []span) {
+	sort.SliceStable(spans, func(i, j int) bool {
+		if spans[i].start != spans[j].start {
+			return spans[i].start < spans[j].start
+		}
+		if spans[i].end != spans[j].end {
+			return spans[i].end > spans[j].end
+		}
+		return spans[i].order < spans[j].order
+	})
+}
+
+// clip returns the parts of spans that are inside [start, end).
+func clip(spans []span, start, end int) []span {
+	var res []span
+	for _, s := range spans {
+		if s.start < start {
+			s.start = start
+		}
+		if s.end > end {
+			s.end = end
+		}
+		if s.start < s.end {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+type builder struct {
+	text string
+	// start and end of the paragraph being built
+	paraStart, paraEnd int
+}
+
+// open is an entity whose node is being filled.
+type open struct {
+	span *span
+	// children of the entity are added to node
+	node ast.Node
+	// written after the children, for entities rendered as HTML
+	closing string
+}
+
+// blocks adds the blocks for text[start:end] to parent.
+func (b *builder) blocks(parent ast.Node, start, end int, spans []span) {
+	pos := start
+	for {
+		inside := clip(spans, pos, end)
+		var block *span
+		for i := range inside {
+			if isBlock(&inside[i]) {
+				block = &inside[i]
+				break
+			}
+		}
+		if block == nil {
+			b.paragraphs(parent, pos, end, inside)
+			return
+		}
+		b.paragraphs(parent, pos, block.start, clip(inside, pos, block.start))
+
+		if block.Type == "pre" {
+			literal := b.text[block.start:block.end]
+			if !strings.HasSuffix(literal, "\n") {
+				literal += "\n"
+			}
+			ast.AppendChild(parent, &ast.CodeBlock{
+				Leaf:     ast.Leaf{Literal: []byte(literal)},
+				IsFenced: true,
+				Info:     []byte(block.Language),
+			})
+		} else {
+			quote := &ast.BlockQuote{}
+			ast.AppendChild(parent, quote)
+			var nested []span
+			for _, s := range clip(inside, block.start, block.end) {
+				if s.order != block.order {
+					nested = append(nested, s)
+				}
+			}
+			b.blocks(quote, block.start, block.end, nested)
+		}
+		pos = block.end
+	}
+}
+
+// paragraphs adds a paragraph for each part of text[start:end] separated by
+// empty lines.
+func (b *builder) paragraphs(parent ast.Node, start, end int, spans []span) {
+	for start < end {
+		stop := strings.Index(b.text[start:end], "\n\n")
+		next := end
+		if stop < 0 {
+			stop = end
+		} else {
+			stop += start
+			next = stop
+		}
+		// skip the newlines around the paragraph
+		for start < stop && b.text[start] == '\n' {
+			start++
+		}
+		for stop > start && b.text[stop-1] == '\n' {
+			stop--
+		}
+		for next < end && b.text[next] == '\n' {
+			next++
+		}
+		if strings.TrimSpace(b.text[start:stop]) != "" {
+			para := &ast.Paragraph{}
+			ast.AppendChild(parent, para)
+			b.paraStart, b.paraEnd = start, stop
+			b.inline(para, start, stop, clip(spans, start, stop))
+		}
+		start = next
+	}
+}
+
+// inline adds the text and the inline entities of text[start:end] to
+// parent. Entities that overlap are split so that they nest.
+func (b *builder) inline(parent ast.Node, start, end int, spans []span) {
+	spans = b.aroundCode(spans)
+
+	bounds := []int{start, end}
+	for _, s := range spans {
+		bounds = append(bounds, s.start, s.end)
+	}
+	sort.Ints(bounds)
+
+	var stack []open
+	top := func() open {
+		if len(stack) == 0 {
+			return open{node: parent}
+		}
+		return stack[len(stack)-1]
+	}
+	closeFrom := func(i int) {
+		for j := len(stack) - 1; j >= i; j-- {
+			if stack[j].closing != "" {
+				ast.AppendChild(stack[j].node, htmlSpan(stack[j].closing))
+			}
+		}
+		stack = stack[:i]
+	}
+	opened := map[int]bool{}
+
+	for i := 0; i+1 < len(bounds); i++ {
+		from, to := bounds[i], bounds[i+1]
+		if from == to {
+			continue
+		}
+		active := func(s *span) bool {
+			return s.start <= from && s.end >= to
+		}
+
+		// close the entities that ended, and the ones opened inside them
+		for j := range stack {
+			if !active(stack[j].span) {
+				closeFrom(j)
+				break
+			}
+		}
+
+		// open the entities that cover this part, the ones that go on
+		// longer outside
+		var starting []*span
+		for k := range spans {
+			s := &spans[k]
+			if active(s) && !onStack(stack, s) {
+				starting = append(starting, s)
+			}
+		}
+		sort.SliceStable(starting, func(i, j int) bool {
+			if starting[i].end != starting[j].end {
+				return starting[i].end > starting[j].end
+			}
+			// code can't contain anything
+			return starting[i].Type != "code" && starting[j].Type == "code"
+		})
+		for _, s := range starting {
+			stack = append(stack, b.open(top().node, s, opened[s.order]))
+			opened[s.order] = true
+		}
+
+		if top().span != nil && top().span.Type == "code" {
+			// the literal was added when the code was opened
+			continue
+		}
+		b.addText(top().node, from, to)
+	}
+	closeFrom(0)
+}
+
+// aroundCode moves the bounds of entities that start or end inside code to
+// the end of the code, as code can't contain formatting.
+func (b *builder) aroundCode(spans []span) []span {
+	for _, c := range spans {
+		if c.Type != "code" {
+			continue
+		}
+		for i := range spans {
+			s := &spans[i]
+			if s.order == c.order {
+				continue
+			}
+			if s.start > c.start && s.start < c.end {
+				s.start = c.end
+			}
+			if s.end > c.start && s.end < c.end {
+				s.end = c.end
+			}
+		}
+	}
+	var res []span
+	for _, s := range spans {
+		if s.start < s.end {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+func onStack(stack []open, s *span) bool {
+	for _, o := range stack {
+		if o.span == s {
+			return true
+		}
+	}
+	return false
+}
+
+// open adds the node for s to parent. split is set for the continuation of
+// an entity that was split.
+func (b *builder) open(parent ast.Node, s *span, split bool) open {
+	o := open{span: s, node: parent}
+	var node ast.Node
+	switch s.Type {
+	case "bold":
+		node = &ast.Strong{}
+	case "italic":
+		node = &ast.Emph{}
+	case "strikethrough":
+		node = &ast.Del{}
+	case "code":
+		ast.AppendChild(parent, &ast.Code{Leaf: ast.Leaf{Literal: []byte(b.text[s.start:s.end])}})
+		return o
+	case "text_link":
+		node = &ast.Link{Destination: []byte(s.URL)}
+	case "text_mention":
+		var id int64
+		if s.User != nil {
+			id = s.User.ID
+		}
+		node = &ast.Link{Destination: []byte(fmt.Sprintf("tg://user?id=%d", id))}
+	case "url":
+		url := b.text[s.start:s.end]
+		if !strings.Contains(url, "://") {
+			url = "http://" + url
+		}
+		node = &ast.Link{Destination: []byte(url)}
+	case "email":
+		node = &ast.Link{Destination: []byte("mailto:" + b.text[s.start:s.end])}
+	}
+
+	tag, isHTML := htmlTags[s.Type]
+	if isHTML && (node == nil || split || (s.Type == "bold" || s.Type == "italic") && inEmphasis(parent)) {
+		ast.AppendChild(parent, htmlSpan("<"+tag+">"))
+		o.closing = "</" + tag + ">"
+		return o
+	}
+	ast.AppendChild(parent, node)
+	o.node = node
+	return o
+}
+
+// inEmphasis reports whether node is inside bold or italic text. Their
+// delimiters are all asterisks, nested ones run into each other.
+func inEmphasis(node ast.Node) bool {
+	for ; node != nil; node = node.GetParent() {
+		switch node.(type) {
+		case *ast.Strong, *ast.Emph:
+			return true
+		}
+	}
+	return false
+}
+
+func htmlSpan(s string) *ast.HTMLSpan {
+	return &ast.HTMLSpan{Leaf: ast.Leaf{Literal: []byte(s)}}
+}
+
+// addText adds text[start:end] to parent. Line breaks become hard breaks.
+func (b *builder) addText(parent ast.Node, start, end int) {
+	lines := strings.Split(b.text[start:end], "\n")
+	for i, line := range lines {
+		lineStart := i > 0 || start == b.paraStart || b.text[start-1] == '\n'
+		lineEnd := i < len(lines)-1 || end == b.paraEnd || b.text[end] == '\n'
+		if i > 0 {
+			ast.AppendChild(parent, &ast.Hardbreak{})
+		}
+		addLine(parent, line, lineStart, lineEnd)
+	}
+}
+
+// addLine adds a line of text to parent. Characters that would be read as
+// Markdown get text nodes of their own, which the md renderer escapes.
+// Spaces the renderer or parser would drop, at the ends of the line and
+// in runs, are written as &#32;, and so is = at the start of a line,
+// which the parser doesn't unescape.
+func addLine(parent ast.Node, line string, lineStart, lineEnd bool) {
+	var plain strings.Builder
+	flush := func() {
+		if plain.Len() > 0 {
+			ast.AppendChild(parent, text(plain.String()))
+			plain.Reset()
+		}
+	}
+	special := func(s string) {
+		flush()
+		ast.AppendChild(parent, text(s))
+	}
+	entity := func(s string) {
+		flush()
+		ast.AppendChild(parent, htmlSpan(s))
+	}
+
+	i := 0
+	if lineStart {
+		for i < len(line) && line[i] == ' ' {
+			entity("&#32;")
+			i++
+		}
+		digits := i
+		for digits < len(line) && line[digits] >= '0' && line[digits] <= '9' {
+			digits++
+		}
+		switch {
+		case i < len(line) && strings.IndexByte("#-+>", line[i]) >= 0:
+			// headings, lists and quotes
+			special(line[i : i+1])
+			i++
+		case i < len(line) && line[i] == '=':
+			// setext headings
+			entity("&#61;")
+			i++
+		case digits > i && digits < len(line) && (line[digits] == '.' || line[digits] == ')'):
+			// ordered lists, the md renderer escapes a period after a number
+			special(line[i:digits])
+			special(line[digits : digits+1])
+			i = digits + 1
+		}
+	}
+	trailing := len(line)
+	if lineEnd {
+		trailing = len(strings.TrimRight(line, " "))
+	}
+	for ; i < len(line); i++ {
+		switch {
+		case line[i] == ' ' && (i >= trailing || i > 0 && line[i-1] == ' '):
+			entity("&#32;")
+		case strings.IndexByte("\\`*_[]<>~&$", line[i]) >= 0:
+			special(line[i : i+1])
+		default:
+			plain.WriteByte(line[i])
+		}
+	}
+	flush()
+}
+
+func text(s string) *ast.Text {
+	return &ast.Text{Leaf: ast.Leaf{Literal: []byte(s)}}
+}
@@ -0,0 +1,116 @@
+package entities
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/eternalsad/markdownify"
+	"github.com/eternalsad/markdownify/internal/rendertest"
+	"github.com/eternalsad/markdownify/md"
+)
+
+func testConversion(t *testing.T, text string, ents []MessageEntity, expected string) {
+	t.Helper()
+	got := string(markdown.Render(Parse(text, ents), md.NewRenderer()))
+	if got != expected {
+		t.Errorf("\nInput   [%#v]\nExpected[%#v]\nGot     [%#v]\n", text, expected, got)
+	}
+}
+
+func TestParsePlain(t *testing.T) {
+	testConversion(t, "Hello, world", nil, "Hello, world\n\n")
+	testConversion(t, "one\ntwo\n\nthree", nil, "one\\\ntwo\n\nthree\n\n")
+	// text that would be read as Markdown is escaped
+	testConversion(t, "1. not a list\n# not a heading\n*a* [b] `c`", nil,
+		"1\\. not a list\\\n\\# not a heading\\\n\\*a\\* \\[b\\] \\`c\\`\n\n")
+}
+
+// testRoundTrip converts text to Markdown and back and expects the same
+// text and entities.
+func testRoundTrip(t *testing.T, text string, ents []MessageEntity) {
+	t.Helper()
+	source := markdown.Render(Parse(text, ents), md.NewRenderer())
+	gotText, gotEnts := Render(rendertest.Parse(string(source)), RendererOptions{})
+	if gotText != text || !reflect.DeepEqual(gotEnts, ents) {
+		t.Errorf("\nInput   [%#v %v]\nMarkdown[%#v]\nGot     [%#v %v]\n", text, ents, string(source), gotText, gotEnts)
+	}
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	for _, text := range []string{
+		"~~not strike~~ ~sub~",
+		"Title\n===",
+		"Title\n---",
+		"&amp; &copy; &#65; a & b",
+		"a    b",
+		"  indented\ntrailing  \n  both  ",
+		"$x$ and $$y$$",
+		"1. not a list\n# not a heading\n> not a quote",
+		"*a* [b](c) `d` <e> \\",
+	} {
+		testRoundTrip(t, text, nil)
+	}
+	testRoundTrip(t, "bold  ~text~ &amp;", []MessageEntity{{Type: "bold", Offset: 0, Length: 4}})
+}
+
+func TestParseNested(t *testing.T) {
+	ents := []MessageEntity{
+		{Type: "bold", Offset: 0, Length: 11},
+		{Type: "italic", Offset: 5, Length: 6},
+	}
+	testConversion(t, "bold italic", ents, "**bold <i>italic</i>**\n\n")
+}
+
+func TestParseOverlapping(t *testing.T) {
+	ents := []MessageEntity{
+		{Type: "bold", Offset: 0, Length: 7},
+		{Type: "italic", Offset: 5, Length: 11},
+	}
+	testConversion(t, "bold both italic", ents, "**bold <i>bo</i>**<i>th italic</i>\n\n")
+}
+
+func TestParseUTF16(t *testing.T) {
+	// the emoji takes two UTF-16 code units
+	ents := []MessageEntity{{Type: "bold", Offset: 3, Length: 4}}
+	testConversion(t, "😀 бold text", ents, "😀 **бold** text\n\n")
+}
+
+func TestParseCode(t *testing.T) {
+	ents := []MessageEntity{
+		{Type: "code", Offset: 4, Length: 6},
+		// ends inside the code
+		{Type: "bold", Offset: 0, Length: 6},
+	}
+	testConversion(t, "run `ls *` now", ents, "**run `` `ls *` ``** now\n\n")
+}
+
+func TestParsePre(t *testing.T) {
+	text := "Code:\nfmt.Println(1)\nDone"
+	ents := []MessageEntity{{Type: "pre", Offset: 6, Length: 14, Language: "go"}}
+	testConversion(t, text, ents, "Code:\n\n\n```go\nfmt.Println(1)\n```\n\nDone\n\n")
+}
+
+func TestParseLinks(t *testing.T) {
+	text := "docs at example.com, mail a@b.c or ask Bob"
+	ents := []MessageEntity{
+		{Type: "text_link", Offset: 0, Length: 4, URL: "https://go.dev/doc"},
+		{Type: "url", Offset: 8, Length: 11},
+		{Type: "email", Offset: 26, Length: 5},
+		{Type: "text_mention", Offset: 39, Length: 3, User: &User{ID: 42}},
+		{Type: "hashtag", Offset: 35, Length: 3},
+	}
+	expected := "[docs](https://go.dev/doc) at [example.com](http://example.com), " +
+		"mail [a@b.c](mailto:a@b.c) or ask [Bob](tg://user?id=42)\n\n"
+	testConversion(t, text, ents, expected)
+}
+
+func TestParseBlockquote(t *testing.T) {
+	text := "quoted text\nsecret\nafter"
+	ents := []MessageEntity{
+		{Type: "blockquote", Offset: 0, Length: 18},
+		{Type: "underline", Offset: 7, Length: 4},
+		{Type: "spoiler", Offset: 12, Length: 6},
+	}
+	expected := "> quoted <u>text</u>\\\n> <tg-spoiler>secret</tg-spoiler>\n\nafter\n\n"
+	testConversion(t, text, ents, expected)
+}
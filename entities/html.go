@@ -0,0 +1,117 @@
+package entities
+
+import (
+	"strconv"
+	"strings"
+)
+
+// textEscaper escapes the characters that the HTML parse mode of the Bot
+// API requires to be escaped in text.
+var textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// attrEscaper additionally escapes quotes for attribute values.
+var attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+// HTML returns text formatted with entities as HTML for the HTML parse
+// mode of the Bot API, see
+// https://core.telegram.org/bots/api#html-style. Overlapping entities are
+// split so that the tags nest. Entities inside code and pre are dropped,
+// the Bot API doesn't allow them there. Entities that don't change how the
+// text looks are dropped too, the Bot API detects URLs and mentions itself.
+func HTML(text string, entities []MessageEntity) string {
+	spans := toSpans(text, entities)
+	var b strings.Builder
+	// the entities whose tags are open
+	type openTag struct {
+		end          int
+		code         bool
+		tag, closing string
+	}
+	var stack []openTag
+
+	next := 0
+	for pos := 0; ; {
+		// close the entities ending at pos, and reopen the ones opened
+		// after them that go on
+		for {
+			first := -1
+			for i, t := range stack {
+				if t.end <= pos {
+					first = i
+					break
+				}
+			}
+			if first < 0 {
+				break
+			}
+			for i := len(stack) - 1; i >= first; i-- {
+				b.WriteString(stack[i].closing)
+			}
+			reopen := append([]openTag(nil), stack[first+1:]...)
+			stack = stack[:first]
+			for _, t := range reopen {
+				if t.end > pos {
+					b.WriteString(t.tag)
+					stack = append(stack, t)
+				}
+			}
+		}
+		for ; next < len(spans) && spans[next].start == pos; next++ {
+			s := &spans[next]
+			if len(stack) > 0 && stack[len(stack)-1].code {
+				continue
+			}
+			if tag, closing := htmlTag(s); tag != "" {
+				b.WriteString(tag)
+				stack = append(stack, openTag{
+					end:     s.end,
+					code:    s.Type == "code" || s.Type == "pre",
+					tag:     tag,
+					closing: closing,
+				})
+			}
+		}
+		if pos == len(text) {
+			break
+		}
+
+		end := len(text)
+		if next < len(spans) {
+			end = min(end, spans[next].start)
+		}
+		for _, t := range stack {
+			end = min(end, t.end)
+		}
+		textEscaper.WriteString(&b, text[pos:end])
+		pos = end
+	}
+	return b.String()
+}
+
+// htmlTag returns the opening and the closing tag for an entity, or empty
+// strings for entities without a tag.
+func htmlTag(s *span) (tag, closing string) {
+	if name, ok := htmlTags[s.Type]; ok {
+		return "<" + name + ">", "</" + name + ">"
+	}
+	switch s.Type {
+	case "code":
+		return "<code>", "</code>"
+	case "pre":
+		if s.Language != "" {
+			return `<pre><code class="language-` + attrEscaper.Replace(s.Language) + `">`, "</code></pre>"
+		}
+		return "<pre>", "</pre>"
+	case "blockquote":
+		return "<blockquote>", "</blockquote>"
+	case "expandable_blockquote":
+		return "<blockquote expandable>", "</blockquote>"
+	case "text_link":
+		return `<a href="` + attrEscaper.Replace(s.URL) + `">`, "</a>"
+	case "text_mention":
+		if s.User != nil {
+			return `<a href="tg://user?id=` + strconv.FormatInt(s.User.ID, 10) + `">`, "</a>"
+		}
+	}
+	return "", ""
+}
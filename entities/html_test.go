@@ -0,0 +1,42 @@
+package entities
+
+import "testing"
+
+func TestHTML(t *testing.T) {
+	tests := []struct {
+		text     string
+		entities []MessageEntity
+		expected string
+	}{
+		{"a < b & c", nil, "a &lt; b &amp; c"},
+		{"bold both code", []MessageEntity{
+			{Type: "bold", Offset: 0, Length: 9},
+			{Type: "italic", Offset: 5, Length: 4},
+			{Type: "code", Offset: 10, Length: 4},
+		}, "<b>bold <i>both</i></b> <code>code</code>"},
+		// the emoji takes two UTF-16 code units
+		{"😀 бold", []MessageEntity{{Type: "bold", Offset: 3, Length: 4}}, "😀 <b>бold</b>"},
+		{"link", []MessageEntity{{Type: "text_link", Offset: 0, Length: 4, URL: `https://example.com/?a=1&b="2"`}},
+			`<a href="https://example.com/?a=1&amp;b=&quot;2&quot;">link</a>`},
+		{"x := *p", []MessageEntity{
+			{Type: "pre", Offset: 0, Length: 7, Language: "go"},
+			{Type: "bold", Offset: 5, Length: 2},
+		}, `<pre><code class="language-go">x := *p</code></pre>`},
+		// overlapping entities are split
+		{"abcdef", []MessageEntity{
+			{Type: "bold", Offset: 0, Length: 4},
+			{Type: "italic", Offset: 2, Length: 4},
+		}, "<b>ab<i>cd</i></b><i>ef</i>"},
+		{"quote\nsecret", []MessageEntity{
+			{Type: "expandable_blockquote", Offset: 0, Length: 12},
+			{Type: "spoiler", Offset: 6, Length: 6},
+			{Type: "url", Offset: 0, Length: 5},
+		}, "<blockquote expandable>quote\n<tg-spoiler>secret</tg-spoiler></blockquote>"},
+		{"me", []MessageEntity{{Type: "text_mention", Offset: 0, Length: 2, User: &User{ID: 7}}}, `<a href="tg://user?id=7">me</a>`},
+	}
+	for _, test := range tests {
+		if got := HTML(test.text, test.entities); got != test.expected {
+			t.Errorf("%q: got %q, expected %q", test.text, got, test.expected)
+		}
+	}
+}
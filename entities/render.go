@@ -0,0 +1,539 @@
+package entities
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/eternalsad/markdownify/ast"
+	"github.com/eternalsad/markdownify/internal/plain"
+	"github.com/eternalsad/markdownify/parser/latex"
+)
+
+// Default styling used for the blank fields of RendererOptions.
+var (
+	// DefaultHorizontalRule is written for thematic breaks.
+	DefaultHorizontalRule = "──────────"
+	// DefaultTaskUnchecked and DefaultTaskChecked mark task list items.
+	DefaultTaskUnchecked = "☐"
+	DefaultTaskChecked   = "☑"
+)
+
+// RenderNodeFunc allows reusing most of Renderer logic and replacing
+// rendering of some nodes. If it returns false, Renderer.RenderNode
+// will execute its logic. If it returns true, Renderer.RenderNode will
+// skip rendering this node and will return WalkStatus
+type RenderNodeFunc func(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool)
+
+// RendererOptions is a collection of supplementary parameters tweaking
+// the behavior of the entities renderer.
+// Blank fields are replaced by the matching Default* value.
+type RendererOptions struct {
+	// HorizontalRule is written for thematic breaks.
+	HorizontalRule string
+
+	// TaskUnchecked and TaskChecked are written in front of the text of
+	// task list items.
+	TaskUnchecked string
+	TaskChecked   string
+
+	// RawMath shows formulas as written instead of replacing LaTeX
+	// commands with Unicode symbols.
+	RawMath bool
+
+	// if set, called at the start of RenderNode(). Allows replacing
+	// rendering of some nodes. Text written by the hook is not counted in
+	// the offsets of entities.
+	RenderNodeHook RenderNodeFunc
+}
+
+// Renderer renders a document to plain text and the entities formatting
+// it, the inverse of Parse. Unlike MarkdownV2 the result can't be rejected
+// by the Bot API for its markup. The entities are collected by the same
+// walk, get them with Entities.
+//
+// Do not create this directly, instead use the NewRenderer function.
+type Renderer struct {
+	Opts RendererOptions
+
+	entities []MessageEntity
+	// indexes of the entities that wait for the next text to get their
+	// offsets
+	unplaced []int
+	// stack of the open entities, indexes into entities or -1 for nodes
+	// that have no entity
+	open []int
+	// length of the text written so far in UTF-16 code units
+	length int
+	// newlines to write before the next text
+	pendingNewlines int
+	// depth of the block quotes being rendered, Telegram doesn't nest them
+	quoteDepth int
+	// stack of lists being rendered, innermost last
+	lists []*listLevel
+	// stack of HTML tags opened by HTML spans, innermost last
+	spans []openSpan
+
+	latex *latex.LaTeXToMarkdownV2
+}
+
+// listLevel is the rendering state of a single (possibly nested) list.
+type listLevel struct {
+	ordered bool
+	counter int
+}
+
+// openSpan is an HTML tag opened by an HTML span.
+type openSpan struct {
+	name   string
+	entity bool // an entity was opened for the tag
+}
+
+// NewRenderer returns an entities renderer.
+func NewRenderer(opts RendererOptions) *Renderer {
+	if opts.HorizontalRule == "" {
+		opts.HorizontalRule = DefaultHorizontalRule
+	}
+	if opts.TaskUnchecked == "" {
+		opts.TaskUnchecked = DefaultTaskUnchecked
+	}
+	if opts.TaskChecked == "" {
+		opts.TaskChecked = DefaultTaskChecked
+	}
+	return &Renderer{
+		Opts:  opts,
+		latex: latex.NewLaTeXToMarkdownV2(),
+	}
+}
+
+// Entities returns the entities of the text written so far. Entities
+// that are still open end at the end of the text, empty ones are dropped.
+func (r *Renderer) Entities() []MessageEntity {
+	var out []MessageEntity
+	for i, e := range r.entities {
+		if r.isOpen(i) {
+			e.Length = r.length - e.Offset
+		}
+		if e.Offset >= 0 && e.Length > 0 {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func (r *Renderer) isOpen(i int) bool {
+	for _, j := range r.open {
+		if j == i {
+			return r.entities[i].Offset >= 0
+		}
+	}
+	return false
+}
+
+// write writes text after the pending newlines and places the entities
+// waiting for it.
+func (r *Renderer) write(w io.Writer, text string) {
+	if text == "" {
+		return
+	}
+	if r.length > 0 && r.pendingNewlines > 0 {
+		nl := strings.Repeat("\n", r.pendingNewlines)
+		io.WriteString(w, nl)
+		r.length += len(nl)
+	}
+	r.pendingNewlines = 0
+	for _, i := range r.unplaced {
+		r.entities[i].Offset = r.length
+	}
+	r.unplaced = r.unplaced[:0]
+	io.WriteString(w, text)
+	r.length += len(utf16.Encode([]rune(text)))
+}
+
+// lineBreak ends the current line followed by n-1 blank lines. The
+// newlines are written with the next text.
+func (r *Renderer) lineBreak(n int) {
+	if n > r.pendingNewlines {
+		r.pendingNewlines = n
+	}
+}
+
+// blockBreak is the break written after a block in node's parent.
+func blockBreak(node ast.Node) int {
+	if item, ok := node.GetParent().(*ast.ListItem); ok {
+		if list, ok := item.Parent.(*ast.List); ok && list.Tight {
+			return 1
+		}
+	}
+	return 2
+}
+
+// openEntity starts e at the next text.
+func (r *Renderer) openEntity(e MessageEntity) {
+	e.Offset = -1
+	r.entities = append(r.entities, e)
+	i := len(r.entities) - 1
+	r.unplaced = append(r.unplaced, i)
+	r.open = append(r.open, i)
+}
+
+// openNone marks a node that has no entity, so that closeEntity stays
+// balanced.
+func (r *Renderer) openNone() {
+	r.open = append(r.open, -1)
+}
+
+// closeEntity ends the innermost open entity at the end of the text
+// written so far.
+func (r *Renderer) closeEntity() {
+	i := r.open[len(r.open)-1]
+	r.open = r.open[:len(r.open)-1]
+	if i < 0 {
+		return
+	}
+	e := &r.entities[i]
+	if e.Offset < 0 {
+		// no text was written, the entity is dropped
+		for j, k := range r.unplaced {
+			if k == i {
+				r.unplaced = append(r.unplaced[:j], r.unplaced[j+1:]...)
+				break
+			}
+		}
+		return
+	}
+	e.Length = r.length - e.Offset
+}
+
+// inline opens or closes an entity of typ for a node with children.
+func (r *Renderer) inline(typ string, entering bool) {
+	if entering {
+		r.openEntity(MessageEntity{Type: typ})
+	} else {
+		r.closeEntity()
+	}
+}
+
+func (r *Renderer) para(w io.Writer, node *ast.Paragraph, entering bool) {
+	// terms of definition lists are bold, like in md2
+	if item, ok := node.Parent.(*ast.ListItem); ok && item.ListFlags&ast.ListTypeTerm != 0 {
+		r.inline("bold", entering)
+	}
+	if !entering {
+		r.lineBreak(blockBreak(node))
+		return
+	}
+	if item, ok := node.Parent.(*ast.ListItem); ok && item.IsTask && ast.GetFirstChild(item) == node {
+		r.taskCheckbox(w, item)
+	}
+}
+
+func (r *Renderer) taskCheckbox(w io.Writer, item *ast.ListItem) {
+	box := r.Opts.TaskUnchecked
+	if item.Checked {
+		box = r.Opts.TaskChecked
+	}
+	r.write(w, box+" ")
+}
+
+// heading writes headings as bold paragraphs, Telegram has no headings.
+func (r *Renderer) heading(entering bool) {
+	r.inline("bold", entering)
+	if !entering {
+		r.lineBreak(2)
+	}
+}
+
+func (r *Renderer) blockQuote(entering bool) {
+	r.lineBreak(2)
+	if entering {
+		if r.quoteDepth == 0 {
+			r.openEntity(MessageEntity{Type: "blockquote"})
+		} else {
+			r.openNone()
+		}
+		r.quoteDepth++
+		return
+	}
+	r.quoteDepth--
+	r.closeEntity()
+}
+
+func (r *Renderer) list(node *ast.List, entering bool) {
+	if !entering {
+		r.lists = r.lists[:len(r.lists)-1]
+		if len(r.lists) == 0 {
+			r.lineBreak(2)
+		}
+		return
+	}
+	level := &listLevel{ordered: node.ListFlags&ast.ListTypeOrdered != 0, counter: 1}
+	if node.Start > 0 {
+		level.counter = node.Start
+	}
+	r.lists = append(r.lists, level)
+	r.lineBreak(1)
+}
+
+// definitionIndent is written before the definitions of definition lists.
+const definitionIndent = "    "
+
+func (r *Renderer) listItem(w io.Writer, node *ast.ListItem, entering bool) {
+	if !entering {
+		r.lineBreak(1)
+		return
+	}
+	level := r.lists[len(r.lists)-1]
+	indent := strings.Repeat("  ", len(r.lists)-1)
+	// definition lists have no markers, definitions are indented under
+	// their term
+	switch {
+	case node.ListFlags&ast.ListTypeTerm != 0:
+		r.write(w, indent)
+		return
+	case node.ListFlags&ast.ListTypeDefinition != 0:
+		r.write(w, indent+definitionIndent)
+		return
+	}
+	if level.ordered {
+		r.write(w, fmt.Sprintf("%s%d. ", indent, level.counter))
+		level.counter++
+	} else {
+		r.write(w, indent+"- ")
+	}
+	if _, ok := ast.GetFirstChild(node).(*ast.Paragraph); node.IsTask && !ok {
+		r.taskCheckbox(w, node)
+	}
+}
+
+// linkURL tells if Telegram accepts url in a text_link entity.
+func linkURL(url string) bool {
+	s := strings.ToLower(url)
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://") ||
+		strings.HasPrefix(s, "tg://") || strings.HasPrefix(s, "mailto:")
+}
+
+func (r *Renderer) link(w io.Writer, node *ast.Link, entering bool) ast.WalkStatus {
+	if node.NoteID != 0 {
+		// footnotes have no anchors to link to
+		if entering {
+			r.write(w, fmt.Sprintf("[%d]", node.NoteID))
+		}
+		return ast.SkipChildren
+	}
+	url := string(node.Destination)
+	if entering {
+		if linkURL(url) {
+			r.openEntity(MessageEntity{Type: "text_link", URL: url})
+		} else {
+			r.openNone()
+		}
+		return ast.GoToNext
+	}
+	r.closeEntity()
+	if !linkURL(url) && url != "" && plain.Text(node) != url {
+		r.write(w, " ("+url+")")
+	}
+	return ast.GoToNext
+}
+
+// image writes the description of an image linked to it.
+func (r *Renderer) image(w io.Writer, node *ast.Image) {
+	alt := plain.Text(node)
+	url := string(node.Destination)
+	if alt == "" {
+		alt = url
+	}
+	if linkURL(url) {
+		r.openEntity(MessageEntity{Type: "text_link", URL: url})
+		r.write(w, alt)
+		r.closeEntity()
+		return
+	}
+	r.write(w, alt)
+}
+
+func (r *Renderer) codeBlock(w io.Writer, node *ast.CodeBlock) {
+	r.lineBreak(1)
+	e := MessageEntity{Type: "pre"}
+	if info := strings.Fields(string(node.Info)); len(info) > 0 {
+		e.Language = info[0]
+	}
+	r.openEntity(e)
+	r.write(w, strings.TrimSuffix(string(node.Literal), "\n"))
+	r.closeEntity()
+	r.lineBreak(blockBreak(node))
+}
+
+// mathText returns the text of a formula as it is shown, see
+// RendererOptions.RawMath.
+func (r *Renderer) mathText(literal []byte) string {
+	if r.Opts.RawMath {
+		return strings.TrimSpace(string(literal))
+	}
+	return strings.TrimSpace(r.latex.ToUnicode(string(literal)))
+}
+
+// htmlSpanRe matches an HTML tag and captures the slash of closing tags and
+// the tag name.
+var htmlSpanRe = regexp.MustCompile(`^<(/?)([A-Za-z][A-Za-z0-9-]*)[^>]*?(/?)>$`)
+
+// spanEntities are the entities of the inline tags supported by Telegram.
+var spanEntities = map[string]string{
+	"b": "bold", "strong": "bold",
+	"i": "italic", "em": "italic",
+	"u": "underline", "ins": "underline",
+	"s": "strikethrough", "strike": "strikethrough", "del": "strikethrough",
+	"tg-spoiler": "spoiler", "spoiler": "spoiler",
+	"code": "code",
+}
+
+// htmlSpan opens and closes the entities of inline tags, the other tags
+// are dropped.
+func (r *Renderer) htmlSpan(w io.Writer, node *ast.HTMLSpan) {
+	m := htmlSpanRe.FindStringSubmatch(strings.TrimSpace(string(node.Literal)))
+	if m == nil {
+		return
+	}
+	closing, selfClosing := m[1] == "/", m[3] == "/"
+	name := strings.ToLower(m[2])
+	if name == "br" {
+		r.lineBreak(1)
+		return
+	}
+	if closing {
+		for i := len(r.spans) - 1; i >= 0; i-- {
+			if r.spans[i].name != name {
+				continue
+			}
+			for j := len(r.spans) - 1; j >= i; j-- {
+				if r.spans[j].entity {
+					r.closeEntity()
+				}
+			}
+			r.spans = r.spans[:i]
+			break
+		}
+		return
+	}
+	if selfClosing {
+		return
+	}
+	typ := spanEntities[name]
+	if strings.Contains(m[0], "tg-spoiler") {
+		typ = "spoiler"
+	}
+	if typ != "" {
+		r.openEntity(MessageEntity{Type: typ})
+	}
+	r.spans = append(r.spans, openSpan{name: name, entity: typ != ""})
+}
+
+// RenderNode renders a markdown node to plain text and entities.
+func (r *Renderer) RenderNode(w io.Writer, node ast.Node, entering bool) ast.WalkStatus {
+	if r.Opts.RenderNodeHook != nil {
+		status, didHandle := r.Opts.RenderNodeHook(w, node, entering)
+		if didHandle {
+			return status
+		}
+	}
+	switch node := node.(type) {
+	case *ast.Text:
+		r.write(w, plain.CleanWithoutTrim(string(node.Literal)))
+	case *ast.Softbreak, *ast.NonBlockingSpace:
+		r.write(w, " ")
+	case *ast.Hardbreak:
+		r.lineBreak(1)
+	case *ast.Emph:
+		r.inline("italic", entering)
+	case *ast.Strong:
+		r.inline("bold", entering)
+	case *ast.Del:
+		r.inline("strikethrough", entering)
+	case *ast.BlockQuote:
+		r.blockQuote(entering)
+	case *ast.Link:
+		return r.link(w, node, entering)
+	case *ast.Image:
+		if entering {
+			r.image(w, node)
+		}
+		return ast.SkipChildren
+	case *ast.Code:
+		r.openEntity(MessageEntity{Type: "code"})
+		r.write(w, string(node.Literal))
+		r.closeEntity()
+	case *ast.CodeBlock:
+		r.codeBlock(w, node)
+	case *ast.Paragraph:
+		r.para(w, node, entering)
+	case *ast.HTMLSpan:
+		r.htmlSpan(w, node)
+	case *ast.HTMLBlock:
+		if text := plain.HTMLText(string(node.Literal)); text != "" {
+			r.lineBreak(1)
+			r.write(w, text)
+			r.lineBreak(2)
+		}
+	case *ast.Heading:
+		r.heading(entering)
+	case *ast.HorizontalRule:
+		r.lineBreak(1)
+		r.write(w, r.Opts.HorizontalRule)
+		r.lineBreak(2)
+	case *ast.List:
+		r.list(node, entering)
+	case *ast.ListItem:
+		r.listItem(w, node, entering)
+	case *ast.Table:
+		r.lineBreak(2)
+	case *ast.TableRow:
+		r.lineBreak(1)
+	case *ast.TableCell:
+		if entering && ast.GetPrevNode(node) != nil {
+			r.write(w, " | ")
+		}
+		if node.IsHeader {
+			r.inline("bold", entering)
+		}
+	case *ast.Math:
+		r.write(w, r.mathText(node.Literal))
+	case *ast.MathBlock:
+		if entering {
+			r.lineBreak(1)
+			r.write(w, r.mathText(node.Literal))
+			r.lineBreak(2)
+		}
+		return ast.SkipChildren
+	case *ast.Subscript:
+		r.write(w, string(node.Literal))
+	case *ast.Superscript:
+		r.write(w, string(node.Literal))
+	default:
+		// the other nodes have no entities, their children are rendered as
+		// they are
+	}
+	return ast.GoToNext
+}
+
+// RenderHeader renders header
+func (r *Renderer) RenderHeader(w io.Writer, ast ast.Node) {
+	// do nothing
+}
+
+// RenderFooter renders footer
+func (r *Renderer) RenderFooter(w io.Writer, ast ast.Node) {
+	// do nothing
+}
+
+// Render renders doc to the text and entities of a Telegram message.
+func Render(doc ast.Node, opts RendererOptions) (string, []MessageEntity) {
+	r := NewRenderer(opts)
+	var buf strings.Builder
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		return r.RenderNode(&buf, node, entering)
+	})
+	return buf.String(), r.Entities()
+}
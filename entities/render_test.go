@@ -0,0 +1,87 @@
+package entities
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/eternalsad/markdownify/internal/rendertest"
+	"github.com/eternalsad/markdownify/parser"
+)
+
+func testRendering(t *testing.T, source, expected string, expectedEntities []MessageEntity) {
+	t.Helper()
+	text, ents := Render(rendertest.Parse(source), RendererOptions{})
+	if text != expected {
+		t.Errorf("\nInput   [%#v]\nExpected[%#v]\nGot     [%#v]\n", source, expected, text)
+	}
+	if !reflect.DeepEqual(ents, expectedEntities) {
+		t.Errorf("\nInput   [%#v]\nExpected[%#v]\nGot     [%#v]\n", source, expectedEntities, ents)
+	}
+}
+
+func TestRenderInline(t *testing.T) {
+	testRendering(t, "Hello, world", "Hello, world", nil)
+	testRendering(t, "**bold _both_** `code`", "bold both code", []MessageEntity{
+		{Type: "bold", Offset: 0, Length: 9},
+		{Type: "italic", Offset: 5, Length: 4},
+		{Type: "code", Offset: 10, Length: 4},
+	})
+	testRendering(t, "~~gone~~ <u>under</u> <tg-spoiler>secret</tg-spoiler>", "gone under secret", []MessageEntity{
+		{Type: "strikethrough", Offset: 0, Length: 4},
+		{Type: "underline", Offset: 5, Length: 5},
+		{Type: "spoiler", Offset: 11, Length: 6},
+	})
+}
+
+func TestRenderUTF16(t *testing.T) {
+	// the emoji takes two UTF-16 code units
+	testRendering(t, "😀 **бold**", "😀 бold", []MessageEntity{
+		{Type: "bold", Offset: 3, Length: 4},
+	})
+}
+
+func TestRenderLinks(t *testing.T) {
+	testRendering(t, "[site](https://example.com) and [local](/path)", "site and local (/path)", []MessageEntity{
+		{Type: "text_link", Offset: 0, Length: 4, URL: "https://example.com"},
+	})
+	testRendering(t, "![logo](https://example.com/a.png)", "logo", []MessageEntity{
+		{Type: "text_link", Offset: 0, Length: 4, URL: "https://example.com/a.png"},
+	})
+}
+
+func TestRenderBlocks(t *testing.T) {
+	testRendering(t, "# Title\n\nText\n\n```go\nx := 1\n```\n\nEnd",
+		"Title\n\nText\n\nx := 1\n\nEnd", []MessageEntity{
+			{Type: "bold", Offset: 0, Length: 5},
+			{Type: "pre", Offset: 13, Length: 6, Language: "go"},
+		})
+	testRendering(t, "> quoted\n> > nested\n\nafter", "quoted\n\nnested\n\nafter", []MessageEntity{
+		{Type: "blockquote", Offset: 0, Length: 14},
+	})
+}
+
+func TestRenderLists(t *testing.T) {
+	testRendering(t, "- one\n- two\n  1. a\n  2. b\n\ntext", "- one\n- two\n  1. a\n  2. b\n\ntext", nil)
+	testRendering(t, "- [ ] todo\n- [x] done", "- ☐ todo\n- ☑ done", nil)
+	testRendering(t, "Term\n: def a\n: def b\n\ntext", "Term\n    def a\n    def b\n\ntext", []MessageEntity{
+		{Type: "bold", Offset: 0, Length: 4},
+	})
+}
+
+func TestRenderTable(t *testing.T) {
+	testRendering(t, "| a | b |\n|---|---|\n| 1 | 2 |", "a | b\n1 | 2", []MessageEntity{
+		{Type: "bold", Offset: 0, Length: 1},
+		{Type: "bold", Offset: 4, Length: 1},
+	})
+}
+
+func TestRenderEmptyEntities(t *testing.T) {
+	testRendering(t, "a ** ** b", "a ** ** b", nil)
+	testRendering(t, "text <b></b>", "text ", nil)
+}
+
+func TestRenderRoundTrip(t *testing.T) {
+	p := parser.NewWithExtensions(parser.CommonExtensions)
+	text, ents := Render(p.Parse([]byte("plain **bold** and *italic*")), RendererOptions{})
+	testConversion(t, text, ents, "plain **bold** and *italic*\n\n")
+}
@@ -595,11 +595,20 @@ func (r *Renderer) paragraphExit(w io.Writer, para *ast.Paragraph) {
 
 // Paragraph writes ast.Paragraph node
 func (r *Renderer) Paragraph(w io.Writer, para *ast.Paragraph, entering bool) {
+	// the checkbox of a task list item goes inside its first paragraph
+	task, ok := para.Parent.(*ast.ListItem)
+	isTask := ok && task.IsTask && ast.GetFirstChild(task) == para
 	if SkipParagraphTags(para) {
+		if entering && isTask {
+			r.taskCheckbox(w, task)
+		}
 		return
 	}
 	if entering {
 		r.paragraphEnter(w, para)
+		if isTask {
+			r.taskCheckbox(w, task)
+		}
 	} else {
 		r.paragraphExit(w, para)
 	}
@@ -797,6 +806,19 @@ func (r *Renderer) listItemEnter(w io.Writer, listItem *ast.ListItem) {
 		openTag = "<dt>"
 	}
 	r.Outs(w, openTag)
+	if _, ok := ast.GetFirstChild(listItem).(*ast.Paragraph); listItem.IsTask && !ok {
+		r.taskCheckbox(w, listItem)
+	}
+}
+
+// taskCheckbox writes a disabled checkbox for a task list item.
+func (r *Renderer) taskCheckbox(w io.Writer, listItem *ast.ListItem) {
+	checked := ""
+	if listItem.Checked {
+		checked = ` checked=""`
+	}
+	tag := `<input type="checkbox"` + checked + ` disabled=""`
+	r.OutOneOf(w, r.Opts.Flags&UseXHTML == 0, tag+"> ", tag+" /> ")
 }
 
 func (r *Renderer) listItemExit(w io.Writer, listItem *ast.ListItem) {
@@ -0,0 +1,355 @@
+// Package plain has the parts shared by the renderers of text formats.
+//
+// Slack mrkdwn, WhatsApp and Discord have no syntax for tables and only
+// some of the Markdown blocks, their renderers write lists, quotes and
+// tables as text with Renderer. The Matrix and terminal renderers use the
+// helpers for the plain text they write.
+package plain
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/eternalsad/markdownify/ast"
+)
+
+// Markers are the strings a format writes for bold text and list items.
+type Markers struct {
+	// Bold opens and closes bold text.
+	Bold string
+	// Bullets are the bullets of unordered list items, by depth. Items
+	// get "-" without bullets.
+	Bullets []string
+	// ListIndent is written for each level of list nesting.
+	ListIndent string
+	// TaskUnchecked and TaskChecked are written after the bullet of task
+	// list items.
+	TaskUnchecked string
+	TaskChecked   string
+}
+
+// EscapeFunc escapes the characters of text that a format would read as
+// formatting. lineStart tells if text starts a line, where headings, lists
+// and quotes start.
+type EscapeFunc func(text string, lineStart bool) string
+
+// RenderNodeFunc renders a node, it is the RenderNode of a format.
+type RenderNodeFunc func(w io.Writer, node ast.Node, entering bool) ast.WalkStatus
+
+// Renderer keeps the state shared by the text formats and writes the
+// blocks they write alike. The renderer of a format calls it from its
+// RenderNode.
+//
+// Do not create this directly, instead use the New function.
+type Renderer struct {
+	Markers Markers
+	// Escape escapes text, nil writes text as it is.
+	Escape EscapeFunc
+
+	renderNode RenderNodeFunc
+	// stack of lists being rendered, innermost last
+	lists []*listLevel
+	// > 0 while inside bold text, nested bold markers are not written
+	boldDepth int
+	// set if nothing but blanks was written on the current line
+	lineStart bool
+}
+
+// listLevel is the rendering state of a single (possibly nested) list.
+type listLevel struct {
+	ordered bool
+	counter int
+}
+
+// New returns a Renderer writing markers and escaping text with escape.
+// renderNode renders the nodes of RenderChildren.
+func New(markers Markers, escape EscapeFunc, renderNode RenderNodeFunc) *Renderer {
+	return &Renderer{
+		Markers:    markers,
+		Escape:     escape,
+		renderNode: renderNode,
+		lineStart:  true,
+	}
+}
+
+// Outs writes s and notes if the current line is still blank.
+func (r *Renderer) Outs(w io.Writer, s string) {
+	if s == "" {
+		return
+	}
+	io.WriteString(w, s)
+	r.lineStart = strings.TrimRight(s, " ") == "" && r.lineStart || strings.HasSuffix(s, "\n")
+}
+
+// LineStart tells if nothing but blanks was written on the current line.
+func (r *Renderer) LineStart() bool {
+	return r.lineStart
+}
+
+// ListDepth returns the number of lists being rendered.
+func (r *Renderer) ListDepth() int {
+	return len(r.lists)
+}
+
+// ListIndent returns the indentation of the items of the innermost list.
+func (r *Renderer) ListIndent() string {
+	if len(r.lists) < 2 {
+		return ""
+	}
+	return strings.Repeat(r.Markers.ListIndent, len(r.lists)-1)
+}
+
+// RenderChildren renders the children of node to a string, as if they
+// started a line.
+func (r *Renderer) RenderChildren(node ast.Node) string {
+	var buf bytes.Buffer
+	lineStart := r.lineStart
+	r.lineStart = true
+	for _, child := range node.GetChildren() {
+		ast.WalkFunc(child, func(n ast.Node, entering bool) ast.WalkStatus {
+			return r.renderNode(&buf, n, entering)
+		})
+	}
+	r.lineStart = lineStart
+	return buf.String()
+}
+
+// escape returns text escaped with Escape.
+func (r *Renderer) escape(text string, lineStart bool) string {
+	if r.Escape == nil {
+		return text
+	}
+	return r.Escape(text, lineStart)
+}
+
+// Text writes the literal of a text node, escaped, on one line.
+func (r *Renderer) Text(w io.Writer, literal string) {
+	r.Outs(w, r.escape(CleanWithoutTrim(literal), r.lineStart))
+}
+
+// Bold opens or closes bold text. Nested bold markers would end the bold
+// text early, so only the outermost ones are written.
+func (r *Renderer) Bold(w io.Writer, entering bool) {
+	if entering {
+		if r.boldDepth == 0 {
+			r.Outs(w, r.Markers.Bold)
+		}
+		r.boldDepth++
+	} else {
+		r.boldDepth--
+		if r.boldDepth == 0 {
+			r.Outs(w, r.Markers.Bold)
+		}
+	}
+}
+
+// Para ends paragraphs with a blank line, or a line break in list items.
+func (r *Renderer) Para(w io.Writer, node *ast.Paragraph, entering bool) {
+	_, inListItem := node.Parent.(*ast.ListItem)
+	if entering {
+		// paragraphs after the first one in a list item continue under its
+		// text
+		if inListItem && ast.GetFirstChild(node.Parent) != node {
+			r.Outs(w, r.ListIndent()+r.Markers.ListIndent)
+		}
+		return
+	}
+	if inListItem {
+		r.Outs(w, "\n")
+	} else {
+		r.Outs(w, "\n\n")
+	}
+}
+
+// List starts or ends a list, the last list is followed by a blank line.
+func (r *Renderer) List(w io.Writer, node *ast.List, entering bool) {
+	if entering {
+		level := &listLevel{
+			ordered: node.ListFlags&ast.ListTypeOrdered != 0,
+			counter: 1,
+		}
+		if node.Start > 0 {
+			level.counter = node.Start
+		}
+		r.lists = append(r.lists, level)
+		return
+	}
+	r.lists = r.lists[:len(r.lists)-1]
+	if len(r.lists) == 0 {
+		r.Outs(w, "\n")
+	}
+}
+
+// ListItem writes the indentation, the bullet or number and the task
+// marker of a list item.
+func (r *Renderer) ListItem(w io.Writer, node *ast.ListItem, entering bool) {
+	if !entering {
+		return
+	}
+	level := r.lists[len(r.lists)-1]
+	r.Outs(w, r.ListIndent())
+	if node.ListFlags&(ast.ListTypeTerm|ast.ListTypeDefinition) != 0 {
+		if node.ListFlags&ast.ListTypeDefinition != 0 {
+			r.Outs(w, r.Markers.ListIndent)
+		}
+		return
+	}
+	if level.ordered {
+		r.Outs(w, fmt.Sprintf("%d. ", level.counter))
+		level.counter++
+	} else {
+		bullet := "-"
+		if bullets := r.Markers.Bullets; len(bullets) > 0 {
+			bullet = bullets[(len(r.lists)-1)%len(bullets)]
+		}
+		r.Outs(w, bullet+" ")
+	}
+	if node.IsTask {
+		if node.Checked {
+			r.Outs(w, r.Markers.TaskChecked+" ")
+		} else {
+			r.Outs(w, r.Markers.TaskUnchecked+" ")
+		}
+	}
+}
+
+// BlockQuote writes the lines of a quote after "> ".
+func (r *Renderer) BlockQuote(w io.Writer, node *ast.BlockQuote) {
+	text := strings.Trim(r.RenderChildren(node), "\n")
+	for _, line := range strings.Split(text, "\n") {
+		if line == "" {
+			r.Outs(w, ">\n")
+		} else {
+			r.Outs(w, "> "+line+"\n")
+		}
+	}
+	r.Outs(w, "\n")
+}
+
+// HTMLBlock writes the text of an HTML block without the tags.
+func (r *Renderer) HTMLBlock(w io.Writer, node *ast.HTMLBlock) {
+	text := HTMLText(string(node.Literal))
+	if text == "" {
+		return
+	}
+	r.Outs(w, r.escape(text, true)+"\n\n")
+}
+
+// CleanWithoutTrim replaces line breaks and tabs with spaces and collapses
+// runs of spaces.
+func CleanWithoutTrim(s string) string {
+	var b []byte
+	var p byte
+	for i := 0; i < len(s); i++ {
+		q := s[i]
+		if q == '\n' || q == '\r' || q == '\t' {
+			q = ' '
+		}
+		if q != ' ' || p != ' ' {
+			b = append(b, q)
+			p = q
+		}
+	}
+	return string(b)
+}
+
+// Text returns the text of node and its children without formatting.
+func Text(node ast.Node) string {
+	var buf strings.Builder
+	ast.WalkFunc(node, func(n ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.GoToNext
+		}
+		switch n := n.(type) {
+		case *ast.Text:
+			buf.WriteString(CleanWithoutTrim(string(n.Literal)))
+		case *ast.Code:
+			buf.Write(n.Literal)
+		case *ast.Math:
+			buf.Write(n.Literal)
+		case *ast.Hardbreak, *ast.Softbreak:
+			buf.WriteString(" ")
+		}
+		return ast.GoToNext
+	})
+	return strings.TrimSpace(buf.String())
+}
+
+// htmlTagRe matches an HTML tag or comment.
+var htmlTagRe = regexp.MustCompile(`<!--[\s\S]*?-->|</?[a-zA-Z][^>]*>`)
+
+// HTMLText returns the text of HTML without the tags and comments, for
+// formats that can't show HTML.
+func HTMLText(html string) string {
+	return strings.TrimSpace(htmlTagRe.ReplaceAllString(html, ""))
+}
+
+// Table returns the text of a table with aligned columns and a line under
+// the header, to be written as preformatted text.
+func Table(node *ast.Table) string {
+	var rows [][]string
+	header := -1
+	columns := 0
+	ast.WalkFunc(node, func(n ast.Node, entering bool) ast.WalkStatus {
+		row, ok := n.(*ast.TableRow)
+		if !ok || !entering {
+			return ast.GoToNext
+		}
+		var cells []string
+		for _, cell := range row.Children {
+			cells = append(cells, Text(cell))
+		}
+		if len(cells) > columns {
+			columns = len(cells)
+		}
+		if _, ok := row.Parent.(*ast.TableHeader); ok {
+			header = len(rows)
+		}
+		rows = append(rows, cells)
+		return ast.SkipChildren
+	})
+	if len(rows) == 0 {
+		return ""
+	}
+
+	widths := make([]int, columns)
+	for _, row := range rows {
+		for i, cell := range row {
+			if n := utf8.RuneCountInString(cell); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	var buf strings.Builder
+	for i, row := range rows {
+		for j := 0; j < columns; j++ {
+			cell := ""
+			if j < len(row) {
+				cell = row[j]
+			}
+			if j > 0 {
+				buf.WriteString(" | ")
+			}
+			buf.WriteString(cell)
+			if j < columns-1 {
+				buf.WriteString(strings.Repeat(" ", widths[j]-utf8.RuneCountInString(cell)))
+			}
+		}
+		buf.WriteString("\n")
+		if i == header {
+			for j, width := range widths {
+				if j > 0 {
+					buf.WriteString("-+-")
+				}
+				buf.WriteString(strings.Repeat("-", width))
+			}
+			buf.WriteString("\n")
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,91 @@
+package plain
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/eternalsad/markdownify/ast"
+	"github.com/eternalsad/markdownify/internal/rendertest"
+)
+
+// textRenderer renders lists, quotes and bold text with Renderer.
+type textRenderer struct {
+	base *Renderer
+}
+
+func newTextRenderer() *textRenderer {
+	r := &textRenderer{}
+	markers := Markers{Bold: "*", Bullets: []string{"-", "+"}, ListIndent: "  ", TaskUnchecked: "[ ]", TaskChecked: "[x]"}
+	escape := func(text string, lineStart bool) string {
+		if lineStart && strings.HasPrefix(text, "#") {
+			return `\` + text
+		}
+		return text
+	}
+	r.base = New(markers, escape, r.RenderNode)
+	return r
+}
+
+func (r *textRenderer) RenderNode(w io.Writer, node ast.Node, entering bool) ast.WalkStatus {
+	switch node := node.(type) {
+	case *ast.Text:
+		r.base.Text(w, string(node.Literal))
+	case *ast.Softbreak:
+		r.base.Outs(w, " ")
+	case *ast.Strong:
+		r.base.Bold(w, entering)
+	case *ast.Paragraph:
+		r.base.Para(w, node, entering)
+	case *ast.List:
+		r.base.List(w, node, entering)
+	case *ast.ListItem:
+		r.base.ListItem(w, node, entering)
+	case *ast.BlockQuote:
+		if entering {
+			r.base.BlockQuote(w, node)
+		}
+		return ast.SkipChildren
+	case *ast.HTMLBlock:
+		r.base.HTMLBlock(w, node)
+	}
+	return ast.GoToNext
+}
+
+func testRendering(t *testing.T, source, expected string) {
+	t.Helper()
+	rendertest.Expect(t, source, expected, rendertest.Render(newTextRenderer(), source))
+}
+
+func TestRenderer(t *testing.T) {
+	testRendering(t, "__a **b** c__\n", "*a b c*\n\n")
+	testRendering(t, "- a\n    - b\n- [x] c\n\n3. d\n", "- a\n  + b\n- [x] c\n\n3. d\n\n")
+	testRendering(t, "> a\n>\n> **b**\n", "> a\n>\n> *b*\n\n")
+	// text at the start of a line is escaped as such
+	testRendering(t, "\\# a\n\n> \\# b\n\n<div>\n# c\n</div>\n", "\\# a\n\n> \\# b\n\n\\# c\n\n")
+}
+
+func TestText(t *testing.T) {
+	doc := rendertest.Parse("a  **b**\n`c` $d$\n")
+	if got := Text(doc); got != "a b c d" {
+		t.Errorf("text = %q", got)
+	}
+	if got := HTMLText(" <p>a<!-- b --> <b>c</b></p> "); got != "a c" {
+		t.Errorf("HTML text = %q", got)
+	}
+}
+
+func TestTable(t *testing.T) {
+	doc := rendertest.Parse("| Name | Year |\n|---|---|\n| Go | 2009 |\n| Rust |\n")
+	table := ast.GetFirstChild(doc).(*ast.Table)
+	expected := "Name | Year\n-----+-----\nGo   | 2009\nRust | \n"
+	if got := Table(table); got != expected {
+		t.Errorf("\nExpected[%#v]\nGot     [%#v]\n", expected, got)
+	}
+}
+
+func TestRendererNoBullets(t *testing.T) {
+	r := newTextRenderer()
+	r.base.Markers.Bullets = nil
+	rendertest.Expect(t, "- a\n", "- a\n\n", rendertest.Render(r, "- a\n"))
+}
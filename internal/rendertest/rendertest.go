@@ -0,0 +1,41 @@
+// Package rendertest has the helpers shared by the tests of the renderers.
+package rendertest
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/eternalsad/markdownify/ast"
+	"github.com/eternalsad/markdownify/parser"
+)
+
+// Extensions are the parser extensions of the test documents.
+const Extensions = parser.CommonExtensions | parser.OrderedListStart | parser.TaskLists
+
+// NodeRenderer is a renderer of the nodes of a document.
+type NodeRenderer interface {
+	RenderNode(w io.Writer, node ast.Node, entering bool) ast.WalkStatus
+}
+
+// Parse parses source with Extensions.
+func Parse(source string) ast.Node {
+	return parser.NewWithExtensions(Extensions).Parse([]byte(source))
+}
+
+// Render parses source and renders the document with r.
+func Render(r NodeRenderer, source string) string {
+	var buf bytes.Buffer
+	ast.WalkFunc(Parse(source), func(node ast.Node, entering bool) ast.WalkStatus {
+		return r.RenderNode(&buf, node, entering)
+	})
+	return buf.String()
+}
+
+// Expect reports an error if got, rendered from source, is not expected.
+func Expect(t *testing.T, source, expected, got string) {
+	t.Helper()
+	if got != expected {
+		t.Errorf("\nInput   [%#v]\nExpected[%#v]\nGot     [%#v]\n", source, expected, got)
+	}
+}
@@ -0,0 +1,665 @@
+// Package matrix renders a Markdown AST to the HTML subset Matrix clients
+// accept in the formatted_body of org.matrix.custom.html messages, together
+// with the plain text body used by clients that don't show HTML.
+//
+// Only the tags and attributes of the allowlist in the Matrix specification
+// are written. Spoilers become <span data-mx-spoiler> and formulas carry
+// their LaTeX source in data-mx-maths.
+package matrix
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/eternalsad/markdownify/ast"
+	"github.com/eternalsad/markdownify/html"
+	"github.com/eternalsad/markdownify/internal/plain"
+	"github.com/eternalsad/markdownify/parser/latex"
+)
+
+// Format is the value of the format field of messages with a formatted_body.
+const Format = "org.matrix.custom.html"
+
+// Default styling used for the blank fields of RendererOptions.
+var (
+	// DefaultHorizontalRule is written to the plain body for thematic breaks.
+	DefaultHorizontalRule = "──────────"
+	// DefaultTaskUnchecked and DefaultTaskChecked mark task list items.
+	DefaultTaskUnchecked = "☐"
+	DefaultTaskChecked   = "☑"
+	// DefaultSpoiler is written to the plain body in place of spoilers.
+	DefaultSpoiler = "[spoiler]"
+)
+
+// AllowedTags are the tags Matrix clients keep in formatted_body.
+var AllowedTags = map[string]bool{
+	"font": true, "del": true, "h1": true, "h2": true, "h3": true, "h4": true,
+	"h5": true, "h6": true, "blockquote": true, "p": true, "a": true, "ul": true,
+	"ol": true, "sup": true, "sub": true, "li": true, "b": true, "i": true,
+	"u": true, "strong": true, "em": true, "strike": true, "code": true,
+	"hr": true, "br": true, "div": true, "table": true, "thead": true,
+	"tbody": true, "tr": true, "th": true, "td": true, "caption": true,
+	"pre": true, "span": true, "img": true, "details": true, "summary": true,
+}
+
+// AllowedSchemes are the URL schemes Matrix clients keep in links.
+var AllowedSchemes = []string{"http", "https", "ftp", "mailto", "magnet"}
+
+// RenderNodeFunc allows reusing most of Renderer logic and replacing
+// rendering of some nodes. If it returns false, Renderer.RenderNode
+// will execute its logic. If it returns true, Renderer.RenderNode will
+// skip rendering this node and will return WalkStatus
+type RenderNodeFunc func(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool)
+
+// RendererOptions is a collection of supplementary parameters tweaking
+// the behavior of various parts of the Matrix renderer.
+// Blank fields are replaced by the matching Default* value.
+type RendererOptions struct {
+	// HorizontalRule is written to the plain body for thematic breaks.
+	HorizontalRule string
+
+	// TaskUnchecked and TaskChecked are written in front of the text of
+	// task list items.
+	TaskUnchecked string
+	TaskChecked   string
+
+	// Spoiler is written to the plain body in place of spoilers so that
+	// notifications don't reveal them.
+	Spoiler string
+
+	// RawMath shows formulas as written in the fallback of data-mx-maths
+	// and in the plain body instead of replacing LaTeX commands with
+	// Unicode symbols.
+	RawMath bool
+
+	// if set, called at the start of RenderNode(). Allows replacing
+	// rendering of some nodes. The plain body is not written for nodes
+	// handled by the hook.
+	RenderNodeHook RenderNodeFunc
+}
+
+// Renderer renders to Matrix HTML. The plain text body is written by the
+// same walk, get it with Body.
+//
+// Do not create this directly, instead use the NewRenderer function.
+type Renderer struct {
+	*html.Renderer
+
+	Opts RendererOptions
+
+	// the plain text body
+	body bytes.Buffer
+	// newlines to write to the body before the next text
+	pendingNewlines int
+	// quote depth of the blank lines among the pending newlines
+	breakDepth int
+	// depth of the block quotes being rendered
+	quoteDepth int
+	// stack of lists being rendered, innermost last
+	lists []*listLevel
+	// stack of HTML tags opened by HTML spans, innermost last
+	spans []openSpan
+	// > 0 while inside a spoiler, the body gets no text
+	spoilerDepth int
+
+	latex *latex.LaTeXToMarkdownV2
+}
+
+// listLevel is the rendering state of a single (possibly nested) list.
+type listLevel struct {
+	ordered bool
+	counter int
+}
+
+// openSpan is an HTML tag opened by an HTML span.
+type openSpan struct {
+	name     string // name of the tag in the input
+	closeTag string // tag closing the output, "" if nothing was written
+	spoiler  bool
+}
+
+// NewRenderer returns a Matrix renderer.
+func NewRenderer(opts RendererOptions) *Renderer {
+	if opts.HorizontalRule == "" {
+		opts.HorizontalRule = DefaultHorizontalRule
+	}
+	if opts.TaskUnchecked == "" {
+		opts.TaskUnchecked = DefaultTaskUnchecked
+	}
+	if opts.TaskChecked == "" {
+		opts.TaskChecked = DefaultTaskChecked
+	}
+	if opts.Spoiler == "" {
+		opts.Spoiler = DefaultSpoiler
+	}
+	return &Renderer{
+		Renderer: html.NewRenderer(html.RendererOptions{}),
+		Opts:     opts,
+		latex:    latex.NewLaTeXToMarkdownV2(),
+	}
+}
+
+// Body returns the plain text body written so far.
+func (r *Renderer) Body() string {
+	return r.body.String()
+}
+
+// plain writes text to the plain body, prefixing its lines with the
+// markers of the enclosing block quotes.
+func (r *Renderer) plain(text string) {
+	if text == "" || r.spoilerDepth > 0 {
+		return
+	}
+	prefix := strings.Repeat("> ", r.quoteDepth)
+	for i, line := range strings.Split(text, "\n") {
+		if i > 0 {
+			r.plainBreak(r.pendingNewlines + 1)
+		}
+		if line == "" {
+			continue
+		}
+		if r.body.Len() > 0 {
+			depth := r.breakDepth
+			if r.quoteDepth < depth {
+				depth = r.quoteDepth
+			}
+			blank := strings.Repeat(">", depth)
+			for n := 0; n < r.pendingNewlines; n++ {
+				if n > 0 {
+					r.body.WriteString(blank)
+				}
+				r.body.WriteString("\n")
+			}
+		}
+		if r.pendingNewlines > 0 || r.body.Len() == 0 {
+			r.body.WriteString(prefix)
+		}
+		r.pendingNewlines = 0
+		r.body.WriteString(line)
+	}
+}
+
+// plainBreak ends the current line of the plain body followed by n-1
+// blank lines. The newlines are written with the next text.
+func (r *Renderer) plainBreak(n int) {
+	if r.pendingNewlines == 0 || r.quoteDepth < r.breakDepth {
+		r.breakDepth = r.quoteDepth
+	}
+	if n > r.pendingNewlines {
+		r.pendingNewlines = n
+	}
+}
+
+// blockBreak is the break written to the plain body after a block in
+// node's parent.
+func blockBreak(node ast.Node) int {
+	if item, ok := node.GetParent().(*ast.ListItem); ok {
+		if list, ok := item.Parent.(*ast.List); ok && list.Tight {
+			return 1
+		}
+	}
+	return 2
+}
+
+// escape returns s with HTML special characters escaped.
+func escape(s string) string {
+	var buf bytes.Buffer
+	html.EscapeHTML(&buf, []byte(s))
+	return buf.String()
+}
+
+// IsAllowedURL tells if Matrix clients keep links to url.
+func IsAllowedURL(url []byte) bool {
+	s := strings.ToLower(string(url))
+	for _, scheme := range AllowedSchemes {
+		if strings.HasPrefix(s, scheme+":") {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Renderer) text(w io.Writer, node *ast.Text) {
+	r.Text(w, node)
+	r.plain(plain.CleanWithoutTrim(string(node.Literal)))
+}
+
+func (r *Renderer) para(w io.Writer, node *ast.Paragraph, entering bool) {
+	skipTags := html.SkipParagraphTags(node)
+	if !entering {
+		// tags left open don't leak out of the paragraph
+		r.closeSpans(w, 0)
+		if !skipTags {
+			r.Outs(w, "</p>")
+		}
+		r.CR(w)
+		r.plainBreak(blockBreak(node))
+		return
+	}
+	if !skipTags {
+		r.Outs(w, "<p>")
+	}
+	if item, ok := node.Parent.(*ast.ListItem); ok && item.IsTask && ast.GetFirstChild(item) == node {
+		r.taskCheckbox(w, item)
+	}
+}
+
+func (r *Renderer) taskCheckbox(w io.Writer, item *ast.ListItem) {
+	box := r.Opts.TaskUnchecked
+	if item.Checked {
+		box = r.Opts.TaskChecked
+	}
+	r.Outs(w, box+" ")
+	r.plain(box + " ")
+}
+
+func (r *Renderer) heading(w io.Writer, node *ast.Heading, entering bool) {
+	level := node.Level
+	if level < 1 || level > 6 {
+		level = 6
+	}
+	if entering {
+		r.CR(w)
+		r.Outs(w, fmt.Sprintf("<h%d>", level))
+		return
+	}
+	r.closeSpans(w, 0)
+	r.Outs(w, fmt.Sprintf("</h%d>", level))
+	r.CR(w)
+	r.plainBreak(2)
+}
+
+func (r *Renderer) blockQuote(w io.Writer, entering bool) {
+	r.OutOneOfCr(w, entering, "<blockquote>", "</blockquote>")
+	if entering {
+		r.plainBreak(2)
+		r.quoteDepth++
+	} else {
+		r.quoteDepth--
+		r.plainBreak(2)
+	}
+}
+
+func (r *Renderer) list(w io.Writer, node *ast.List, entering bool) {
+	ordered := node.ListFlags&ast.ListTypeOrdered != 0
+	if !entering {
+		r.OutOneOf(w, ordered, "</ol>", "</ul>")
+		r.CR(w)
+		r.lists = r.lists[:len(r.lists)-1]
+		if len(r.lists) == 0 {
+			r.plainBreak(2)
+		}
+		return
+	}
+	level := &listLevel{ordered: ordered, counter: 1}
+	if node.Start > 0 {
+		level.counter = node.Start
+	}
+	r.lists = append(r.lists, level)
+	r.CR(w)
+	switch {
+	case !ordered:
+		r.Outs(w, "<ul>")
+	case node.Start > 1:
+		r.Outs(w, fmt.Sprintf(`<ol start="%d">`, node.Start))
+	default:
+		r.Outs(w, "<ol>")
+	}
+	r.CR(w)
+	r.plainBreak(1)
+}
+
+func (r *Renderer) listItem(w io.Writer, node *ast.ListItem, entering bool) {
+	if !entering {
+		r.Outs(w, "</li>")
+		r.CR(w)
+		r.plainBreak(1)
+		return
+	}
+	r.Outs(w, "<li>")
+	level := r.lists[len(r.lists)-1]
+	indent := strings.Repeat("  ", len(r.lists)-1)
+	if level.ordered {
+		r.plain(fmt.Sprintf("%s%d. ", indent, level.counter))
+		level.counter++
+	} else {
+		r.plain(indent + "- ")
+	}
+	if _, ok := ast.GetFirstChild(node).(*ast.Paragraph); node.IsTask && !ok {
+		r.taskCheckbox(w, node)
+	}
+}
+
+func (r *Renderer) link(w io.Writer, node *ast.Link, entering bool) ast.WalkStatus {
+	if node.NoteID != 0 {
+		// footnotes have no anchors to link to
+		if entering {
+			ref := fmt.Sprintf("[%d]", node.NoteID)
+			r.Outs(w, "<sup>"+ref+"</sup>")
+			r.plain(ref)
+		}
+		return ast.SkipChildren
+	}
+	allowed := IsAllowedURL(node.Destination)
+	if entering {
+		if allowed {
+			r.Outs(w, `<a href="`)
+			html.EscLink(w, node.Destination)
+			r.Outs(w, `">`)
+		}
+		return ast.GoToNext
+	}
+	if allowed {
+		r.Outs(w, "</a>")
+	}
+	url := string(node.Destination)
+	text := plain.Text(node)
+	if url != "" && text != url && text != strings.TrimPrefix(url, "mailto:") {
+		r.plain(" (" + url + ")")
+	}
+	return ast.GoToNext
+}
+
+// image writes an <img> for mxc:// URLs, the only ones Matrix clients
+// load, and a link to the image otherwise.
+func (r *Renderer) image(w io.Writer, node *ast.Image) {
+	alt := plain.Text(node)
+	url := string(node.Destination)
+	switch {
+	case strings.HasPrefix(url, "mxc://"):
+		r.Outs(w, `<img src="`+escape(url)+`" alt="`+escape(alt)+`">`)
+	case IsAllowedURL(node.Destination):
+		if alt == "" {
+			alt = url
+		}
+		r.Outs(w, `<a href="`)
+		html.EscLink(w, node.Destination)
+		r.Outs(w, `">`+escape(alt)+`</a>`)
+	default:
+		r.Outs(w, escape(alt))
+	}
+	switch {
+	case alt == "" || alt == url:
+		r.plain(url)
+	default:
+		r.plain(alt + " (" + url + ")")
+	}
+}
+
+func (r *Renderer) code(w io.Writer, node *ast.Code) {
+	r.Code(w, node)
+	r.plain(string(node.Literal))
+}
+
+// languageRe matches the characters allowed in the language of a code block.
+var languageRe = regexp.MustCompile(`^[A-Za-z0-9_+#.-]+$`)
+
+func (r *Renderer) codeBlock(w io.Writer, node *ast.CodeBlock) {
+	r.CR(w)
+	r.Outs(w, "<pre><code")
+	if info := strings.Fields(string(node.Info)); len(info) > 0 && languageRe.MatchString(info[0]) {
+		r.Outs(w, ` class="language-`+info[0]+`"`)
+	}
+	r.Outs(w, ">")
+	html.EscapeHTML(w, node.Literal)
+	r.Outs(w, "</code></pre>")
+	r.CR(w)
+	r.plainBreak(1)
+	r.plain(strings.TrimSuffix(string(node.Literal), "\n"))
+	r.plainBreak(blockBreak(node))
+}
+
+// mathText returns the text of a formula as it is shown, see
+// RendererOptions.RawMath.
+func (r *Renderer) mathText(literal []byte) string {
+	if r.Opts.RawMath {
+		return string(literal)
+	}
+	return r.latex.ToUnicode(string(literal))
+}
+
+// math writes a formula with its LaTeX source in data-mx-maths and the
+// text shown by clients without LaTeX support as its content.
+func (r *Renderer) math(w io.Writer, literal []byte, block bool) {
+	source := strings.TrimSpace(string(literal))
+	text := strings.TrimSpace(r.mathText(literal))
+	tag := "span"
+	if block {
+		tag = "div"
+		r.CR(w)
+		r.plainBreak(1)
+	}
+	r.Outs(w, "<"+tag+` data-mx-maths="`+escape(source)+`"><code>`+escape(text)+"</code></"+tag+">")
+	r.plain(text)
+	if block {
+		r.CR(w)
+		r.plainBreak(2)
+	}
+}
+
+func (r *Renderer) horizontalRule(w io.Writer) {
+	r.CR(w)
+	r.Outs(w, "<hr>")
+	r.CR(w)
+	r.plainBreak(1)
+	r.plain(r.Opts.HorizontalRule)
+	r.plainBreak(2)
+}
+
+// htmlSpanRe matches an HTML tag and captures the slash of closing tags and
+// the tag name.
+var htmlSpanRe = regexp.MustCompile(`^<(/?)([A-Za-z][A-Za-z0-9-]*)[^>]*?(/?)>$`)
+
+// spanTags maps tags of the input to allowed tags.
+var spanTags = map[string]string{
+	"s":   "del",
+	"ins": "u",
+}
+
+// htmlSpan writes allowed inline tags without their attributes. Spoiler
+// tags become <span data-mx-spoiler>, the other tags are dropped.
+func (r *Renderer) htmlSpan(w io.Writer, node *ast.HTMLSpan) {
+	m := htmlSpanRe.FindStringSubmatch(strings.TrimSpace(string(node.Literal)))
+	if m == nil {
+		return
+	}
+	closing, selfClosing := m[1] == "/", m[3] == "/"
+	name := strings.ToLower(m[2])
+	if name == "br" {
+		r.Outs(w, "<br>")
+		r.plain("\n")
+		return
+	}
+	if closing {
+		for i := len(r.spans) - 1; i >= 0; i-- {
+			if r.spans[i].name == name {
+				r.closeSpans(w, i)
+				break
+			}
+		}
+		return
+	}
+	if selfClosing {
+		return
+	}
+	span := openSpan{name: name}
+	tag := name
+	if mapped, ok := spanTags[name]; ok {
+		tag = mapped
+	}
+	switch {
+	case name == "tg-spoiler" || name == "spoiler" || strings.Contains(m[0], "tg-spoiler"):
+		r.Outs(w, "<span data-mx-spoiler>")
+		r.plain(r.Opts.Spoiler)
+		span.closeTag, span.spoiler = "</span>", true
+		r.spoilerDepth++
+	case AllowedTags[tag]:
+		r.Outs(w, "<"+tag+">")
+		span.closeTag = "</" + tag + ">"
+	}
+	r.spans = append(r.spans, span)
+}
+
+// closeSpans closes the tags opened by HTML spans since the n-th open one,
+// innermost first.
+func (r *Renderer) closeSpans(w io.Writer, n int) {
+	for len(r.spans) > n {
+		span := r.spans[len(r.spans)-1]
+		r.spans = r.spans[:len(r.spans)-1]
+		r.Outs(w, span.closeTag)
+		if span.spoiler {
+			r.spoilerDepth--
+		}
+	}
+}
+
+// htmlBlock writes the text of an HTML block without the tags.
+func (r *Renderer) htmlBlock(w io.Writer, node *ast.HTMLBlock) {
+	text := plain.HTMLText(string(node.Literal))
+	if text == "" {
+		return
+	}
+	r.CR(w)
+	r.Outs(w, "<p>"+escape(text)+"</p>")
+	r.CR(w)
+	r.plain(text)
+	r.plainBreak(2)
+}
+
+func (r *Renderer) tableCell(w io.Writer, node *ast.TableCell, entering bool) {
+	if !entering {
+		r.closeSpans(w, 0)
+		r.OutOneOf(w, node.IsHeader, "</th>", "</td>")
+		r.CR(w)
+		return
+	}
+	if ast.GetPrevNode(node) == nil {
+		r.CR(w)
+	} else {
+		r.plain(" | ")
+	}
+	r.OutOneOf(w, node.IsHeader, "<th>", "<td>")
+}
+
+// RenderNode renders a markdown node to Matrix HTML and the plain body.
+func (r *Renderer) RenderNode(w io.Writer, node ast.Node, entering bool) ast.WalkStatus {
+	if r.Opts.RenderNodeHook != nil {
+		status, didHandle := r.Opts.RenderNodeHook(w, node, entering)
+		if didHandle {
+			return status
+		}
+	}
+	switch node := node.(type) {
+	case *ast.Text:
+		r.text(w, node)
+	case *ast.Softbreak:
+		r.CR(w)
+		r.plain(" ")
+	case *ast.Hardbreak:
+		r.HardBreak(w, node)
+		r.plain("\n")
+	case *ast.NonBlockingSpace:
+		r.NonBlockingSpace(w, node)
+		r.plain(" ")
+	case *ast.Emph:
+		r.OutOneOf(w, entering, "<em>", "</em>")
+	case *ast.Strong:
+		r.OutOneOf(w, entering, "<strong>", "</strong>")
+	case *ast.Del:
+		r.OutOneOf(w, entering, "<del>", "</del>")
+	case *ast.BlockQuote:
+		r.blockQuote(w, entering)
+	case *ast.Link:
+		return r.link(w, node, entering)
+	case *ast.Image:
+		if entering {
+			r.image(w, node)
+		}
+		return ast.SkipChildren
+	case *ast.Code:
+		r.code(w, node)
+	case *ast.CodeBlock:
+		r.codeBlock(w, node)
+	case *ast.Paragraph:
+		r.para(w, node, entering)
+	case *ast.HTMLSpan:
+		r.htmlSpan(w, node)
+	case *ast.HTMLBlock:
+		r.htmlBlock(w, node)
+	case *ast.Heading:
+		r.heading(w, node, entering)
+	case *ast.HorizontalRule:
+		r.horizontalRule(w)
+	case *ast.List:
+		r.list(w, node, entering)
+	case *ast.ListItem:
+		r.listItem(w, node, entering)
+	case *ast.Table:
+		r.OutOneOfCr(w, entering, "<table>", "</table>")
+		r.plainBreak(2)
+	case *ast.TableHeader:
+		r.OutOneOfCr(w, entering, "<thead>", "</thead>")
+	case *ast.TableBody:
+		r.OutOneOfCr(w, entering, "<tbody>", "</tbody>")
+	case *ast.TableRow:
+		r.OutOneOfCr(w, entering, "<tr>", "</tr>")
+		r.plainBreak(1)
+	case *ast.TableCell:
+		r.tableCell(w, node, entering)
+	case *ast.Math:
+		r.math(w, node.Literal, false)
+	case *ast.MathBlock:
+		if entering {
+			r.math(w, node.Literal, true)
+		}
+		return ast.SkipChildren
+	case *ast.Subscript:
+		r.Outs(w, "<sub>"+escape(string(node.Literal))+"</sub>")
+		r.plain(string(node.Literal))
+	case *ast.Superscript:
+		r.Outs(w, "<sup>"+escape(string(node.Literal))+"</sup>")
+		r.plain(string(node.Literal))
+	default:
+		// the other nodes have no allowed tags, their children are
+		// rendered as they are
+	}
+	return ast.GoToNext
+}
+
+// RenderHeader renders header
+func (r *Renderer) RenderHeader(w io.Writer, ast ast.Node) {
+	// do nothing
+}
+
+// RenderFooter closes the tags left open by HTML spans.
+func (r *Renderer) RenderFooter(w io.Writer, ast ast.Node) {
+	r.closeSpans(w, 0)
+}
+
+// Message is the content of an m.room.message event carrying both the
+// plain body and the formatted body.
+type Message struct {
+	MsgType       string `json:"msgtype"`
+	Body          string `json:"body"`
+	Format        string `json:"format,omitempty"`
+	FormattedBody string `json:"formatted_body,omitempty"`
+}
+
+// Render renders doc to an m.text message in a single walk.
+func Render(doc ast.Node, opts RendererOptions) Message {
+	r := NewRenderer(opts)
+	var buf bytes.Buffer
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		return r.RenderNode(&buf, node, entering)
+	})
+	r.RenderFooter(&buf, doc)
+	return Message{
+		MsgType:       "m.text",
+		Body:          r.Body(),
+		Format:        Format,
+		FormattedBody: strings.TrimSpace(buf.String()),
+	}
+}
@@ -0,0 +1,87 @@
+package matrix
+
+import (
+	"testing"
+
+	"github.com/eternalsad/markdownify/internal/rendertest"
+)
+
+func renderMessage(source string) Message {
+	return Render(rendertest.Parse(source), RendererOptions{})
+}
+
+func testRendering(t *testing.T, source string, formatted string, body string) {
+	t.Helper()
+	got := renderMessage(source)
+	rendertest.Expect(t, source, formatted, got.FormattedBody)
+	if got.Body != body {
+		t.Errorf("\nInput   [%#v]\nExpected body[%#v]\nGot          [%#v]\n", source, body, got.Body)
+	}
+}
+
+func TestRenderInline(t *testing.T) {
+	testRendering(t, "**bold** *italic* ~~strike~~ `a < b`\n",
+		"<p><strong>bold</strong> <em>italic</em> <del>strike</del> <code>a &lt; b</code></p>",
+		"bold italic strike a < b")
+}
+
+func TestRenderLinks(t *testing.T) {
+	testRendering(t, "[docs](https://go.dev/doc) https://go.dev\n",
+		`<p><a href="https://go.dev/doc">docs</a> <a href="https://go.dev">https://go.dev</a></p>`,
+		"docs (https://go.dev/doc) https://go.dev")
+	testRendering(t, "[click](javascript:alert(1))\n",
+		"<p>click</p>",
+		"click (javascript:alert(1))")
+	testRendering(t, "![a](mxc://example.org/abc) ![b](https://example.org/b.png)\n",
+		`<p><img src="mxc://example.org/abc" alt="a"> <a href="https://example.org/b.png">b</a></p>`,
+		"a (mxc://example.org/abc) b (https://example.org/b.png)")
+}
+
+func TestRenderHTMLSpans(t *testing.T) {
+	testRendering(t, "a <tg-spoiler>secret</tg-spoiler> <b onclick=\"x\">b</b> <script>c</script>\n",
+		"<p>a <span data-mx-spoiler>secret</span> <b>b</b> c</p>",
+		"a [spoiler] b c")
+}
+
+func TestRenderUnclosedHTMLSpans(t *testing.T) {
+	testRendering(t, "<tg-spoiler>secret\n\nmore text here\n\n- item\n",
+		"<p><span data-mx-spoiler>secret</span></p>\n<p>more text here</p>\n\n<ul>\n<li>item\n</li>\n</ul>",
+		"[spoiler]\n\nmore text here\n\n- item")
+	testRendering(t, "# <i>title\n\ntext <b>bold\n",
+		"<h1><i>title</i></h1>\n<p>text <b>bold</b></p>",
+		"title\n\ntext bold")
+}
+
+func TestRenderMath(t *testing.T) {
+	testRendering(t, "$\\alpha < 1$\n",
+		`<p><span data-mx-maths="\alpha &lt; 1"><code>α &lt; 1</code></span></p>`,
+		"α < 1")
+	got := renderMessage("$$\n\\sum x\n$$\n")
+	expected := `<div data-mx-maths="\sum x"><code>∑ x</code></div>`
+	if got.FormattedBody != expected {
+		t.Errorf("expected %q, got %q", expected, got.FormattedBody)
+	}
+}
+
+func TestRenderBlocks(t *testing.T) {
+	testRendering(t, "# Title\n\n> quote\n>\n> more\n\n```go\nx := 1\n```\n",
+		"<h1>Title</h1>\n\n<blockquote><p>quote</p>\n<p>more</p>\n</blockquote>\n\n<pre><code class=\"language-go\">x := 1\n</code></pre>",
+		"Title\n\n> quote\n>\n> more\n\nx := 1")
+}
+
+func TestRenderLists(t *testing.T) {
+	testRendering(t, "- a\n    - b\n- [x] c\n\n3. d\n",
+		"<ul>\n<li>a\n\n<ul>\n<li>b\n</li>\n</ul>\n</li>\n<li>☑ c\n</li>\n</ul>\n\n<ol start=\"3\">\n<li>d\n</li>\n</ol>",
+		"- a\n  - b\n- ☑ c\n\n3. d")
+}
+
+func TestRenderTable(t *testing.T) {
+	got := renderMessage("| A | B |\n|:--|--:|\n| 1 | 2 |\n")
+	expected := "<table>\n<thead>\n<tr>\n<th>A</th>\n<th>B</th>\n</tr>\n</thead>\n\n<tbody>\n<tr>\n<td>1</td>\n<td>2</td>\n</tr>\n</tbody>\n</table>"
+	if got.FormattedBody != expected {
+		t.Errorf("expected %q, got %q", expected, got.FormattedBody)
+	}
+	if got.Body != "A | B\n1 | 2" {
+		t.Errorf("body = %q", got.Body)
+	}
+}
@@ -78,6 +78,13 @@ func (r *Renderer) listItem(w io.Writer, node *ast.ListItem, entering bool) {
 		} else {
 			fmt.Fprintf(w, "%s ", bullet)
 		}
+		if node.IsTask {
+			if node.Checked {
+				r.outs(w, "[x] ")
+			} else {
+				r.outs(w, "[ ] ")
+			}
+		}
 	}
 }
 
@@ -119,7 +126,10 @@ func needsEscaping(text []byte, lastNormalText string) bool {
 		"(", ")",
 		"#",
 		"+",
-		"-":
+		"-",
+		"~",
+		"&",
+		"$":
 		return true
 	case "!":
 		return false
@@ -232,13 +242,59 @@ func (r *Renderer) codeBlock(w io.Writer, node *ast.CodeBlock) {
 	}
 	r.outs(w, "\n")
 	r.out(w, text)
+	if len(text) > 0 && text[len(text)-1] != '\n' {
+		r.outs(w, "\n")
+	}
 	r.outs(w, "```\n\n")
 }
 
+// longestRun returns the length of the longest run of c in text.
+func longestRun(text []byte, c byte) int {
+	longest, n := 0, 0
+	for _, b := range text {
+		if b != c {
+			n = 0
+			continue
+		}
+		n++
+		if n > longest {
+			longest = n
+		}
+	}
+	return longest
+}
+
 func (r *Renderer) code(w io.Writer, node *ast.Code) {
-	r.outs(w, "`")
+	// the delimiter has to be longer than any run of backticks in the code
+	delim := strings.Repeat("`", longestRun(node.Literal, '`')+1)
+	pad := ""
+	if bytes.HasPrefix(node.Literal, []byte("`")) || bytes.HasSuffix(node.Literal, []byte("`")) {
+		pad = " "
+	}
+	r.outs(w, delim+pad)
 	r.out(w, node.Literal)
-	r.outs(w, "`")
+	r.outs(w, pad+delim)
+}
+
+func (r *Renderer) hardbreak(w io.Writer) {
+	r.outs(w, "\\\n")
+}
+
+func (r *Renderer) blockQuote(w io.Writer, node *ast.BlockQuote) {
+	var buf bytes.Buffer
+	for _, child := range node.Children {
+		ast.WalkFunc(child, func(node ast.Node, entering bool) ast.WalkStatus {
+			return r.RenderNode(&buf, node, entering)
+		})
+	}
+	for _, line := range strings.Split(strings.Trim(buf.String(), "\n"), "\n") {
+		if line == "" {
+			r.outs(w, ">\n")
+		} else {
+			r.outs(w, "> "+line+"\n")
+		}
+	}
+	r.outs(w, "\n")
 }
 
 func (r *Renderer) heading(w io.Writer, node *ast.Heading, entering bool) {
@@ -298,7 +354,7 @@ func (r *Renderer) RenderNode(w io.Writer, node ast.Node, entering bool) ast.Wal
 	case *ast.Softbreak:
 		panic(fmt.Sprintf("node %T NYI", node))
 	case *ast.Hardbreak:
-		panic(fmt.Sprintf("node %T NYI", node))
+		r.hardbreak(w)
 	case *ast.Emph:
 		r.surround(w, "*")
 	case *ast.Strong:
@@ -306,7 +362,10 @@ func (r *Renderer) RenderNode(w io.Writer, node ast.Node, entering bool) ast.Wal
 	case *ast.Del:
 		r.surround(w, "~~")
 	case *ast.BlockQuote:
-		panic(fmt.Sprintf("node %T NYI", node))
+		if entering {
+			r.blockQuote(w, node)
+		}
+		return ast.SkipChildren
 	case *ast.Aside:
 		panic(fmt.Sprintf("node %T NYI", node))
 	case *ast.Link:
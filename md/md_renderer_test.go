@@ -4,7 +4,9 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/eternalsad/markdownify"
 	"github.com/eternalsad/markdownify/ast"
+	"github.com/eternalsad/markdownify/parser"
 )
 
 func TestRenderDocument(t *testing.T) {
@@ -72,7 +74,8 @@ func TestRenderCode(t *testing.T) {
 func TestRenderCodeBlock(t *testing.T) {
 	var input = &ast.CodeBlock{Info: []byte(string("scala"))}
 	input.Literal = []byte(string("val x : Int = 42"))
-	expected := "\n```scala\nval x : Int = 42\n```\n"
+	// like paragraphs, code blocks are followed by a blank line
+	expected := "\n```scala\nval x : Int = 42\n```\n\n"
 	testRendering(t, input, expected)
 }
 
@@ -150,6 +153,14 @@ func TestRenderList(t *testing.T) {
 	testRendering(t, input, expected)
 }
 
+func TestRenderTaskList(t *testing.T) {
+	var source = []byte("- [ ] aaa\n- [x] bbb\n- ccc\n")
+	p := parser.NewWithExtensions(parser.CommonExtensions | parser.TaskLists)
+	var input = markdown.Parse(source, p)
+	var expected = "- [ ] aaa\n- [x] bbb\n- ccc\n\n"
+	testRendering(t, input, expected)
+}
+
 func testRendering(t *testing.T, input ast.Node, expected string) {
 	renderer := NewRenderer()
 	result := string(markdown.Render(input, renderer))
@@ -157,3 +168,16 @@ func testRendering(t *testing.T, input ast.Node, expected string) {
 		t.Errorf("[%s] is not equal to [%s]", result, expected)
 	}
 }
+
+func TestRenderCodeBackticks(t *testing.T) {
+	var input = &ast.Code{}
+	input.Literal = []byte("`ls` and ``x``")
+	expected := "``` `ls` and ``x`` ```"
+	testRendering(t, input, expected)
+}
+
+func TestRenderBlockQuote(t *testing.T) {
+	var input = markdown.Parse([]byte("> first\n>\n> second"), nil)
+	expected := "> first\n>\n> second\n\n"
+	testRendering(t, input, expected)
+}
@@ -0,0 +1,200 @@
+package md2
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/eternalsad/markdownify/ast"
+)
+
+// CodeOverflow tells what to do with code blocks longer than
+// RendererOptions.CodeBlockMaxLines.
+type CodeOverflow int
+
+const (
+	// CodeOverflowSplit renders the code as several consecutive code blocks,
+	// reopening the fence every CodeBlockMaxLines lines, so that it can be
+	// split across messages.
+	CodeOverflowSplit CodeOverflow = iota
+	// CodeOverflowAttach leaves a short note in the text and adds the code
+	// to Renderer.Attachments, to be sent as a document.
+	CodeOverflowAttach
+)
+
+// Attachment is a code block that was taken out of the text, see
+// CodeOverflowAttach.
+type Attachment struct {
+	FileName string
+	Language string // normalized, see NormalizeLanguage
+	Content  []byte
+}
+
+// languageAliases maps common names of languages to the ones understood by
+// Telegram's syntax highlighter.
+var languageAliases = map[string]string{
+	"golang":        "go",
+	"sh":            "bash",
+	"shell":         "bash",
+	"zsh":           "bash",
+	"console":       "bash",
+	"shell-session": "bash",
+	"js":            "javascript",
+	"jsx":           "javascript",
+	"node":          "javascript",
+	"ts":            "typescript",
+	"tsx":           "typescript",
+	"py":            "python",
+	"python3":       "python",
+	"py3":           "python",
+	"rb":            "ruby",
+	"rs":            "rust",
+	"kt":            "kotlin",
+	"kts":           "kotlin",
+	"yml":           "yaml",
+	"c++":           "cpp",
+	"cxx":           "cpp",
+	"hpp":           "cpp",
+	"h":             "c",
+	"cs":            "csharp",
+	"c#":            "csharp",
+	"f#":            "fsharp",
+	"ps1":           "powershell",
+	"pwsh":          "powershell",
+	"ps":            "powershell",
+	"md":            "markdown",
+	"htm":           "html",
+	"xhtml":         "html",
+	"docker":        "dockerfile",
+	"objc":          "objectivec",
+	"objective-c":   "objectivec",
+	"pl":            "perl",
+	"hs":            "haskell",
+	"ex":            "elixir",
+	"exs":           "elixir",
+	"erl":           "erlang",
+	"tf":            "hcl",
+	"terraform":     "hcl",
+	"psql":          "sql",
+	"postgresql":    "sql",
+	"mysql":         "sql",
+	"text":          "",
+	"txt":           "",
+	"plain":         "",
+	"plaintext":     "",
+}
+
+// languageExtensions are file name extensions of attachments, by language.
+var languageExtensions = map[string]string{
+	"bash": "sh", "c": "c", "cpp": "cpp", "csharp": "cs", "css": "css",
+	"go": "go", "html": "html", "java": "java", "javascript": "js",
+	"json": "json", "kotlin": "kt", "markdown": "md", "php": "php",
+	"python": "py", "ruby": "rb", "rust": "rs", "sql": "sql", "swift": "swift",
+	"typescript": "ts", "xml": "xml", "yaml": "yaml",
+}
+
+// NormalizeLanguage returns the language of a code block's info string in
+// the form accepted by Telegram, or "" if there is none or it is not valid.
+func NormalizeLanguage(info string) string {
+	fields := strings.Fields(info)
+	if len(fields) == 0 {
+		return ""
+	}
+	lang := strings.ToLower(strings.Trim(fields[0], "{}"))
+	lang = strings.TrimPrefix(lang, ".")
+	lang = strings.TrimPrefix(lang, "language-")
+	if alias, ok := languageAliases[lang]; ok {
+		return alias
+	}
+	for _, c := range lang {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.ContainsRune("+-_#.", c)) {
+			return ""
+		}
+	}
+	return lang
+}
+
+// codeFileName returns the file name of an attachment: the second field of
+// the info string if it looks like one, or a name derived from the language.
+func (r *Renderer) codeFileName(info string, lang string) string {
+	fields := strings.Fields(info)
+	if len(fields) > 1 && strings.Contains(fields[1], ".") && !strings.ContainsAny(fields[1], `/\`) {
+		return fields[1]
+	}
+	ext := languageExtensions[lang]
+	if ext == "" {
+		ext = "txt"
+	}
+	return fmt.Sprintf("code%d.%s", len(r.Attachments)+1, ext)
+}
+
+// splitLines splits text into lines, keeping the line endings.
+func splitLines(text []byte) [][]byte {
+	var lines [][]byte
+	for len(text) > 0 {
+		i := bytes.IndexByte(text, '\n')
+		if i < 0 {
+			lines = append(lines, text)
+			break
+		}
+		lines = append(lines, text[:i+1])
+		text = text[i+1:]
+	}
+	return lines
+}
+
+func (r *Renderer) codeBlock(w io.Writer, node *ast.CodeBlock) {
+	info := string(node.Info)
+	lang := NormalizeLanguage(info)
+	lines := splitLines(node.Literal)
+
+	max := r.Opts.CodeBlockMaxLines
+	if max > 0 && len(lines) > max && r.Opts.CodeBlockOverflow == CodeOverflowAttach {
+		name := r.codeFileName(info, lang)
+		r.Attachments = append(r.Attachments, Attachment{
+			FileName: name,
+			Language: lang,
+			Content:  node.Literal,
+		})
+		r.doubleSpace(w)
+		r.outs(w, escapeMarkdownV2(fmt.Sprintf("📎 %s (%d lines)", name, len(lines))))
+		r.outs(w, "\n\n")
+		return
+	}
+	if max <= 0 || len(lines) <= max {
+		max = len(lines)
+	}
+
+	r.doubleSpace(w)
+	for start := 0; start < len(lines) || start == 0; start += max {
+		if start > 0 {
+			// an empty line lets the message splitter break here
+			r.outs(w, "\n")
+		}
+		end := start + max
+		if end > len(lines) {
+			end = len(lines)
+		}
+		r.outs(w, "```")
+		r.outs(w, lang)
+		r.outs(w, "\n")
+		for _, line := range lines[start:end] {
+			r.outs(w, escapeCode(string(line)))
+		}
+		if end > start && !bytes.HasSuffix(lines[end-1], []byte("\n")) {
+			r.outs(w, "\n")
+		}
+		r.outs(w, "```\n")
+		if max == 0 {
+			break
+		}
+	}
+	r.outs(w, "\n")
+}
+
+func (r *Renderer) code(w io.Writer, node *ast.Code) {
+	r.outs(w, "`")
+	r.outs(w, escapeCode(string(node.Literal)))
+	r.outs(w, "`")
+}
@@ -0,0 +1,27 @@
+package md2
+
+import "testing"
+
+func TestNormalizeLanguage(t *testing.T) {
+	var tests = []string{
+		"", "",
+		"go", "go",
+		"golang", "go",
+		"sh", "bash",
+		"Python", "python",
+		"{.js}", "javascript",
+		"go main.go,readonly", "go",
+		"text", "",
+		"<script>", "",
+	}
+	for i := 0; i+1 < len(tests); i += 2 {
+		if got := NormalizeLanguage(tests[i]); got != tests[i+1] {
+			t.Errorf("NormalizeLanguage(%q) = %q, expected %q", tests[i], got, tests[i+1])
+		}
+	}
+}
+
+func TestRenderCodeEscaping(t *testing.T) {
+	testRendering(t, "use `a\\` here\n", "use `a\\\\` here\n\n")
+	testRendering(t, "```\n`x` \\n\n```\n", "\n```\n\\`x\\` \\\\n\n```\n\n")
+}
@@ -0,0 +1,471 @@
+package md2
+
+import (
+	"html"
+	"io"
+	"strings"
+)
+
+// Telegram doesn't accept HTML inside MarkdownV2, so raw HTML from the
+// markdown source is tokenized and the tags Telegram has an equivalent for
+// are converted to MarkdownV2 entities or Unicode. Other tags are dropped
+// and only their text is kept.
+
+type htmlTokenType int
+
+const (
+	htmlText htmlTokenType = iota
+	htmlStartTag
+	htmlEndTag
+	htmlSelfClosingTag
+	htmlComment
+)
+
+// htmlToken is a single token of raw HTML.
+type htmlToken struct {
+	typ htmlTokenType
+	// text with entities decoded for htmlText, lower-cased tag name for tags
+	data  string
+	attrs map[string]string
+}
+
+// tokenizeHTML splits s into text and tags. It is forgiving: anything that
+// doesn't look like a tag is returned as text.
+func tokenizeHTML(s string) []htmlToken {
+	var tokens []htmlToken
+	text := func(t string) {
+		if t != "" {
+			tokens = append(tokens, htmlToken{typ: htmlText, data: html.UnescapeString(t)})
+		}
+	}
+	start := 0
+	for i := 0; i < len(s); {
+		if s[i] != '<' {
+			i++
+			continue
+		}
+		tok, n := parseHTMLTag(s[i:])
+		if n == 0 {
+			i++
+			continue
+		}
+		text(s[start:i])
+		tokens = append(tokens, tok)
+		i += n
+		start = i
+	}
+	text(s[start:])
+	return tokens
+}
+
+// parseHTMLTag parses a tag, comment or declaration at the start of s and
+// returns it with its length in bytes, or 0 if s doesn't start with a tag.
+func parseHTMLTag(s string) (htmlToken, int) {
+	if strings.HasPrefix(s, "<!--") {
+		end := strings.Index(s[4:], "-->")
+		if end < 0 {
+			return htmlToken{}, 0
+		}
+		return htmlToken{typ: htmlComment}, 4 + end + 3
+	}
+	if strings.HasPrefix(s, "<!") || strings.HasPrefix(s, "<?") {
+		end := strings.IndexByte(s, '>')
+		if end < 0 {
+			return htmlToken{}, 0
+		}
+		return htmlToken{typ: htmlComment}, end + 1
+	}
+
+	tok := htmlToken{typ: htmlStartTag}
+	i := 1
+	if i < len(s) && s[i] == '/' {
+		tok.typ = htmlEndTag
+		i++
+	}
+	nameStart := i
+	for i < len(s) && isTagNameChar(s[i]) {
+		i++
+	}
+	if i == nameStart || !isLetter(s[nameStart]) {
+		return htmlToken{}, 0
+	}
+	tok.data = strings.ToLower(s[nameStart:i])
+
+	for i < len(s) {
+		i = skipHTMLSpace(s, i)
+		if i >= len(s) {
+			return htmlToken{}, 0
+		}
+		switch {
+		case s[i] == '>':
+			return tok, i + 1
+		case strings.HasPrefix(s[i:], "/>"):
+			if tok.typ == htmlStartTag {
+				tok.typ = htmlSelfClosingTag
+			}
+			return tok, i + 2
+		}
+
+		// attribute name
+		nameStart := i
+		for i < len(s) && !isHTMLSpace(s[i]) && s[i] != '=' && s[i] != '>' && s[i] != '/' {
+			i++
+		}
+		name := strings.ToLower(s[nameStart:i])
+		if name == "" {
+			// a stray '/' in the middle of a tag
+			i++
+			continue
+		}
+		value := ""
+		j := skipHTMLSpace(s, i)
+		if j < len(s) && s[j] == '=' {
+			i = skipHTMLSpace(s, j+1)
+			if i >= len(s) {
+				return htmlToken{}, 0
+			}
+			if q := s[i]; q == '"' || q == '\'' {
+				end := strings.IndexByte(s[i+1:], q)
+				if end < 0 {
+					return htmlToken{}, 0
+				}
+				value = s[i+1 : i+1+end]
+				i += end + 2
+			} else {
+				valueStart := i
+				for i < len(s) && !isHTMLSpace(s[i]) && s[i] != '>' {
+					i++
+				}
+				value = s[valueStart:i]
+			}
+		}
+		if tok.attrs == nil {
+			tok.attrs = map[string]string{}
+		}
+		tok.attrs[name] = html.UnescapeString(value)
+	}
+	return htmlToken{}, 0
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isTagNameChar(c byte) bool {
+	return isLetter(c) || (c >= '0' && c <= '9') || c == '-'
+}
+
+func isHTMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+func skipHTMLSpace(s string, i int) int {
+	for i < len(s) && isHTMLSpace(s[i]) {
+		i++
+	}
+	return i
+}
+
+// htmlVoidTags never have an end tag.
+var htmlVoidTags = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true,
+	"hr": true, "img": true, "input": true, "link": true, "meta": true,
+	"source": true, "track": true, "wbr": true,
+}
+
+// htmlDroppedTags are removed together with their content.
+var htmlDroppedTags = map[string]bool{
+	"script": true, "style": true, "head": true, "title": true,
+}
+
+// htmlBreakTags end a line when they are closed.
+var htmlBreakTags = map[string]bool{
+	"p": true, "div": true, "li": true, "tr": true, "blockquote": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"dt": true, "dd": true, "details": true, "summary": true, "table": true,
+}
+
+// openHTMLTag is a tag converted to a MarkdownV2 entity that still has to
+// be closed.
+type openHTMLTag struct {
+	name  string
+	close func(w io.Writer)
+}
+
+// htmlState is the state of raw HTML conversion. It is kept across nodes,
+// because inline HTML is parsed into a separate ast.HTMLSpan per tag.
+type htmlState struct {
+	open []openHTMLTag
+	// > 0 inside <script>, <style> and the like
+	dropped int
+	// > 0 inside <code>, <kbd> and <pre>
+	code int
+	// > 0 inside <pre>
+	pre int
+	// text of <pre> has been written and ended with a newline
+	preStarted, preNewline bool
+	// superscript or subscript map in effect, or nil
+	script map[rune]rune
+}
+
+// rawHTML converts a chunk of raw HTML, see the comment at the top of this
+// file.
+func (r *Renderer) rawHTML(w io.Writer, s string) {
+	for _, tok := range tokenizeHTML(s) {
+		switch tok.typ {
+		case htmlText:
+			r.htmlText(w, tok.data)
+		case htmlStartTag:
+			r.htmlStartTag(w, tok)
+			if htmlVoidTags[tok.data] {
+				r.htmlEndTag(w, tok.data)
+			}
+		case htmlSelfClosingTag:
+			r.htmlStartTag(w, tok)
+			r.htmlEndTag(w, tok.data)
+		case htmlEndTag:
+			r.htmlEndTag(w, tok.data)
+		}
+	}
+}
+
+func (r *Renderer) htmlText(w io.Writer, s string) {
+	st := &r.html
+	if st.dropped > 0 {
+		return
+	}
+	if st.pre == 0 {
+		s = collapseHTMLSpace(s)
+		if s == "" || s == " " && r.lastOutputLen == 0 {
+			return
+		}
+	} else {
+		// like in HTML, a newline right after <pre> is ignored
+		if !st.preStarted {
+			s = strings.TrimPrefix(s, "\n")
+		}
+		if s == "" {
+			return
+		}
+		st.preStarted = true
+		st.preNewline = strings.HasSuffix(s, "\n")
+	}
+	r.outs(w, r.escapeInline(s))
+}
+
+// escapeInline escapes text according to the HTML conversion state.
+func (r *Renderer) escapeInline(s string) string {
+	if r.html.script != nil {
+		s = mapRunes(s, r.html.script)
+	}
+	if r.html.code > 0 {
+		return escapeCode(s)
+	}
+	return escapeMarkdownV2(s)
+}
+
+// collapseHTMLSpace replaces runs of whitespace with a single space.
+func collapseHTMLSpace(s string) string {
+	var b strings.Builder
+	space := false
+	for i := 0; i < len(s); i++ {
+		if isHTMLSpace(s[i]) {
+			space = true
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteByte(s[i])
+	}
+	if space {
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
+
+func (r *Renderer) htmlStartTag(w io.Writer, tok htmlToken) {
+	st := &r.html
+	if htmlDroppedTags[tok.data] {
+		st.dropped++
+		r.pushHTML(tok.data, func(w io.Writer) { st.dropped-- })
+		return
+	}
+	if st.dropped > 0 {
+		return
+	}
+	if st.code > 0 && tok.data != "code" && tok.data != "pre" && tok.data != "kbd" && tok.data != "br" {
+		// no entities inside code
+		return
+	}
+
+	switch tok.data {
+	case "b", "strong":
+		r.bold(w, true)
+		r.pushHTML(tok.data, func(w io.Writer) { r.bold(w, false) })
+	case "i", "em", "cite", "var":
+		r.pushHTMLEntity(w, tok.data, "_")
+	case "u", "ins":
+		r.pushHTMLEntity(w, tok.data, "__")
+	case "s", "del", "strike":
+		r.pushHTMLEntity(w, tok.data, "~")
+	case "tg-spoiler":
+		r.pushHTMLEntity(w, tok.data, "||")
+	case "span":
+		if tok.attrs["class"] == "tg-spoiler" {
+			r.pushHTMLEntity(w, tok.data, "||")
+		} else {
+			r.pushHTML(tok.data, nil)
+		}
+	case "code", "kbd", "tt", "samp":
+		if st.code > 0 {
+			r.pushHTML(tok.data, nil)
+			return
+		}
+		st.code++
+		r.outs(w, "`")
+		r.pushHTML(tok.data, func(w io.Writer) {
+			st.code--
+			r.outs(w, "`")
+		})
+	case "pre":
+		if st.code > 0 {
+			r.pushHTML(tok.data, nil)
+			return
+		}
+		st.code++
+		st.pre++
+		st.preStarted, st.preNewline = false, false
+		r.outs(w, "```\n")
+		r.pushHTML(tok.data, func(w io.Writer) {
+			st.code--
+			st.pre--
+			if !st.preNewline {
+				r.outs(w, "\n")
+			}
+			r.outs(w, "```\n")
+		})
+	case "a":
+		href, ok := tok.attrs["href"]
+		if !ok {
+			r.pushHTML(tok.data, nil)
+			return
+		}
+		r.outs(w, "[")
+		r.pushHTML(tok.data, func(w io.Writer) {
+			r.outs(w, "](")
+			r.outs(w, escapeLinkDestination(href))
+			r.outs(w, ")")
+		})
+	case "sup":
+		r.pushScript(tok.data, superscriptRunes)
+	case "sub":
+		r.pushScript(tok.data, subscriptRunes)
+	case "br":
+		r.outs(w, "\n")
+	case "hr":
+		r.outs(w, "\n")
+		r.horizontalRule(w)
+	case "li":
+		r.outs(w, escapeMarkdownV2(r.bullet(1)+" "))
+		r.pushHTML(tok.data, nil)
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		r.bold(w, true)
+		r.pushHTML(tok.data, func(w io.Writer) { r.bold(w, false) })
+	case "img":
+		if alt := tok.attrs["alt"]; alt != "" {
+			r.outs(w, escapeMarkdownV2(alt))
+		}
+	default:
+		if !htmlVoidTags[tok.data] {
+			r.pushHTML(tok.data, nil)
+		}
+	}
+}
+
+func (r *Renderer) htmlEndTag(w io.Writer, name string) {
+	st := &r.html
+	// find the matching start tag, ignore stray end tags
+	i := len(st.open) - 1
+	for i >= 0 && st.open[i].name != name {
+		i--
+	}
+	if i < 0 {
+		return
+	}
+	// close everything that was left open inside of it, too
+	for len(st.open) > i {
+		r.popHTML(w)
+	}
+	if htmlBreakTags[name] && st.dropped == 0 {
+		r.outs(w, "\n")
+	}
+}
+
+func (r *Renderer) pushHTML(name string, close func(w io.Writer)) {
+	r.html.open = append(r.html.open, openHTMLTag{name: name, close: close})
+}
+
+func (r *Renderer) pushHTMLEntity(w io.Writer, name string, marker string) {
+	r.outs(w, marker)
+	r.pushHTML(name, func(w io.Writer) { r.outs(w, marker) })
+}
+
+func (r *Renderer) pushScript(name string, m map[rune]rune) {
+	prev := r.html.script
+	r.html.script = m
+	r.pushHTML(name, func(w io.Writer) { r.html.script = prev })
+}
+
+func (r *Renderer) popHTML(w io.Writer) {
+	st := &r.html
+	tag := st.open[len(st.open)-1]
+	st.open = st.open[:len(st.open)-1]
+	if tag.close != nil {
+		tag.close(w)
+	}
+}
+
+// closeHTML closes entities of HTML tags that were left open, so that they
+// don't leak out of the block they were opened in.
+func (r *Renderer) closeHTML(w io.Writer) {
+	for len(r.html.open) > 0 {
+		r.popHTML(w)
+	}
+}
+
+// escapeLinkDestination escapes the url part of an inline link.
+func escapeLinkDestination(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return strings.ReplaceAll(s, ")", `\)`)
+}
+
+// mapRunes replaces the runes of s that are present in m.
+func mapRunes(s string, m map[rune]rune) string {
+	return strings.Map(func(c rune) rune {
+		if mapped, ok := m[c]; ok {
+			return mapped
+		}
+		return c
+	}, s)
+}
+
+var superscriptRunes = map[rune]rune{
+	'0': '⁰', '1': '¹', '2': '²', '3': '³', '4': '⁴',
+	'5': '⁵', '6': '⁶', '7': '⁷', '8': '⁸', '9': '⁹',
+	'+': '⁺', '-': '⁻', '=': '⁼', '(': '⁽', ')': '⁾',
+	'a': 'ᵃ', 'b': 'ᵇ', 'c': 'ᶜ', 'd': 'ᵈ', 'e': 'ᵉ', 'f': 'ᶠ', 'g': 'ᵍ',
+	'h': 'ʰ', 'i': 'ⁱ', 'j': 'ʲ', 'k': 'ᵏ', 'l': 'ˡ', 'm': 'ᵐ', 'n': 'ⁿ',
+	'o': 'ᵒ', 'p': 'ᵖ', 'r': 'ʳ', 's': 'ˢ', 't': 'ᵗ', 'u': 'ᵘ', 'v': 'ᵛ',
+	'w': 'ʷ', 'x': 'ˣ', 'y': 'ʸ', 'z': 'ᶻ',
+}
+
+var subscriptRunes = map[rune]rune{
+	'0': '₀', '1': '₁', '2': '₂', '3': '₃', '4': '₄',
+	'5': '₅', '6': '₆', '7': '₇', '8': '₈', '9': '₉',
+	'+': '₊', '-': '₋', '=': '₌', '(': '₍', ')': '₎',
+	'a': 'ₐ', 'e': 'ₑ', 'h': 'ₕ', 'i': 'ᵢ', 'j': 'ⱼ', 'k': 'ₖ', 'l': 'ₗ',
+	'm': 'ₘ', 'n': 'ₙ', 'o': 'ₒ', 'p': 'ₚ', 'r': 'ᵣ', 's': 'ₛ', 't': 'ₜ',
+	'u': 'ᵤ', 'v': 'ᵥ', 'x': 'ₓ',
+}
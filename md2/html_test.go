@@ -0,0 +1,81 @@
+package md2
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenizeHTML(t *testing.T) {
+	got := tokenizeHTML(`a <A HREF='x&amp;y' title=t>b</a><br/><!-- c --> 1 < 2`)
+	expected := []htmlToken{
+		{typ: htmlText, data: "a "},
+		{typ: htmlStartTag, data: "a", attrs: map[string]string{"href": "x&y", "title": "t"}},
+		{typ: htmlText, data: "b"},
+		{typ: htmlEndTag, data: "a"},
+		{typ: htmlSelfClosingTag, data: "br"},
+		{typ: htmlComment},
+		{typ: htmlText, data: " 1 < 2"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("\nExpected[%#v]\nGot     [%#v]\n", expected, got)
+	}
+}
+
+func TestRenderInlineHTML(t *testing.T) {
+	var tests = []string{
+		"<b>bold</b> and <strong>strong</strong>\n",
+		"*bold* and *strong*\n\n",
+
+		"<i>a</i> <em>b</em> <u>c</u> <s>d</s> <del>e</del>\n",
+		"_a_ _b_ __c__ ~d~ ~e~\n\n",
+
+		"**bold <b>nested</b>**\n",
+		"*bold nested*\n\n",
+
+		"line<br>break\n",
+		"line\nbreak\n\n",
+
+		"H<sub>2</sub>O and x<sup>n+1</sup>\n",
+		"H₂O and xⁿ⁺¹\n\n",
+
+		"press <kbd>Ctrl</kbd>\n",
+		"press `Ctrl`\n\n",
+
+		"<code>a`b\\c</code>\n",
+		"`a\\`b\\\\c`\n\n",
+
+		"<a href=\"https://example.com/(x)\">link</a>\n",
+		"[link](https://example.com/(x\\))\n\n",
+
+		"<span class=\"tg-spoiler\">hidden</span>\n",
+		"||hidden||\n\n",
+
+		"<unknown foo=\"bar\">text</unknown> <b>unclosed\n",
+		"text *unclosed*\n\n",
+
+		"stray </b> end\n",
+		"stray  end\n\n",
+	}
+	for i := 0; i+1 < len(tests); i += 2 {
+		testRendering(t, tests[i], tests[i+1])
+	}
+}
+
+func TestRenderHTMLBlock(t *testing.T) {
+	var tests = []string{
+		"<div>\n  <p>Hello &amp; <b>welcome</b> 1.5!</p>\n</div>\n",
+		"\nHello & *welcome* 1\\.5\\!\n\n",
+
+		"<div>\n<script>alert(1)</script>\nvisible\n</div>\n",
+		"\nvisible\n\n",
+
+		"<!-- just a comment -->\n",
+		"",
+
+		"<pre>\nif a < b {\n}\n</pre>\n",
+		"\n```\nif a < b {\n}\n```\n\n",
+	}
+	for i := 0; i+1 < len(tests); i += 2 {
+		testRendering(t, tests[i], tests[i+1])
+	}
+}
@@ -6,33 +6,153 @@ import (
 	"github.com/eternalsad/markdownify/parser/latex"
 	"io"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/eternalsad/markdownify/ast"
 )
 
+// HeadingStyle describes how headings of one level are rendered.
+type HeadingStyle struct {
+	Prefix    string // written before the heading text, e.g. an emoji
+	Bold      bool   // render the heading as *bold*
+	Underline bool   // render the heading as __underline__
+	Upper     bool   // convert the heading text to upper case
+	Separator string // if set, written on its own line below the heading
+}
+
+// Default styling used for the blank fields of RendererOptions.
+var (
+	// DefaultHeadings are the heading styles for levels 1 to 4. Deeper levels
+	// use the last one.
+	DefaultHeadings = []HeadingStyle{
+		{Prefix: "📌", Bold: true},
+		{Prefix: "✏️", Bold: true},
+		{Prefix: "📚", Bold: true},
+		{Prefix: "🔖", Bold: true},
+	}
+	// DefaultListBullets are the bullet glyphs for unordered lists, by depth.
+	// Lists nested deeper than len(DefaultListBullets) reuse them from the start.
+	DefaultListBullets = []string{"•", "◦", "▪"}
+	// DefaultListIndent is the blank written for each column of list
+	// indentation. Telegram collapses leading ASCII spaces, U+2007 FIGURE SPACE
+	// survives and is as wide as a digit.
+	DefaultListIndent = "\u2007"
+	// DefaultHorizontalRule is the separator line written for thematic breaks.
+	DefaultHorizontalRule = "──────────"
+	// DefaultTaskUnchecked and DefaultTaskChecked mark task list items.
+	DefaultTaskUnchecked = "☐"
+	DefaultTaskChecked   = "☑"
+)
+
+// RenderNodeFunc allows reusing most of Renderer logic and replacing
+// rendering of some nodes. If it returns false, Renderer.RenderNode
+// will execute its logic. If it returns true, Renderer.RenderNode will
+// skip rendering this node and will return WalkStatus
+type RenderNodeFunc func(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool)
+
+// RendererOptions is a collection of supplementary parameters tweaking
+// the behavior of various parts of MarkdownV2 renderer.
+// Blank fields are replaced by the matching Default* value.
+type RendererOptions struct {
+	// Headings holds the style for each heading level, starting at level 1.
+	// Levels past the end use the last style.
+	Headings []HeadingStyle
+
+	// ListBullets are the bullet glyphs for unordered lists, by depth.
+	ListBullets []string
+	// ListIndent is the blank written for each column of list indentation.
+	ListIndent string
+
+	// HorizontalRule is the separator line written for thematic breaks.
+	HorizontalRule string
+
+	// TaskUnchecked and TaskChecked are written after the bullet of task
+	// list items.
+	TaskUnchecked string
+	TaskChecked   string
+
+	// CodeBlockMaxLines, if > 0, is the number of lines after which a code
+	// block is handled according to CodeBlockOverflow.
+	CodeBlockMaxLines int
+	CodeBlockOverflow CodeOverflow
+
+	// RawMath renders formulas as written instead of replacing LaTeX
+	// commands with Unicode symbols.
+	RawMath bool
+
+	// if set, called at the start of RenderNode(). Allows replacing
+	// rendering of some nodes
+	RenderNodeHook RenderNodeFunc
+}
+
 // Renderer renders to markdown. Allows to convert to a canonnical
 // form
+//
+// Do not create this directly, instead use the NewRenderer function.
 type Renderer struct {
-	orderedListCounter map[int]int
+	Opts RendererOptions
+
+	// Attachments collects code blocks taken out of the text, see
+	// CodeOverflowAttach.
+	Attachments []Attachment
+
+	// stack of lists being rendered, innermost last
+	lists []*listLevel
 	// used to keep track of whether a given list item uses a paragraph
 	// for large spacing.
 	paragraph map[int]bool
 
 	lastOutputLen  int
 	listDepth      int
-	indentSize     int
 	lastNormalText string
 
+	// > 0 while inside bold text, nested bold markers are not written
+	boldDepth int
+	// set while rendering the text of an upper-cased heading
+	upper bool
+	// state of inline HTML conversion
+	html htmlState
+
 	latex *latex.LaTeXToMarkdownV2
 }
 
-// NewRenderer returns a Markdown renderer.
-func NewRenderer() *Renderer {
+// definitionIndent is the number of ListIndent blanks before definitions in
+// definition lists.
+const definitionIndent = 4
+
+// listLevel is the rendering state of a single (possibly nested) list.
+type listLevel struct {
+	ordered bool
+	counter int
+	// written at the start of continuation lines of the current item, so
+	// that they line up under the item text
+	indent string
+}
+
+// NewRenderer returns a MarkdownV2 renderer.
+func NewRenderer(opts RendererOptions) *Renderer {
+	if opts.Headings == nil {
+		opts.Headings = DefaultHeadings
+	}
+	if opts.ListBullets == nil {
+		opts.ListBullets = DefaultListBullets
+	}
+	if opts.ListIndent == "" {
+		opts.ListIndent = DefaultListIndent
+	}
+	if opts.HorizontalRule == "" {
+		opts.HorizontalRule = DefaultHorizontalRule
+	}
+	if opts.TaskUnchecked == "" {
+		opts.TaskUnchecked = DefaultTaskUnchecked
+	}
+	if opts.TaskChecked == "" {
+		opts.TaskChecked = DefaultTaskChecked
+	}
 	return &Renderer{
-		orderedListCounter: map[int]int{},
-		paragraph:          map[int]bool{},
-		indentSize:         4,
-		latex:              latex.NewLaTeXToMarkdownV2(),
+		Opts:      opts,
+		paragraph: map[int]bool{},
+		latex:     latex.NewLaTeXToMarkdownV2(),
 	}
 }
 
@@ -53,72 +173,112 @@ func (r *Renderer) doubleSpace(w io.Writer) {
 	//}
 }
 
+// listIndent returns the indentation of continuation lines of the innermost
+// list item, or "" outside of lists.
+func (r *Renderer) listIndent() string {
+	if len(r.lists) == 0 {
+		return ""
+	}
+	return r.lists[len(r.lists)-1].indent
+}
+
+// bullet returns the bullet glyph for an unordered list at the given depth,
+// starting at 1.
+func (r *Renderer) bullet(depth int) string {
+	bullets := r.Opts.ListBullets
+	if len(bullets) == 0 {
+		return DefaultListBullets[(depth-1)%len(DefaultListBullets)]
+	}
+	return bullets[(depth-1)%len(bullets)]
+}
+
 func (r *Renderer) list(w io.Writer, node *ast.List, entering bool) {
 	if entering {
-		r.listDepth++
-		flags := node.ListFlags
-		if flags&ast.ListTypeOrdered != 0 {
-			r.orderedListCounter[r.listDepth] = 1
+		level := &listLevel{
+			ordered: node.ListFlags&ast.ListTypeOrdered != 0,
+			counter: 1,
+			indent:  r.listIndent(),
 		}
+		if node.Start > 0 {
+			level.counter = node.Start
+		}
+		r.lists = append(r.lists, level)
+		r.listDepth++
 	} else {
+		r.lists = r.lists[:len(r.lists)-1]
 		r.listDepth--
-		fmt.Fprintf(w, "\n")
+		if r.listDepth == 0 {
+			r.outs(w, "\n")
+		}
 	}
 }
 
 func (r *Renderer) listItem(w io.Writer, node *ast.ListItem, entering bool) {
-	flags := node.ListFlags
-	bullet := string(node.BulletChar)
-
-	if entering {
-		//for i := 1; i < r.listDepth; i++ {
-		//	for i := 0; i < r.indentSize; i++ {
-		//		fmt.Fprintf(w, " ")
-		//	}
-		//}
+	if !entering {
+		return
+	}
+	level := r.lists[len(r.lists)-1]
+	// nested items line up with the text of the enclosing item
+	parentIndent := ""
+	if len(r.lists) > 1 {
+		parentIndent = r.lists[len(r.lists)-2].indent
+	}
 
-		if r.listDepth >= 1 {
-			fmt.Fprintf(w, (strings.Repeat(" ", (r.listDepth-1)*r.indentSize)))
-		}
+	// definition lists have no markers, terms are bold (see para) and
+	// definitions are indented under them
+	if node.ListFlags&ast.ListTypeTerm != 0 {
+		level.indent = parentIndent
+		r.outs(w, parentIndent)
+		return
+	}
+	if node.ListFlags&ast.ListTypeDefinition != 0 {
+		level.indent = parentIndent + strings.Repeat(r.Opts.ListIndent, definitionIndent)
+		r.outs(w, level.indent)
+		return
+	}
 
-		if flags&ast.ListTypeOrdered != 0 {
-			fmt.Fprintf(w, "%d\\. ", r.orderedListCounter[r.listDepth])
-			r.orderedListCounter[r.listDepth]++
+	var marker string
+	if level.ordered {
+		marker = fmt.Sprintf("%d. ", level.counter)
+		level.counter++
+	} else {
+		marker = r.bullet(len(r.lists)) + " "
+	}
+	if node.IsTask {
+		if node.Checked {
+			marker += r.Opts.TaskChecked + " "
 		} else {
-			fmt.Fprintf(w, "\\%s ", bullet)
+			marker += r.Opts.TaskUnchecked + " "
 		}
 	}
+	level.indent = parentIndent + strings.Repeat(r.Opts.ListIndent, utf8.RuneCountInString(marker))
+
+	r.outs(w, parentIndent)
+	r.outs(w, escapeMarkdownV2(marker))
 }
 
 func (r *Renderer) para(w io.Writer, node *ast.Paragraph, entering bool) {
-	parent := node.GetParent()
-	secondListItem := false
+	item, inListItem := node.Parent.(*ast.ListItem)
+	isTerm := inListItem && item.ListFlags&ast.ListTypeTerm != 0
 
-	switch parent.(type) {
-	case *ast.ListItem:
-		parentList, ok := parent.(*ast.ListItem)
-		if !ok {
-			break
-		}
+	// paragraphs after the first one in a list item continue under its text
+	if entering && inListItem && ast.GetFirstChild(node.Parent) != node {
+		r.outs(w, r.listIndent())
+	}
 
-		if len(parent.GetChildren()) > 1 {
-			if node.AsContainer() == parentList.GetChildren()[1].AsContainer() {
-				secondListItem = true
-			}
-		}
+	if !entering {
+		r.closeHTML(w)
 	}
 
-	if entering && secondListItem {
-		if secondListItem {
-			r.outs(w, "\t")
-		}
+	if isTerm {
+		r.bold(w, entering)
 	}
 
 	if !entering && r.lastOutputLen > 0 {
 		var br = "\n\n"
 
 		// List items don't need the extra line-break.
-		if _, ok := node.Parent.(*ast.ListItem); ok {
+		if inListItem {
 			br = "\n"
 		}
 
@@ -127,10 +287,6 @@ func (r *Renderer) para(w io.Writer, node *ast.Paragraph, entering bool) {
 }
 
 // escape replaces instances of backslash with escaped backslash in text.
-func escape(text []byte) []byte {
-	return bytes.Replace(text, []byte(`\`), []byte(`\\`), -1)
-}
-
 func isNumber(data []byte) bool {
 	for _, b := range data {
 		if b < '0' || b > '9' {
@@ -204,9 +360,15 @@ func (r *Renderer) skipSpaceIfNeededNormalText(w io.Writer, cleanString string)
 }
 
 func (r *Renderer) text(w io.Writer, text *ast.Text) {
-	lit := []byte(escapeMarkdownV2(string(text.Literal)))
 	normalText := string(text.Literal)
-	if needsEscaping(lit, r.lastNormalText) {
+	if r.html.dropped > 0 {
+		return
+	}
+	if r.upper {
+		normalText = strings.ToUpper(normalText)
+	}
+	lit := []byte(r.escapeInline(normalText))
+	if r.html.code == 0 && needsEscaping(lit, r.lastNormalText) {
 		lit = append([]byte("\\"), lit...)
 	}
 	r.lastNormalText = normalText
@@ -235,48 +397,22 @@ func (r *Renderer) surround(w io.Writer, symbol string) {
 }
 
 func (r *Renderer) htmlSpan(w io.Writer, node *ast.HTMLSpan) {
-	r.out(w, node.Literal)
+	r.rawHTML(w, string(node.Literal))
 }
 
 func (r *Renderer) htmlBlock(w io.Writer, node *ast.HTMLBlock) {
+	var buf bytes.Buffer
+	r.rawHTML(&buf, string(node.Literal))
+	r.closeHTML(&buf)
+	text := strings.Trim(buf.String(), " \n")
+	if text == "" {
+		return
+	}
 	r.doubleSpace(w)
-	r.out(w, node.Literal)
+	r.outs(w, text)
 	r.outs(w, "\n\n")
 }
 
-func (r *Renderer) codeBlock(w io.Writer, node *ast.CodeBlock) {
-	r.doubleSpace(w)
-	text := node.Literal
-	lang := string(node.Info)
-	// Parse out the language name.
-	count := 0
-	for _, elt := range strings.Fields(lang) {
-		if elt[0] == '.' {
-			elt = elt[1:]
-		}
-		if len(elt) == 0 {
-			continue
-		}
-		r.outs(w, "```")
-		r.outs(w, elt)
-		count++
-		break
-	}
-
-	if count == 0 {
-		r.outs(w, "```")
-	}
-	r.outs(w, "\n")
-	r.out(w, text)
-	r.outs(w, "```\n\n")
-}
-
-func (r *Renderer) code(w io.Writer, node *ast.Code) {
-	r.outs(w, "`")
-	r.out(w, node.Literal)
-	r.outs(w, "`")
-}
-
 //// Рендерер для заголовка таблицы
 //func (r *Renderer) tableHeader(w io.Writer, node *ast.TableHeader, entering bool) {
 //	if !entering {
@@ -467,7 +603,7 @@ func (r *Renderer) table(w io.Writer, node *ast.Table) {
 				paddedContent = paddedContent[:columnWidths[j]-3] + "..."
 			}
 
-			fmt.Fprint(w, paddedContent)
+			fmt.Fprint(w, escapeCode(paddedContent))
 
 			if j < numColumns-1 {
 				fmt.Fprint(w, " | ")
@@ -557,21 +693,6 @@ func renderNodeText(w io.Writer, node ast.Node) {
 	}
 }
 
-// Чтобы избежать дублирования таблиц, нужно отслеживать обработанные таблицы
-// Для этого можно использовать глобальную переменную или добавить метод в рендерер
-
-// Карта для отслеживания обработанных таблиц
-var processedTables = make(map[*ast.Table]bool)
-
-// Метод для проверки, была ли таблица уже обработана
-func (r *Renderer) wasTableProcessed(table *ast.Table) bool {
-	if processedTables[table] {
-		return true
-	}
-	processedTables[table] = true
-	return false
-}
-
 // Проверяет, находится ли узел внутри таблицы
 func isInsideTable(node ast.Node) bool {
 	for node != nil {
@@ -626,15 +747,14 @@ func (r *Renderer) tableCell(w io.Writer, node *ast.TableCell) {
 	// Обработка происходит в методе table
 }
 
-var headings = map[int]string{
-	0: "📌",
-	1: "✏️",
-	2: "📚",
-	3: "🔖",
+// Escape escapes the characters reserved in Markdown V2, so that text is
+// shown as it is written.
+func Escape(text string) string {
+	return escapeMarkdownV2(text)
 }
 
 func escapeMarkdownV2(text string) string {
-	specialChars := []string{"_", "*", "[", "]", "(", ")", "~", "`", ">", "#", "+", "-", "=", "|", "{", "}", ".", "!"}
+	specialChars := []string{`\`, "_", "*", "[", "]", "(", ")", "~", "`", ">", "#", "+", "-", "=", "|", "{", "}", ".", "!"}
 	result := text
 	for _, char := range specialChars {
 		result = strings.ReplaceAll(result, char, "\\"+char)
@@ -642,18 +762,80 @@ func escapeMarkdownV2(text string) string {
 	return result
 }
 
+// headingStyle returns the style for headings of the given level.
+func (r *Renderer) headingStyle(level int) HeadingStyle {
+	styles := r.Opts.Headings
+	if len(styles) == 0 {
+		return HeadingStyle{}
+	}
+	if level < 1 {
+		level = 1
+	}
+	if level > len(styles) {
+		level = len(styles)
+	}
+	return styles[level-1]
+}
+
+// escapeCode escapes text inside of code and pre entities, where only
+// backquotes and backslashes have to be escaped.
+func escapeCode(text string) string {
+	text = strings.ReplaceAll(text, `\`, `\\`)
+	return strings.ReplaceAll(text, "`", "\\`")
+}
+
 func (r *Renderer) heading(w io.Writer, node *ast.Heading, entering bool) {
+	style := r.headingStyle(node.Level)
 	if entering {
-		r.out(w, []byte("*"))
-		r.outs(w, headings[node.Level])
-		r.outs(w, " ")
-		r.out(w, []byte(escapeMarkdownV2(string(node.Literal))))
+		if style.Bold {
+			r.bold(w, true)
+		}
+		if style.Underline {
+			r.outs(w, "__")
+		}
+		if style.Prefix != "" {
+			r.outs(w, escapeMarkdownV2(style.Prefix))
+			r.outs(w, " ")
+		}
+		r.upper = style.Upper
 	} else {
-		r.out(w, []byte("*"))
+		r.closeHTML(w)
+		r.upper = false
+		if style.Underline {
+			r.outs(w, "__")
+		}
+		if style.Bold {
+			r.bold(w, false)
+		}
+		if style.Separator != "" {
+			r.outs(w, "\n")
+			r.outs(w, escapeMarkdownV2(style.Separator))
+		}
 		r.outs(w, "\n\n")
 	}
 }
 
+// bold opens or closes bold text. Bold can't be nested in MarkdownV2, so
+// only the outermost markers are written.
+func (r *Renderer) bold(w io.Writer, entering bool) {
+	if entering {
+		if r.boldDepth == 0 {
+			r.outs(w, "*")
+		}
+		r.boldDepth++
+	} else {
+		r.boldDepth--
+		if r.boldDepth == 0 {
+			r.outs(w, "*")
+		}
+	}
+}
+
+func (r *Renderer) horizontalRule(w io.Writer) {
+	r.outs(w, escapeMarkdownV2(r.Opts.HorizontalRule))
+	r.outs(w, "\n\n")
+}
+
 func (r *Renderer) image(w io.Writer, node *ast.Image, entering bool) {
 	if entering {
 		// alt := node. ??
@@ -664,7 +846,7 @@ func (r *Renderer) image(w io.Writer, node *ast.Image, entering bool) {
 		link := node.Destination
 		title := node.Title
 		r.outs(w, "](")
-		r.out(w, escape(link))
+		r.outs(w, escapeLinkDestination(string(link)))
 		if len(title) != 0 {
 			r.outs(w, ` "`)
 			r.out(w, title)
@@ -678,7 +860,7 @@ func (r *Renderer) link(w io.Writer, node *ast.Link, entering bool) {
 	if entering {
 		r.outs(w, "[")
 	} else {
-		link := string(escape(node.Destination))
+		link := escapeLinkDestination(string(node.Destination))
 		title := string(node.Title)
 		r.outs(w, "](")
 		r.outs(w, link)
@@ -691,15 +873,41 @@ func (r *Renderer) link(w io.Writer, node *ast.Link, entering bool) {
 	}
 }
 
+// script writes sub- or superscript text with the Unicode forms of its
+// characters, as for <sub> and <sup>.
+func (r *Renderer) script(w io.Writer, literal []byte, m map[rune]rune) {
+	if r.html.dropped > 0 {
+		return
+	}
+	r.outs(w, r.escapeInline(mapRunes(string(literal), m)))
+}
+
+// citation writes the cited references in brackets.
+func (r *Renderer) citation(w io.Writer, node *ast.Citation) {
+	var refs []string
+	for _, dest := range node.Destination {
+		refs = append(refs, string(dest))
+	}
+	r.outs(w, r.escapeInline("["+strings.Join(refs, ", ")+"]"))
+}
+
+// caption writes the caption of a figure in italics on its own line.
+func (r *Renderer) caption(w io.Writer, entering bool) {
+	if entering {
+		r.outs(w, "_")
+		return
+	}
+	r.outs(w, "_\n\n")
+}
+
 // RenderNode renders markdown node
 func (r *Renderer) RenderNode(w io.Writer, node ast.Node, entering bool) ast.WalkStatus {
-	// Проверка дублирования для таблиц
-	if table, ok := node.(*ast.Table); ok {
-		if r.wasTableProcessed(table) {
-			return ast.GoToNext
+	if r.Opts.RenderNodeHook != nil {
+		status, didHandle := r.Opts.RenderNodeHook(w, node, entering)
+		if didHandle {
+			return status
 		}
 	}
-
 	switch node := node.(type) {
 	case *ast.Text:
 		//// Проверяем, не находится ли текст сразу после таблицы
@@ -720,26 +928,24 @@ func (r *Renderer) RenderNode(w io.Writer, node ast.Node, entering bool) ast.Wal
 		//}
 
 		r.text(w, node)
-	case *ast.Softbreak:
-		panic(fmt.Sprintf("node %T NYI", node))
-	case *ast.Hardbreak:
-		panic(fmt.Sprintf("node %T NYI", node))
+	case *ast.Softbreak, *ast.Hardbreak:
+		r.outs(w, "\n")
 	case *ast.Emph:
 		r.surround(w, "_")
 	case *ast.Strong:
-		r.surround(w, "*")
+		r.bold(w, entering)
 	case *ast.Del:
 		r.surround(w, "~")
 	case *ast.BlockQuote:
 		r.blockQuote(w, node)
 	case *ast.Aside:
-		panic(fmt.Sprintf("node %T NYI", node))
+		// MarkdownV2 has no asides, the content is rendered as is
 	case *ast.Link:
 		r.link(w, node, entering)
 	case *ast.CrossReference:
-		panic(fmt.Sprintf("node %T NYI", node))
+		// the text of the reference is rendered, there is nothing to link to
 	case *ast.Citation:
-		panic(fmt.Sprintf("node %T NYI", node))
+		r.citation(w, node)
 	case *ast.Image:
 		r.image(w, node, entering)
 	case *ast.Code:
@@ -747,9 +953,9 @@ func (r *Renderer) RenderNode(w io.Writer, node ast.Node, entering bool) ast.Wal
 	case *ast.CodeBlock:
 		r.codeBlock(w, node)
 	case *ast.Caption:
-		panic(fmt.Sprintf("node %T NYI", node))
+		r.caption(w, entering)
 	case *ast.CaptionFigure:
-		panic(fmt.Sprintf("node %T NYI", node))
+		// the figure and its caption are rendered one after another
 	case *ast.Document:
 		// do nothing
 	case *ast.Paragraph:
@@ -761,12 +967,16 @@ func (r *Renderer) RenderNode(w io.Writer, node ast.Node, entering bool) ast.Wal
 	case *ast.Heading:
 		r.heading(w, node, entering)
 	case *ast.HorizontalRule:
+		r.horizontalRule(w)
 	case *ast.List:
 		r.list(w, node, entering)
 	case *ast.ListItem:
 		r.listItem(w, node, entering)
 	case *ast.Table:
-		r.table(w, node)
+		// Таблица рендерится целиком при входе, на выходе ничего не делаем
+		if entering {
+			r.table(w, node)
+		}
 		return ast.SkipChildren // Пропускаем внутренний рендеринг таблицы
 
 	case *ast.TableCell:
@@ -784,19 +994,20 @@ func (r *Renderer) RenderNode(w io.Writer, node ast.Node, entering bool) ast.Wal
 	case *ast.MathBlock:
 		r.mathBlock(w, node, entering)
 	case *ast.DocumentMatter:
-		panic(fmt.Sprintf("node %T NYI", node))
+		// front, main and back matter only divide the document
 	case *ast.Callout:
-		panic(fmt.Sprintf("node %T NYI", node))
+		r.outs(w, r.escapeInline("("+string(node.ID)+")"))
 	case *ast.Index:
-		panic(fmt.Sprintf("node %T NYI", node))
+		// index terms are not shown in the text
 	case *ast.Subscript:
-		panic(fmt.Sprintf("node %T NYI", node))
+		r.script(w, node.Literal, subscriptRunes)
 	case *ast.Superscript:
-		panic(fmt.Sprintf("node %T NYI", node))
+		r.script(w, node.Literal, superscriptRunes)
 	case *ast.Footnotes:
 		// nothing by default; just output the list.
 	default:
-		panic(fmt.Sprintf("Unknown node %T", node))
+		// the other nodes have no MarkdownV2 equivalent, their children are
+		// rendered as they are
 	}
 	return ast.GoToNext
 }
@@ -808,26 +1019,32 @@ func (r *Renderer) RenderHeader(w io.Writer, ast ast.Node) {
 
 // RenderFooter renders footer
 func (r *Renderer) RenderFooter(w io.Writer, ast ast.Node) {
-	// do nothing
+	r.closeHTML(w)
+}
+
+// mathText returns the text of a formula as it is shown, see
+// RendererOptions.RawMath.
+func (r *Renderer) mathText(literal []byte) string {
+	if r.Opts.RawMath {
+		return string(literal)
+	}
+	return r.latex.ToUnicode(string(literal))
 }
 
 func (r *Renderer) math(w io.Writer, node *ast.Math) {
 	r.outs(w, "` ")
-	r.out(w, []byte(escapeMarkdownV2(string(node.Literal))))
+	r.outs(w, escapeCode(r.mathText(node.Literal)))
 	r.outs(w, " `")
 }
 
 func (r *Renderer) mathBlock(w io.Writer, node *ast.MathBlock, entering bool) {
-	if r.latex.ContainsLaTeXSymbols(string(node.Literal)) {
+	if !entering {
 		return
 	}
-
-	if entering {
-		r.outs(w, "```\n")
-		r.out(w, []byte(escapeMarkdownV2(string(node.Literal))))
-	} else {
-		r.outs(w, "\n```")
-	}
+	r.doubleSpace(w)
+	r.outs(w, "```\n")
+	r.outs(w, escapeCode(strings.TrimSpace(r.mathText(node.Literal))))
+	r.outs(w, "\n```\n\n")
 }
 
 func (r *Renderer) blockQuote(w io.Writer, node *ast.BlockQuote) {
@@ -0,0 +1,169 @@
+package md2
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/eternalsad/markdownify/ast"
+	"github.com/eternalsad/markdownify/parser"
+)
+
+func renderString(r *Renderer, source string) string {
+	exts := parser.CommonExtensions | parser.OrderedListStart | parser.TaskLists
+	doc := parser.NewWithExtensions(exts).Parse([]byte(source))
+	var buf bytes.Buffer
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		return r.RenderNode(&buf, node, entering)
+	})
+	return buf.String()
+}
+
+func testRendering(t *testing.T, source string, expected string) {
+	t.Helper()
+	got := renderString(NewRenderer(RendererOptions{}), source)
+	if got != expected {
+		t.Errorf("\nInput   [%#v]\nExpected[%#v]\nGot     [%#v]\n", source, expected, got)
+	}
+}
+
+func TestRenderNestedList(t *testing.T) {
+	source := "- aaa\n    - bbb\n        - ccc\n        - ddd\n- eee\n"
+	expected := "• aaa\n" +
+		"\u2007\u2007◦ bbb\n" +
+		"\u2007\u2007\u2007\u2007▪ ccc\n" +
+		"\u2007\u2007\u2007\u2007▪ ddd\n" +
+		"• eee\n\n"
+	testRendering(t, source, expected)
+}
+
+func TestRenderOrderedListStart(t *testing.T) {
+	testRendering(t, "3. aaa\n4. bbb\n", "3\\. aaa\n4\\. bbb\n\n")
+	testRendering(t, "1. aaa\n1. bbb\n", "1\\. aaa\n2\\. bbb\n\n")
+}
+
+func TestRenderListContinuation(t *testing.T) {
+	source := "1. aaa\n\n   bbb\n2. ccc\n"
+	expected := "1\\. aaa\n\u2007\u2007\u2007bbb\n2\\. ccc\n\n"
+	testRendering(t, source, expected)
+
+	source = "10. aaa\n    - bbb\n"
+	expected = "10\\. aaa\n\u2007\u2007\u2007\u2007◦ bbb\n\n"
+	testRendering(t, source, expected)
+}
+
+func TestRenderListOptions(t *testing.T) {
+	r := NewRenderer(RendererOptions{
+		ListBullets: []string{"-", "*"},
+		ListIndent:  "\u2800",
+	})
+	got := renderString(r, "- aaa\n    - bbb\n        - ccc\n")
+	expected := "\\- aaa\n\u2800\u2800\\* bbb\n\u2800\u2800\u2800\u2800\\- ccc\n\n"
+	if got != expected {
+		t.Errorf("\nExpected[%#v]\nGot     [%#v]\n", expected, got)
+	}
+}
+
+func TestRenderTaskList(t *testing.T) {
+	testRendering(t, "- [ ] aaa\n- [x] bbb\n", "• ☐ aaa\n• ☑ bbb\n\n")
+}
+
+func TestRenderHeading(t *testing.T) {
+	testRendering(t, "# Title\n", "*📌 Title*\n\n")
+	testRendering(t, "##### Deep\n", "*🔖 Deep*\n\n")
+	testRendering(t, "## With **bold** text\n", "*✏️ With bold text*\n\n")
+}
+
+func TestRenderHeadingOptions(t *testing.T) {
+	r := NewRenderer(RendererOptions{
+		Headings: []HeadingStyle{
+			{Underline: true, Upper: true, Separator: "──────"},
+			{Prefix: "#"},
+		},
+	})
+	got := renderString(r, "# Title v1\n\n## Sub\n\n### Sub sub\n")
+	expected := "__TITLE V1__\n──────\n\n\\# Sub\n\n\\# Sub sub\n\n"
+	if got != expected {
+		t.Errorf("\nExpected[%#v]\nGot     [%#v]\n", expected, got)
+	}
+}
+
+func TestRenderHorizontalRule(t *testing.T) {
+	testRendering(t, "aaa\n\n---\n\nbbb\n", "aaa\n\n──────────\n\nbbb\n\n")
+
+	r := NewRenderer(RendererOptions{HorizontalRule: "- - -"})
+	got := renderString(r, "***\n")
+	expected := "\\- \\- \\-\n\n"
+	if got != expected {
+		t.Errorf("\nExpected[%#v]\nGot     [%#v]\n", expected, got)
+	}
+}
+
+func TestRenderDefinitionList(t *testing.T) {
+	source := "Term 1\n: Definition a\n\nTerm 2\n: Definition b\n: Definition c\n"
+	expected := "*Term 1*\n" +
+		"\u2007\u2007\u2007\u2007Definition a\n" +
+		"*Term 2*\n" +
+		"\u2007\u2007\u2007\u2007Definition b\n" +
+		"\u2007\u2007\u2007\u2007Definition c\n\n"
+	testRendering(t, source, expected)
+}
+
+func TestRenderMathBlock(t *testing.T) {
+	testRendering(t, "$$\n\\sum_i x_i \\leq \\pi\n$$\n", "\n```\n∑_i x_i ≤ π\n```\n\n")
+	testRendering(t, "$$\nx^2 + y^2\n$$\n", "\n```\nx^2 + y^2\n```\n\n")
+}
+
+func TestRenderLinkDestination(t *testing.T) {
+	testRendering(t, "[a](http://x/a_(b))", "[a](http://x/a_(b\\))\n\n")
+	testRendering(t, `[a](http://x/a\\b)`, "[a](http://x/a\\\\b)\n\n")
+}
+
+func TestRenderExtensionNodes(t *testing.T) {
+	exts := parser.CommonExtensions | parser.SuperSubscript | parser.Mmark
+	tests := map[string]string{
+		"foo  \nbar":           "foo\nbar\n\n",
+		"foo\\\nbar":           "foo\nbar\n\n",
+		"H~2~O and 2^10^":      "H₂O and 2¹⁰\n\n",
+		"A> aside text":        "aside text\n\n",
+		"See [@RFC2535] now":   "See \\[RFC2535\\] now\n\n",
+		"Text (!item) here":    "Text  here\n\n",
+		"{mainmatter}\nbody\n": "body\n\n",
+	}
+	for source, expected := range tests {
+		doc := parser.NewWithExtensions(exts).Parse([]byte(source))
+		var buf bytes.Buffer
+		r := NewRenderer(RendererOptions{})
+		ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+			return r.RenderNode(&buf, node, entering)
+		})
+		if got := buf.String(); got != expected {
+			t.Errorf("\nInput   [%#v]\nExpected[%#v]\nGot     [%#v]\n", source, expected, got)
+		}
+	}
+}
+
+// customNode is a node type the renderer doesn't know.
+type customNode struct {
+	ast.Container
+}
+
+func TestRenderUnknownNode(t *testing.T) {
+	doc := parser.New().Parse([]byte("a **b**\n"))
+	para := ast.GetFirstChild(doc)
+	node := &customNode{}
+	node.SetChildren(para.GetChildren())
+	for _, child := range node.Children {
+		child.SetParent(node)
+	}
+	para.SetChildren(nil)
+	ast.AppendChild(para, node)
+
+	var buf bytes.Buffer
+	r := NewRenderer(RendererOptions{})
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		return r.RenderNode(&buf, node, entering)
+	})
+	if got, expected := buf.String(), "a *b*\n\n"; got != expected {
+		t.Errorf("\nExpected[%#v]\nGot     [%#v]\n", expected, got)
+	}
+}
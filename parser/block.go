@@ -1293,6 +1293,32 @@ func (p *Parser) list(data []byte, flags ast.ListType, start int, delim byte) in
 	return i
 }
 
+// taskListMarker returns the length of a leading GFM task marker ("[ ] ",
+// "[x] " or "[X] ") including the whitespace after it, and whether the task
+// is checked. It returns 0 if data doesn't start with a task marker.
+func taskListMarker(data []byte) (int, bool) {
+	if len(data) < 3 || data[0] != '[' || data[2] != ']' {
+		return 0, false
+	}
+	var checked bool
+	switch data[1] {
+	case ' ':
+		checked = false
+	case 'x', 'X':
+		checked = true
+	default:
+		return 0, false
+	}
+	i := 3
+	if i < len(data) && data[i] != ' ' && data[i] != '\t' && data[i] != '\n' {
+		return 0, false
+	}
+	for i < len(data) && (data[i] == ' ' || data[i] == '\t') {
+		i++
+	}
+	return i, checked
+}
+
 // Returns true if the list item is not the same type as its parent list
 func (p *Parser) listTypeChanged(data []byte, flags *ast.ListType) bool {
 	if p.dliPrefix(data) > 0 && *flags&ast.ListTypeDefinition == 0 {
@@ -1509,9 +1535,11 @@ gatherlines:
 			*flags |= ast.ListItemContainsBlock
 
 		// anything following an empty line is only part
-		// of this item if it is indented 4 spaces
-		// (regardless of the indentation of the beginning of the item)
-		case containsBlankLine && indent < itemIndent:
+		// of this item if it is indented more than the item
+		//
+		// in definition lists an unindented line after an empty line is
+		// the next term
+		case containsBlankLine && (indent <= itemIndent || isDefinitionList && indent < 4):
 			if *flags&ast.ListTypeDefinition != 0 && i < len(data)-1 {
 				// is the next item still a part of this list?
 				next := skipUntilChar(data, i, '\n')
@@ -1553,6 +1581,16 @@ gatherlines:
 		BulletChar: bulletChar,
 		Delimiter:  delimiter,
 	}
+	if p.extensions&TaskLists != 0 && *flags&(ast.ListTypeDefinition|ast.ListTypeTerm) == 0 {
+		if n, checked := taskListMarker(rawBytes); n > 0 {
+			listItem.IsTask = true
+			listItem.Checked = checked
+			rawBytes = rawBytes[n:]
+			if sublist > 0 {
+				sublist -= n
+			}
+		}
+	}
 	p.AddBlock(listItem)
 
 	// render the contents of the list item
@@ -107,3 +107,37 @@ func TestBug325(t *testing.T) {
 			input, exp, got)
 	}
 }
+
+// Text after a blank line belongs to a list item only if it is indented
+// more than the item marker, otherwise it ends the list.
+func TestListItemBlankLine(t *testing.T) {
+	tests := []struct {
+		input string
+		exp   string
+	}{
+		{
+			"- a\n\nparagraph\n",
+			"List 'tight flags=start'\n  ListItem 'flags=start end'\n    Paragraph\n      Text 'a'\nParagraph\n  Text 'paragraph'\n",
+		},
+		{
+			"- a\n\n  continued\n",
+			"List 'flags=start'\n  ListItem 'flags=has_block start'\n    Paragraph\n      Text 'a'\n    Paragraph\n      Text 'continued'\n",
+		},
+		{
+			"1. a\n\n   b\n\nc\n",
+			"List 'flags=ordered start'\n  ListItem 'flags=ordered has_block start end'\n    Paragraph\n      Text 'a'\n    Paragraph\n      Text 'b'\nParagraph\n  Text 'c'\n",
+		},
+		// in definition lists an unindented line is the next term
+		{
+			"Term\n: def\n\nNext\n: def2\n",
+			"List 'tight flags=definition start'\n  ListItem 'flags=definition term start'\n    Paragraph\n      Text 'Term'\n  ListItem 'flags=definition'\n    Paragraph\n      Text 'def'\n  ListItem 'flags=definition term'\n    Paragraph\n      Text 'Next'\n  ListItem 'flags=definition'\n    Paragraph\n      Text 'def2'\n",
+		},
+	}
+	for _, test := range tests {
+		p := NewWithExtensions(CommonExtensions)
+		got := astToString(p.Parse([]byte(test.input)))
+		if got != test.exp {
+			t.Errorf("\nInput   [%#v]\nExpected[%#v]\nGot     [%#v]\n", test.input, test.exp, got)
+		}
+	}
+}
@@ -243,6 +243,11 @@ func (l *LaTeXToMarkdownV2) ContainsLaTeXSymbols(content string) bool {
 	return false
 }
 
+// ToUnicode заменяет LaTeX команды в формуле символами Unicode
+func (l *LaTeXToMarkdownV2) ToUnicode(content string) string {
+	return l.convertLaTeXToUnicode(content)
+}
+
 func (l *LaTeXToMarkdownV2) convertLaTeXToUnicode(content string) string {
 	// 1. Сортируем символы по длине (от длинных к коротким)
 	type symbolPair struct {
@@ -354,16 +359,14 @@ func (l *LaTeXToMarkdownV2) EscapeLaTeX(text []byte) []byte {
 			return match[0] // Возвращаем без изменений, если нет LaTeX символов
 		}
 
-		// Преобразуем LaTeX в Unicode
+		// Преобразуем LaTeX в Unicode. Экранирование делает рендерер,
+		// результат попадает в блок кода или код
 		converted := l.convertLaTeXToUnicode(content)
 
-		// Экранируем для Markdown V2
-		escaped := escapeMarkdownV2(converted)
-
 		if isBlock {
-			return fmt.Sprintf("```\n%s\n```", strings.TrimSpace(escaped))
+			return fmt.Sprintf("```\n%s\n```", strings.TrimSpace(converted))
 		}
-		return fmt.Sprintf("`%s`", strings.TrimSpace(escaped))
+		return fmt.Sprintf("`%s`", strings.TrimSpace(converted))
 	}
 
 	// Разбиваем текст на параграфы
@@ -388,15 +391,3 @@ func (l *LaTeXToMarkdownV2) EscapeLaTeX(text []byte) []byte {
 
 	return []byte(strings.Join(processedLines, "\n\n"))
 }
-
-// escapeMarkdownV2 экранирует специальные символы для Markdown V2
-func escapeMarkdownV2(text string) string {
-	specialChars := []string{"_", "*", "[", "]", "(", ")", "~", "`", ">", "#", "+", "-", "=", "|", "{", "}", ".", "!"}
-
-	result := text
-	for _, char := range specialChars {
-		result = strings.ReplaceAll(result, char, "\\"+char)
-	}
-
-	return result
-}
@@ -46,6 +46,8 @@ const (
 	EmptyLinesBreakList                           // 2 empty lines break out of list
 	Includes                                      // Support including other files.
 	Mmark                                         // Support Mmark syntax, see https://mmark.miek.nl/post/syntax/
+	TaskLists                                     // GFM task list items: - [ ] todo, - [x] done
+	NoLaTeXUnicode                                // Keep \[ \] and \( \) formulas as written instead of converting them to Unicode code
 
 	CommonExtensions Extensions = NoIntraEmphasis | Tables | FencedCode |
 		Autolink | Strikethrough | SpaceHeadings | HeadingIDs |
@@ -310,7 +312,9 @@ func (p *Parser) Parse(input []byte) ast.Node {
 	// callers normalize newlines
 	input = NormalizeNewlines(input)
 
-	input = p.latex2Unicode.EscapeLaTeX(input)
+	if p.extensions&NoLaTeXUnicode == 0 {
+		input = p.latex2Unicode.EscapeLaTeX(input)
+	}
 
 	p.Block(input)
 	// Walk the tree and finish up some of unfinished blocks
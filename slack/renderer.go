@@ -0,0 +1,244 @@
+// Package slack renders a Markdown AST to Slack's mrkdwn format.
+//
+// mrkdwn has bold, italic, strikethrough, code, quotes and links. Headings
+// become bold lines, lists use bullet characters and tables are written as
+// preformatted text. Slack has no way to escape its formatting characters,
+// only &, < and > are escaped.
+package slack
+
+import (
+	"io"
+	"strings"
+
+	"github.com/eternalsad/markdownify/ast"
+	"github.com/eternalsad/markdownify/internal/plain"
+	"github.com/eternalsad/markdownify/parser/latex"
+)
+
+// Default styling used for the blank fields of RendererOptions.
+var (
+	// DefaultListBullets are the bullet glyphs for unordered lists, by depth.
+	DefaultListBullets = []string{"•", "◦", "▪"}
+	// DefaultListIndent is written for each level of list nesting.
+	DefaultListIndent = "    "
+	// DefaultHorizontalRule is the separator line written for thematic breaks.
+	DefaultHorizontalRule = "──────────"
+	// DefaultTaskUnchecked and DefaultTaskChecked mark task list items.
+	DefaultTaskUnchecked = "☐"
+	DefaultTaskChecked   = "☑"
+)
+
+// RenderNodeFunc allows reusing most of Renderer logic and replacing
+// rendering of some nodes. If it returns false, Renderer.RenderNode
+// will execute its logic. If it returns true, Renderer.RenderNode will
+// skip rendering this node and will return WalkStatus
+type RenderNodeFunc func(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool)
+
+// RendererOptions is a collection of supplementary parameters tweaking
+// the behavior of various parts of the mrkdwn renderer.
+// Blank fields are replaced by the matching Default* value.
+type RendererOptions struct {
+	// ListBullets are the bullet glyphs for unordered lists, by depth.
+	ListBullets []string
+	// ListIndent is written for each level of list nesting.
+	ListIndent string
+
+	// HorizontalRule is the separator line written for thematic breaks.
+	HorizontalRule string
+
+	// TaskUnchecked and TaskChecked are written after the bullet of task
+	// list items.
+	TaskUnchecked string
+	TaskChecked   string
+
+	// RawMath renders formulas as written instead of replacing LaTeX
+	// commands with Unicode symbols.
+	RawMath bool
+
+	// if set, called at the start of RenderNode(). Allows replacing
+	// rendering of some nodes
+	RenderNodeHook RenderNodeFunc
+}
+
+// Renderer renders to Slack mrkdwn.
+//
+// Do not create this directly, instead use the NewRenderer function.
+type Renderer struct {
+	Opts RendererOptions
+
+	base  *plain.Renderer
+	latex *latex.LaTeXToMarkdownV2
+}
+
+// NewRenderer returns a Slack mrkdwn renderer.
+func NewRenderer(opts RendererOptions) *Renderer {
+	if len(opts.ListBullets) == 0 {
+		opts.ListBullets = DefaultListBullets
+	}
+	if opts.ListIndent == "" {
+		opts.ListIndent = DefaultListIndent
+	}
+	if opts.HorizontalRule == "" {
+		opts.HorizontalRule = DefaultHorizontalRule
+	}
+	if opts.TaskUnchecked == "" {
+		opts.TaskUnchecked = DefaultTaskUnchecked
+	}
+	if opts.TaskChecked == "" {
+		opts.TaskChecked = DefaultTaskChecked
+	}
+	r := &Renderer{
+		Opts:  opts,
+		latex: latex.NewLaTeXToMarkdownV2(),
+	}
+	markers := plain.Markers{
+		Bold:          "*",
+		Bullets:       opts.ListBullets,
+		ListIndent:    opts.ListIndent,
+		TaskUnchecked: opts.TaskUnchecked,
+		TaskChecked:   opts.TaskChecked,
+	}
+	r.base = plain.New(markers, func(text string, _ bool) string { return escape(text) }, r.RenderNode)
+	return r
+}
+
+func (r *Renderer) outs(w io.Writer, s string) {
+	r.base.Outs(w, s)
+}
+
+// escape escapes the characters that Slack uses for its control sequences.
+func escape(text string) string {
+	text = strings.ReplaceAll(text, "&", "&amp;")
+	text = strings.ReplaceAll(text, "<", "&lt;")
+	return strings.ReplaceAll(text, ">", "&gt;")
+}
+
+func (r *Renderer) heading(w io.Writer, node *ast.Heading, entering bool) {
+	r.base.Bold(w, entering)
+	if !entering {
+		r.outs(w, "\n\n")
+	}
+}
+
+func (r *Renderer) link(w io.Writer, dest []byte, node ast.Node) {
+	text := strings.TrimSpace(r.base.RenderChildren(node))
+	url := strings.ReplaceAll(escape(string(dest)), "|", "%7C")
+	if text == "" || text == url {
+		r.outs(w, "<"+url+">")
+		return
+	}
+	r.outs(w, "<"+url+"|"+text+">")
+}
+
+func (r *Renderer) code(w io.Writer, literal string) {
+	r.outs(w, "`"+escape(literal)+"`")
+}
+
+func (r *Renderer) codeBlock(w io.Writer, literal string) {
+	literal = strings.TrimSuffix(literal, "\n")
+	r.outs(w, r.base.ListIndent())
+	r.outs(w, "```\n"+escape(literal)+"\n```\n")
+	if r.base.ListDepth() == 0 {
+		r.outs(w, "\n")
+	}
+}
+
+// table writes the table as preformatted text with aligned columns.
+func (r *Renderer) table(w io.Writer, node *ast.Table) {
+	if text := plain.Table(node); text != "" {
+		r.codeBlock(w, text)
+	}
+}
+
+// mathText returns the text of a formula as it is shown, see
+// RendererOptions.RawMath.
+func (r *Renderer) mathText(literal []byte) string {
+	if r.Opts.RawMath {
+		return string(literal)
+	}
+	return r.latex.ToUnicode(string(literal))
+}
+
+// RenderNode renders a markdown node to mrkdwn.
+func (r *Renderer) RenderNode(w io.Writer, node ast.Node, entering bool) ast.WalkStatus {
+	if r.Opts.RenderNodeHook != nil {
+		status, didHandle := r.Opts.RenderNodeHook(w, node, entering)
+		if didHandle {
+			return status
+		}
+	}
+	switch node := node.(type) {
+	case *ast.Text:
+		r.base.Text(w, string(node.Literal))
+	case *ast.Softbreak:
+		r.outs(w, " ")
+	case *ast.Hardbreak:
+		r.outs(w, "\n")
+	case *ast.NonBlockingSpace:
+		r.outs(w, " ")
+	case *ast.Emph:
+		r.outs(w, "_")
+	case *ast.Strong:
+		r.base.Bold(w, entering)
+	case *ast.Del:
+		r.outs(w, "~")
+	case *ast.BlockQuote:
+		if entering {
+			r.base.BlockQuote(w, node)
+		}
+		return ast.SkipChildren
+	case *ast.Link:
+		if entering {
+			r.link(w, node.Destination, node)
+		}
+		return ast.SkipChildren
+	case *ast.Image:
+		if entering {
+			r.link(w, node.Destination, node)
+		}
+		return ast.SkipChildren
+	case *ast.Code:
+		r.code(w, string(node.Literal))
+	case *ast.CodeBlock:
+		r.codeBlock(w, string(node.Literal))
+	case *ast.Paragraph:
+		r.base.Para(w, node, entering)
+	case *ast.HTMLSpan:
+		// Slack can't show HTML, the text between the tags is kept
+	case *ast.HTMLBlock:
+		r.base.HTMLBlock(w, node)
+	case *ast.Heading:
+		r.heading(w, node, entering)
+	case *ast.HorizontalRule:
+		r.outs(w, r.Opts.HorizontalRule+"\n\n")
+	case *ast.List:
+		r.base.List(w, node, entering)
+	case *ast.ListItem:
+		r.base.ListItem(w, node, entering)
+	case *ast.Table:
+		if entering {
+			r.table(w, node)
+		}
+		return ast.SkipChildren
+	case *ast.Math:
+		r.code(w, r.mathText(node.Literal))
+	case *ast.MathBlock:
+		if entering {
+			r.codeBlock(w, strings.TrimSpace(r.mathText(node.Literal)))
+		}
+	default:
+		// the other nodes have no mrkdwn equivalent, their children are
+		// rendered as they are
+	}
+	return ast.GoToNext
+}
+
+// RenderHeader renders header
+func (r *Renderer) RenderHeader(w io.Writer, ast ast.Node) {
+	// do nothing
+}
+
+// RenderFooter renders footer
+func (r *Renderer) RenderFooter(w io.Writer, ast ast.Node) {
+	// do nothing
+}
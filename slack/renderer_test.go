@@ -0,0 +1,52 @@
+package slack
+
+import (
+	"testing"
+
+	"github.com/eternalsad/markdownify/internal/rendertest"
+)
+
+func testRendering(t *testing.T, source string, expected string) {
+	t.Helper()
+	rendertest.Expect(t, source, expected, rendertest.Render(NewRenderer(RendererOptions{}), source))
+}
+
+func TestRenderInline(t *testing.T) {
+	testRendering(t, "**bold** *italic* ~~strike~~ `a < b`\n", "*bold* _italic_ ~strike~ `a &lt; b`\n\n")
+	testRendering(t, "Tom & Jerry <3\n", "Tom &amp; Jerry &lt;3\n\n")
+}
+
+func TestRenderLinks(t *testing.T) {
+	testRendering(t, "[docs](https://go.dev/doc?a=1&b=2)\n", "<https://go.dev/doc?a=1&amp;b=2|docs>\n\n")
+	testRendering(t, "see https://go.dev\n", "see <https://go.dev>\n\n")
+	testRendering(t, "![logo](https://go.dev/logo.png)\n", "<https://go.dev/logo.png|logo>\n\n")
+}
+
+func TestRenderHeading(t *testing.T) {
+	testRendering(t, "# Title with **bold**\n\ntext\n", "*Title with bold*\n\ntext\n\n")
+}
+
+func TestRenderCodeBlock(t *testing.T) {
+	testRendering(t, "```go\nif a < b {}\n```\n", "```\nif a &lt; b {}\n```\n\n")
+}
+
+func TestRenderBlockQuote(t *testing.T) {
+	testRendering(t, "> first\n>\n> **second**\n", "> first\n>\n> *second*\n\n")
+}
+
+func TestRenderLists(t *testing.T) {
+	testRendering(t, "- a\n    - b\n- [x] c\n", "• a\n    ◦ b\n• ☑ c\n\n")
+	testRendering(t, "3. a\n4. b\n", "3. a\n4. b\n\n")
+}
+
+func TestRenderTable(t *testing.T) {
+	source := "| Name | Year |\n|---|---|\n| Go | 2009 |\n| Rust | 2010 |\n"
+	expected := "```\nName | Year\n-----+-----\nGo   | 2009\nRust | 2010\n```\n\n"
+	testRendering(t, source, expected)
+}
+
+func TestRenderListsEmptyBullets(t *testing.T) {
+	source := "- a\n    - b\n"
+	got := rendertest.Render(NewRenderer(RendererOptions{ListBullets: []string{}}), source)
+	rendertest.Expect(t, source, "• a\n    ◦ b\n\n", got)
+}
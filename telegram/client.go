@@ -0,0 +1,341 @@
+// Package telegram is a small client of the Telegram Bot API.
+//
+// Bot implements the Client interface on top of net/http. The base URL and
+// the http.Client are configurable, so that code using a Client can be
+// tested against a local server.
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultBaseURL is the address of the Bot API.
+const DefaultBaseURL = "https://api.telegram.org"
+
+// Client is the part of the Bot API used by the bot.
+type Client interface {
+	// GetUpdates waits up to timeout for updates with IDs from offset on.
+	GetUpdates(ctx context.Context, offset int, timeout time.Duration) ([]Update, error)
+	// SendMarkdownMessage sends text formatted as MarkdownV2 to the chat.
+	SendMarkdownMessage(ctx context.Context, chatID int64, text string) error
+	// SendMessage sends a message and returns it as it was sent.
+	SendMessage(ctx context.Context, params SendMessageParams) (*Message, error)
+	// GetFile prepares a file sent to the bot for downloading.
+	GetFile(ctx context.Context, fileID string) (*File, error)
+	// DownloadFile downloads the file at filePath, returned by GetFile.
+	// Files larger than maxSize bytes fail with ErrFileTooLarge.
+	DownloadFile(ctx context.Context, filePath string, maxSize int64) ([]byte, error)
+	// AnswerInlineQuery sends the results of an inline query.
+	AnswerInlineQuery(ctx context.Context, params AnswerInlineQueryParams) error
+}
+
+// ErrFileTooLarge is returned by DownloadFile for files over the limit.
+var ErrFileTooLarge = errors.New("telegram: file is too large")
+
+// Error is an error returned by the Bot API.
+type Error struct {
+	// Method is the called method, e.g. "sendMessage".
+	Method string
+	// StatusCode is the HTTP status of the response.
+	StatusCode int
+	// Code and Description are the error_code and description of the
+	// response.
+	Code        int
+	Description string
+	// RetryAfter is the time to wait before repeating the request, it is
+	// set for "Too Many Requests" errors.
+	RetryAfter time.Duration
+	// MigrateToChatID is the new ID of a group that became a supergroup.
+	MigrateToChatID int64
+}
+
+func (e *Error) Error() string {
+	if e.Description == "" {
+		return fmt.Sprintf("telegram: %s: status %d", e.Method, e.StatusCode)
+	}
+	return fmt.Sprintf("telegram: %s: %s", e.Method, e.Description)
+}
+
+// IsTooManyRequests tells if err is a Bot API error asking to retry later.
+func IsTooManyRequests(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && (apiErr.Code == http.StatusTooManyRequests || apiErr.RetryAfter > 0)
+}
+
+// IsBadRequest tells if err is a Bot API error about the request itself,
+// e.g. text that can't be parsed. Repeating such a request doesn't help.
+func IsBadRequest(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusBadRequest
+}
+
+// IsParseError tells if err is a Bot API error about the markup of the
+// text, "can't parse entities".
+func IsParseError(err error) bool {
+	var apiErr *Error
+	return IsBadRequest(err) && errors.As(err, &apiErr) && strings.Contains(apiErr.Description, "can't parse entities")
+}
+
+// offsetRe matches the position reported in parse errors.
+var offsetRe = regexp.MustCompile(`byte offset (\d+)`)
+
+// ParseErrorOffset returns the byte offset in the text reported by a
+// "can't parse entities" error. Not all of them report one.
+func ParseErrorOffset(err error) (int, bool) {
+	var apiErr *Error
+	if !IsParseError(err) || !errors.As(err, &apiErr) {
+		return 0, false
+	}
+	m := offsetRe.FindStringSubmatch(apiErr.Description)
+	if m == nil {
+		return 0, false
+	}
+	offset, convErr := strconv.Atoi(m[1])
+	return offset, convErr == nil
+}
+
+// BotOptions is a collection of supplementary parameters of Bot.
+// Blank fields are replaced by defaults.
+type BotOptions struct {
+	// BaseURL is the address of the Bot API, DefaultBaseURL if blank.
+	BaseURL string
+	// HTTPClient sends the requests, http.DefaultClient if nil. Its
+	// timeout must be longer than the timeout of GetUpdates.
+	HTTPClient *http.Client
+}
+
+// Bot is a Client calling the Bot API with a bot token.
+//
+// Do not create this directly, instead use the NewBot function.
+type Bot struct {
+	Opts BotOptions
+
+	token string
+}
+
+var _ Client = (*Bot)(nil)
+
+// NewBot returns a Bot using token.
+func NewBot(token string, opts BotOptions) *Bot {
+	if opts.BaseURL == "" {
+		opts.BaseURL = DefaultBaseURL
+	}
+	opts.BaseURL = strings.TrimSuffix(opts.BaseURL, "/")
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+	return &Bot{Opts: opts, token: token}
+}
+
+// Call calls method with params encoded as JSON and decodes the result of
+// the response into result, if it isn't nil. Failed calls return *Error.
+func (b *Bot) Call(ctx context.Context, method string, params any, result any) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("telegram: %s: %w", method, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.methodURL(method), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("telegram: %s: %w", method, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return b.do(req, method, result)
+}
+
+// methodURL returns the URL of method.
+func (b *Bot) methodURL(method string) string {
+	return b.Opts.BaseURL + "/bot" + b.token + "/" + method
+}
+
+// do sends req and decodes the response.
+func (b *Bot) do(req *http.Request, method string, result any) error {
+	resp, err := b.Opts.HTTPClient.Do(req)
+	if err != nil {
+		// the URL contains the token, it must not end up in logs
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("telegram: %s: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("telegram: %s: %w", method, err)
+	}
+	var apiResp response
+	if err := json.Unmarshal(data, &apiResp); err != nil {
+		return &Error{Method: method, StatusCode: resp.StatusCode, Code: resp.StatusCode}
+	}
+	if !apiResp.Ok {
+		apiErr := &Error{
+			Method:      method,
+			StatusCode:  resp.StatusCode,
+			Code:        apiResp.ErrorCode,
+			Description: apiResp.Description,
+		}
+		if p := apiResp.Parameters; p != nil {
+			apiErr.RetryAfter = time.Duration(p.RetryAfter) * time.Second
+			apiErr.MigrateToChatID = p.MigrateToChatID
+		}
+		return apiErr
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(apiResp.Result, result); err != nil {
+		return fmt.Errorf("telegram: %s: decoding result: %w", method, err)
+	}
+	return nil
+}
+
+// GetUpdates waits up to timeout for updates with IDs from offset on.
+func (b *Bot) GetUpdates(ctx context.Context, offset int, timeout time.Duration) ([]Update, error) {
+	var updates []Update
+	params := GetUpdatesParams{Offset: offset, Timeout: int(timeout / time.Second)}
+	if err := b.Call(ctx, "getUpdates", params, &updates); err != nil {
+		return nil, err
+	}
+	return updates, nil
+}
+
+// SendMessage sends a message and returns it as it was sent.
+func (b *Bot) SendMessage(ctx context.Context, params SendMessageParams) (*Message, error) {
+	var msg Message
+	if err := b.Call(ctx, "sendMessage", params, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// EditMessageText replaces the text of a message sent by the bot.
+func (b *Bot) EditMessageText(ctx context.Context, params EditMessageTextParams) (*Message, error) {
+	var msg Message
+	if err := b.Call(ctx, "editMessageText", params, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// SendDocument uploads a file and sends it to the chat.
+func (b *Bot) SendDocument(ctx context.Context, params SendDocumentParams) (*Message, error) {
+	const method = "sendDocument"
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("chat_id", strconv.FormatInt(params.ChatID, 10))
+	if params.Caption != "" {
+		mw.WriteField("caption", params.Caption)
+	}
+	if params.ParseMode != "" {
+		mw.WriteField("parse_mode", params.ParseMode)
+	}
+	fw, err := mw.CreateFormFile("document", params.FileName)
+	if err != nil {
+		return nil, fmt.Errorf("telegram: %s: %w", method, err)
+	}
+	fw.Write(params.Data)
+	if err := mw.Close(); err != nil {
+		return nil, fmt.Errorf("telegram: %s: %w", method, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.methodURL(method), &body)
+	if err != nil {
+		return nil, fmt.Errorf("telegram: %s: %w", method, err)
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	var msg Message
+	if err := b.do(req, method, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// GetFile prepares a file sent to the bot for downloading. The Bot API
+// serves files up to 20 MB.
+func (b *Bot) GetFile(ctx context.Context, fileID string) (*File, error) {
+	var file File
+	if err := b.Call(ctx, "getFile", GetFileParams{FileID: fileID}, &file); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// DownloadFile downloads the file at filePath, returned by GetFile. Files
+// larger than maxSize bytes fail with ErrFileTooLarge, a maxSize of 0 means
+// no limit.
+func (b *Bot) DownloadFile(ctx context.Context, filePath string, maxSize int64) ([]byte, error) {
+	const method = "downloadFile"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.Opts.BaseURL+"/file/bot"+b.token+"/"+filePath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("telegram: %s: %w", method, err)
+	}
+	resp, err := b.Opts.HTTPClient.Do(req)
+	if err != nil {
+		// the URL contains the token, it must not end up in logs
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("telegram: %s: %w", method, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &Error{Method: method, StatusCode: resp.StatusCode, Code: resp.StatusCode}
+	}
+	if maxSize > 0 && resp.ContentLength > maxSize {
+		return nil, fmt.Errorf("%w: %d bytes, the limit is %d", ErrFileTooLarge, resp.ContentLength, maxSize)
+	}
+
+	body := io.Reader(resp.Body)
+	if maxSize > 0 {
+		body = io.LimitReader(resp.Body, maxSize+1)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("telegram: %s: %w", method, err)
+	}
+	if maxSize > 0 && int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("%w: the limit is %d bytes", ErrFileTooLarge, maxSize)
+	}
+	return data, nil
+}
+
+// AnswerInlineQuery sends the results of an inline query. At most 50
+// results are allowed, and the query must be answered within 10 seconds.
+func (b *Bot) AnswerInlineQuery(ctx context.Context, params AnswerInlineQueryParams) error {
+	return b.Call(ctx, "answerInlineQuery", params, nil)
+}
+
+// SetWebhook makes the Bot API post updates to a webhook instead of
+// returning them from getUpdates.
+func (b *Bot) SetWebhook(ctx context.Context, params SetWebhookParams) error {
+	return b.Call(ctx, "setWebhook", params, nil)
+}
+
+// DeleteWebhook removes the webhook, so that getUpdates works again.
+func (b *Bot) DeleteWebhook(ctx context.Context, params DeleteWebhookParams) error {
+	return b.Call(ctx, "deleteWebhook", params, nil)
+}
+
+// SendMarkdownMessage sends text formatted as MarkdownV2 to the chat.
+func (b *Bot) SendMarkdownMessage(ctx context.Context, chatID int64, text string) error {
+	_, err := b.SendMessage(ctx, SendMessageParams{
+		ChatID:    chatID,
+		Text:      text,
+		ParseMode: ModeMarkdownV2,
+	})
+	return err
+}
@@ -0,0 +1,117 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestBot returns a Bot calling handler.
+func newTestBot(t *testing.T, handler http.HandlerFunc) *Bot {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return NewBot("123:abc", BotOptions{BaseURL: srv.URL, HTTPClient: srv.Client()})
+}
+
+func TestGetUpdates(t *testing.T) {
+	bot := newTestBot(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bot123:abc/getUpdates" {
+			t.Errorf("path = %q", r.URL.Path)
+		}
+		var params GetUpdatesParams
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			t.Error(err)
+		}
+		if params.Offset != 7 || params.Timeout != 30 {
+			t.Errorf("params = %+v", params)
+		}
+		io.WriteString(w, `{"ok":true,"result":[{"update_id":7,"message":{"message_id":1,"chat":{"id":42},"text":"hi"}}]}`)
+	})
+	updates, err := bot.GetUpdates(context.Background(), 7, 30*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updates) != 1 || updates[0].Message == nil || updates[0].Message.Text != "hi" || updates[0].Message.Chat.ID != 42 {
+		t.Errorf("updates = %+v", updates)
+	}
+}
+
+func TestSendMarkdownMessage(t *testing.T) {
+	bot := newTestBot(t, func(w http.ResponseWriter, r *http.Request) {
+		var params SendMessageParams
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			t.Error(err)
+		}
+		if params.ChatID != 42 || params.Text != "*hi*" || params.ParseMode != ModeMarkdownV2 {
+			t.Errorf("params = %+v", params)
+		}
+		io.WriteString(w, `{"ok":true,"result":{"message_id":5,"chat":{"id":42},"text":"hi"}}`)
+	})
+	if err := bot.SendMarkdownMessage(context.Background(), 42, "*hi*"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	bot := newTestBot(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/getUpdates") {
+			w.WriteHeader(http.StatusTooManyRequests)
+			io.WriteString(w, `{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 3","parameters":{"retry_after":3}}`)
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, `{"ok":false,"error_code":400,"description":"Bad Request: can't parse entities"}`)
+	})
+	ctx := context.Background()
+
+	_, err := bot.GetUpdates(ctx, 0, 0)
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.RetryAfter != 3*time.Second || apiErr.Method != "getUpdates" {
+		t.Errorf("err = %#v", err)
+	}
+	if !IsTooManyRequests(err) || IsBadRequest(err) {
+		t.Errorf("%v: wrong kind", err)
+	}
+
+	err = bot.SendMarkdownMessage(ctx, 1, "x")
+	if !IsBadRequest(err) || IsTooManyRequests(err) {
+		t.Errorf("%v: wrong kind", err)
+	}
+	if expected := "telegram: sendMessage: Bad Request: can't parse entities"; err.Error() != expected {
+		t.Errorf("err = %q, expected %q", err, expected)
+	}
+}
+
+func TestParseErrorOffset(t *testing.T) {
+	err := &Error{Method: "sendMessage", Code: 400, Description: "Bad Request: can't parse entities: Can't find end of Bold entity at byte offset 12"}
+	if offset, ok := ParseErrorOffset(err); !IsParseError(err) || !ok || offset != 12 {
+		t.Errorf("offset = %d, %v", offset, ok)
+	}
+	err.Description = "Bad Request: can't parse entities: Character '.' is reserved and must be escaped with the preceding '\\'"
+	if _, ok := ParseErrorOffset(err); !IsParseError(err) || ok {
+		t.Errorf("%v: unexpected offset", err)
+	}
+	err.Description = "Bad Request: message is too long"
+	if IsParseError(err) {
+		t.Errorf("%v: not a parse error", err)
+	}
+}
+
+func TestTokenNotInErrors(t *testing.T) {
+	bot := NewBot("123:secret", BotOptions{BaseURL: "http://127.0.0.1:0"})
+	err := bot.SendMarkdownMessage(context.Background(), 1, "x")
+	if err == nil || strings.Contains(err.Error(), "secret") {
+		t.Errorf("err = %v", err)
+	}
+	_, err = bot.DownloadFile(context.Background(), "documents/file.md", 0)
+	if err == nil || strings.Contains(err.Error(), "secret") {
+		t.Errorf("err = %v", err)
+	}
+}
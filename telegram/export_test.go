@@ -0,0 +1,8 @@
+package telegram
+
+// QueuedChats returns the number of chats s keeps a queue for.
+func QueuedChats(s *Sender) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.chats)
+}
@@ -0,0 +1,302 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Default limits used for the blank fields of SenderOptions. They follow
+// the limits of the Bot API: about one message per second in a chat, 20
+// messages per minute in a group and 30 messages per second overall.
+var (
+	DefaultChatInterval   = time.Second
+	DefaultGroupInterval  = 3 * time.Second
+	DefaultGlobalInterval = time.Second / 30
+	DefaultMaxRetries     = 5
+	DefaultMinBackoff     = 500 * time.Millisecond
+	DefaultMaxBackoff     = 30 * time.Second
+)
+
+// SenderOptions is a collection of supplementary parameters of Sender.
+// Blank fields are replaced by the matching Default* value.
+type SenderOptions struct {
+	// ChatInterval is the minimum time between two messages in a private
+	// chat, GroupInterval in a group.
+	ChatInterval  time.Duration
+	GroupInterval time.Duration
+	// GlobalInterval is the minimum time between two messages in all chats.
+	GlobalInterval time.Duration
+
+	// MaxRetries is the number of times a failed request is repeated.
+	MaxRetries int
+	// MinBackoff and MaxBackoff bound the time waited before repeating a
+	// request after a server or network error. The time doubles with each
+	// attempt and is jittered.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// Sender is a Client that paces the messages of another Client to stay
+// within the rate limits of the Bot API. It waits for retry_after on
+// "Too Many Requests" errors and retries server and network errors with
+// backoff. Messages to a chat are sent in the order of the calls.
+//
+// Do not create this directly, instead use the NewSender function.
+type Sender struct {
+	Opts SenderOptions
+
+	client Client
+
+	mu    sync.Mutex
+	chats map[int64]*chatQueue
+	// time of the next message in all chats
+	nextGlobal time.Time
+}
+
+// chatQueue orders the messages to a chat.
+type chatQueue struct {
+	// closed when the last queued message is sent
+	tail chan struct{}
+	// time of the next message in the chat
+	next time.Time
+	// number of queued messages, the queue is dropped at 0 once next is
+	// due
+	waiting int
+}
+
+var _ Client = (*Sender)(nil)
+
+// NewSender returns a Sender sending through client.
+func NewSender(client Client, opts SenderOptions) *Sender {
+	if opts.ChatInterval == 0 {
+		opts.ChatInterval = DefaultChatInterval
+	}
+	if opts.GroupInterval == 0 {
+		opts.GroupInterval = DefaultGroupInterval
+	}
+	if opts.GlobalInterval == 0 {
+		opts.GlobalInterval = DefaultGlobalInterval
+	}
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = DefaultMaxRetries
+	}
+	if opts.MinBackoff == 0 {
+		opts.MinBackoff = DefaultMinBackoff
+	}
+	if opts.MaxBackoff == 0 {
+		opts.MaxBackoff = DefaultMaxBackoff
+	}
+	return &Sender{
+		Opts:   opts,
+		client: client,
+		chats:  map[int64]*chatQueue{},
+	}
+}
+
+// GetUpdates calls GetUpdates of the client, retrying server and network
+// errors.
+func (s *Sender) GetUpdates(ctx context.Context, offset int, timeout time.Duration) ([]Update, error) {
+	var updates []Update
+	err := s.retry(ctx, nil, func(ctx context.Context) error {
+		var err error
+		updates, err = s.client.GetUpdates(ctx, offset, timeout)
+		return err
+	})
+	return updates, err
+}
+
+// GetFile calls GetFile of the client, retrying server and network errors.
+func (s *Sender) GetFile(ctx context.Context, fileID string) (*File, error) {
+	var file *File
+	err := s.retry(ctx, nil, func(ctx context.Context) error {
+		var err error
+		file, err = s.client.GetFile(ctx, fileID)
+		return err
+	})
+	return file, err
+}
+
+// DownloadFile calls DownloadFile of the client, retrying server and
+// network errors.
+func (s *Sender) DownloadFile(ctx context.Context, filePath string, maxSize int64) ([]byte, error) {
+	var data []byte
+	err := s.retry(ctx, nil, func(ctx context.Context) error {
+		var err error
+		data, err = s.client.DownloadFile(ctx, filePath, maxSize)
+		return err
+	})
+	return data, err
+}
+
+// AnswerInlineQuery calls AnswerInlineQuery of the client, retrying server
+// and network errors. Answers aren't paced, they don't go to a chat.
+func (s *Sender) AnswerInlineQuery(ctx context.Context, params AnswerInlineQueryParams) error {
+	return s.retry(ctx, nil, func(ctx context.Context) error {
+		return s.client.AnswerInlineQuery(ctx, params)
+	})
+}
+
+// SendMarkdownMessage sends text formatted as MarkdownV2 to the chat once
+// the rate limits allow it.
+func (s *Sender) SendMarkdownMessage(ctx context.Context, chatID int64, text string) error {
+	return s.Do(ctx, chatID, func(ctx context.Context) error {
+		return s.client.SendMarkdownMessage(ctx, chatID, text)
+	})
+}
+
+// SendMessage sends a message once the rate limits allow it.
+func (s *Sender) SendMessage(ctx context.Context, params SendMessageParams) (*Message, error) {
+	var msg *Message
+	err := s.Do(ctx, params.ChatID, func(ctx context.Context) error {
+		var err error
+		msg, err = s.client.SendMessage(ctx, params)
+		return err
+	})
+	return msg, err
+}
+
+// Do calls send once the rate limits of the chat allow it, after the
+// previous calls for the chat are done. Failed calls are retried like the
+// messages of SendMarkdownMessage.
+func (s *Sender) Do(ctx context.Context, chatID int64, send func(ctx context.Context) error) error {
+	s.mu.Lock()
+	q := s.chats[chatID]
+	if q == nil {
+		q = &chatQueue{}
+		s.chats[chatID] = q
+	}
+	prev := q.tail
+	done := make(chan struct{})
+	q.tail = done
+	q.waiting++
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		q.waiting--
+		if q.waiting > 0 {
+			return
+		}
+		// the queue keeps the time of the next message until it is due
+		time.AfterFunc(time.Until(q.next), func() {
+			s.mu.Lock()
+			if q.waiting == 0 && s.chats[chatID] == q {
+				delete(s.chats, chatID)
+			}
+			s.mu.Unlock()
+		})
+	}()
+
+	if prev != nil {
+		select {
+		case <-prev:
+		case <-ctx.Done():
+			// the next message must still wait for the previous one
+			go func() {
+				<-prev
+				close(done)
+			}()
+			return ctx.Err()
+		}
+	}
+	defer close(done)
+
+	return s.retry(ctx, q, func(ctx context.Context) error {
+		if err := s.wait(ctx, chatID, q); err != nil {
+			return err
+		}
+		return send(ctx)
+	})
+}
+
+// wait waits until a message can be sent to the chat and reserves the
+// time of the message.
+func (s *Sender) wait(ctx context.Context, chatID int64, q *chatQueue) error {
+	interval := s.Opts.ChatInterval
+	if chatID < 0 {
+		interval = s.Opts.GroupInterval
+	}
+	if err := sleep(ctx, time.Until(q.next)); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	now := time.Now()
+	at := now
+	if s.nextGlobal.After(at) {
+		at = s.nextGlobal
+	}
+	s.nextGlobal = at.Add(s.Opts.GlobalInterval)
+	q.next = at.Add(interval)
+	s.mu.Unlock()
+
+	return sleep(ctx, at.Sub(now))
+}
+
+// retry calls call until it succeeds, fails with an error that can't be
+// fixed by repeating it or fails Opts.MaxRetries+1 times. Retry-after
+// times of "Too Many Requests" errors delay the next messages of q, those
+// without one are retried with backoff.
+func (s *Sender) retry(ctx context.Context, q *chatQueue, call func(ctx context.Context) error) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = call(ctx)
+		if err == nil || attempt >= s.Opts.MaxRetries || ctx.Err() != nil {
+			return err
+		}
+		var delay time.Duration
+		var apiErr *Error
+		switch {
+		case errors.As(err, &apiErr) && apiErr.RetryAfter > 0:
+			delay = apiErr.RetryAfter
+			if q != nil {
+				s.mu.Lock()
+				q.next = time.Now().Add(delay)
+				s.mu.Unlock()
+				delay = 0
+			}
+		case errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusTooManyRequests || apiErr.Code == http.StatusTooManyRequests):
+			// Too Many Requests without retry_after
+			delay = s.backoff(attempt)
+		case errors.As(err, &apiErr) && apiErr.StatusCode < 500:
+			return err
+		case errors.Is(err, ErrFileTooLarge):
+			return err
+		default:
+			delay = s.backoff(attempt)
+		}
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// backoff returns the jittered time to wait before repeating a request for
+// the attempt+1-th time.
+func (s *Sender) backoff(attempt int) time.Duration {
+	d := s.Opts.MinBackoff << attempt
+	if d > s.Opts.MaxBackoff || d <= 0 {
+		d = s.Opts.MaxBackoff
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,175 @@
+package telegram_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eternalsad/markdownify/telegram"
+	"github.com/eternalsad/markdownify/telegram/telegramtest"
+)
+
+var fastOptions = telegram.SenderOptions{
+	ChatInterval:   time.Microsecond,
+	GlobalInterval: time.Microsecond,
+	MinBackoff:     time.Millisecond,
+	MaxBackoff:     10 * time.Millisecond,
+}
+
+func TestSenderRetryAfter(t *testing.T) {
+	srv := telegramtest.NewServer()
+	defer srv.Close()
+	srv.Throttle(1, 1)
+	sender := telegram.NewSender(srv.Bot(), fastOptions)
+
+	start := time.Now()
+	if err := sender.SendMarkdownMessage(context.Background(), 42, "hi"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("sent after %v, expected to wait for retry_after", elapsed)
+	}
+	if msgs := srv.Messages(); len(msgs) != 1 {
+		t.Errorf("messages = %+v", msgs)
+	}
+}
+
+func TestSenderKeepsOrder(t *testing.T) {
+	srv := telegramtest.NewServer()
+	defer srv.Close()
+	srv.Throttle(1, 1)
+	sender := telegram.NewSender(srv.Bot(), fastOptions)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for _, text := range []string{"first", "second", "third"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := sender.SendMarkdownMessage(ctx, 42, text); err != nil {
+				t.Error(err)
+			}
+		}()
+		// the first message waits for retry_after, the others are queued
+		// behind it
+		time.Sleep(50 * time.Millisecond)
+	}
+	// other chats don't wait
+	if err := sender.SendMarkdownMessage(ctx, 7, "other"); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+
+	var texts []string
+	for _, msg := range srv.Messages() {
+		texts = append(texts, msg.Text)
+	}
+	expected := []string{"other", "first", "second", "third"}
+	if len(texts) != len(expected) {
+		t.Fatalf("texts = %q, expected %q", texts, expected)
+	}
+	for i := range texts {
+		if texts[i] != expected[i] {
+			t.Fatalf("texts = %q, expected %q", texts, expected)
+		}
+	}
+}
+
+func TestSenderChatInterval(t *testing.T) {
+	srv := telegramtest.NewServer()
+	defer srv.Close()
+	opts := fastOptions
+	opts.ChatInterval = 50 * time.Millisecond
+	sender := telegram.NewSender(srv.Bot(), opts)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := sender.SendMarkdownMessage(context.Background(), 42, "hi"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("3 messages sent in %v", elapsed)
+	}
+}
+
+func TestSenderRetriesServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= 2 {
+			w.WriteHeader(http.StatusBadGateway)
+			io.WriteString(w, "<html>Bad Gateway</html>")
+			return
+		}
+		io.WriteString(w, `{"ok":true,"result":{"message_id":1,"chat":{"id":42}}}`)
+	}))
+	defer srv.Close()
+	bot := telegram.NewBot("1:a", telegram.BotOptions{BaseURL: srv.URL})
+	sender := telegram.NewSender(bot, fastOptions)
+
+	if err := sender.SendMarkdownMessage(context.Background(), 42, "hi"); err != nil {
+		t.Fatal(err)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("%d calls, expected 3", n)
+	}
+}
+
+func TestSenderRetriesTooManyRequestsWithoutRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= 2 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			io.WriteString(w, `{"ok":false,"error_code":429,"description":"Too Many Requests"}`)
+			return
+		}
+		io.WriteString(w, `{"ok":true,"result":{"message_id":1,"chat":{"id":42}}}`)
+	}))
+	defer srv.Close()
+	bot := telegram.NewBot("1:a", telegram.BotOptions{BaseURL: srv.URL})
+	sender := telegram.NewSender(bot, fastOptions)
+
+	if err := sender.SendMarkdownMessage(context.Background(), 42, "hi"); err != nil {
+		t.Fatal(err)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("%d calls, expected 3", n)
+	}
+}
+
+func TestSenderDoesNotRetryBadRequests(t *testing.T) {
+	srv := telegramtest.NewServer()
+	defer srv.Close()
+	sender := telegram.NewSender(srv.Bot(), fastOptions)
+
+	err := sender.SendMarkdownMessage(context.Background(), 42, "bad.")
+	if !telegram.IsBadRequest(err) {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestSenderDropsChatQueues(t *testing.T) {
+	srv := telegramtest.NewServer()
+	defer srv.Close()
+	opts := fastOptions
+	opts.ChatInterval = 20 * time.Millisecond
+	sender := telegram.NewSender(srv.Bot(), opts)
+
+	for chatID := int64(1); chatID <= 3; chatID++ {
+		if err := sender.SendMarkdownMessage(context.Background(), chatID, "hi"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deadline := time.Now().Add(time.Second)
+	for telegram.QueuedChats(sender) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d chat queues left after the messages were sent", telegram.QueuedChats(sender))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,84 @@
+package telegramtest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// htmlTags are the tags supported by the HTML parse mode.
+var htmlTags = map[string]bool{
+	"b": true, "strong": true, "i": true, "em": true, "u": true, "ins": true,
+	"s": true, "strike": true, "del": true, "span": true, "tg-spoiler": true,
+	"a": true, "code": true, "pre": true, "blockquote": true, "tg-emoji": true,
+}
+
+// htmlEntities are the named HTML entities supported by the Bot API.
+var htmlEntities = map[string]string{"lt": "<", "gt": ">", "amp": "&", "quot": `"`}
+
+// ParseHTML checks text the way the Bot API does for the HTML parse mode and
+// returns the text without the tags.
+func ParseHTML(text string) (string, error) {
+	var plain strings.Builder
+	type openTag struct {
+		name   string
+		offset int
+	}
+	var stack []openTag
+	for i := 0; i < len(text); {
+		switch text[i] {
+		case '<':
+			end := strings.IndexByte(text[i:], '>')
+			if end < 0 {
+				return "", &ParseError{Offset: i, Message: fmt.Sprintf("Unclosed start tag at byte offset %d", i)}
+			}
+			tag := text[i+1 : i+end]
+			if name, ok := strings.CutPrefix(tag, "/"); ok {
+				name = strings.ToLower(strings.TrimSpace(name))
+				if len(stack) == 0 || stack[len(stack)-1].name != name {
+					return "", &ParseError{Offset: i, Message: fmt.Sprintf("Unmatched end tag at byte offset %d, found \"</%s>\"", i, name)}
+				}
+				stack = stack[:len(stack)-1]
+			} else {
+				name, attrs, _ := strings.Cut(tag, " ")
+				name = strings.ToLower(name)
+				if !htmlTags[name] || name == "span" && !strings.Contains(attrs, `class="tg-spoiler"`) {
+					return "", &ParseError{Offset: i, Message: fmt.Sprintf("Unsupported start tag %q at byte offset %d", name, i)}
+				}
+				for _, open := range stack {
+					if open.name == "code" || open.name == "pre" && name != "code" {
+						return "", &ParseError{Offset: i, Message: fmt.Sprintf("Entities can't be nested in %s at byte offset %d", open.name, i)}
+					}
+				}
+				stack = append(stack, openTag{name: name, offset: i})
+			}
+			i += end + 1
+		case '&':
+			end := strings.IndexByte(text[i:], ';')
+			if end < 0 {
+				plain.WriteByte('&')
+				i++
+				continue
+			}
+			name := text[i+1 : i+end]
+			if s, ok := htmlEntities[name]; ok {
+				plain.WriteString(s)
+			} else if n, err := strconv.ParseInt(strings.TrimPrefix(name, "#"), 10, 32); err == nil && strings.HasPrefix(name, "#") {
+				plain.WriteRune(rune(n))
+			} else {
+				return "", &ParseError{Offset: i, Message: fmt.Sprintf("Unsupported HTML entity at byte offset %d", i)}
+			}
+			i += end + 1
+		case '>':
+			return "", &ParseError{Offset: i, Message: fmt.Sprintf("Unexpected end of tag at byte offset %d", i)}
+		default:
+			plain.WriteByte(text[i])
+			i++
+		}
+	}
+	if len(stack) > 0 {
+		open := stack[len(stack)-1]
+		return "", &ParseError{Offset: open.offset, Message: fmt.Sprintf("Can't find end tag corresponding to start tag \"%s\"", open.name)}
+	}
+	return plain.String(), nil
+}
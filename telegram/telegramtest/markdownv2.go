@@ -0,0 +1,191 @@
+package telegramtest
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// ParseError is an error in MarkdownV2 or HTML text, its message is the one of the
+// Bot API.
+type ParseError struct {
+	// Offset is the byte offset of the error in the text.
+	Offset  int
+	Message string
+}
+
+func (e *ParseError) Error() string {
+	return "can't parse entities: " + e.Message
+}
+
+// reservedChars must be escaped with a backslash outside of entities.
+const reservedChars = "_*[]()~`>#+-=|{}.!"
+
+// entityNames are the names of the entities by their markers, as the Bot API
+// reports them.
+var entityNames = map[string]string{
+	"*":   "Bold",
+	"_":   "Italic",
+	"__":  "Underline",
+	"~":   "Strikethrough",
+	"||":  "Spoiler",
+	"`":   "Code",
+	"```": "Pre",
+	"[":   "TextUrl",
+}
+
+// openEntity is an entity whose end wasn't found yet.
+type openEntity struct {
+	marker string
+	offset int
+}
+
+// ParseMarkdownV2 checks text the way the Bot API does for the MarkdownV2
+// parse mode and returns the text without the markup.
+func ParseMarkdownV2(text string) (string, error) {
+	var plain strings.Builder
+	var stack []openEntity
+	top := func() string {
+		if len(stack) == 0 {
+			return ""
+		}
+		return stack[len(stack)-1].marker
+	}
+	// toggle opens the entity of marker or closes it if it is the innermost
+	// open entity
+	toggle := func(marker string, offset int) error {
+		if top() == marker {
+			stack = stack[:len(stack)-1]
+			return nil
+		}
+		for _, e := range stack {
+			if e.marker == marker {
+				inner := stack[len(stack)-1]
+				return &ParseError{
+					Offset:  inner.offset,
+					Message: fmt.Sprintf("Can't find end of %s entity at byte offset %d", entityNames[inner.marker], inner.offset),
+				}
+			}
+		}
+		stack = append(stack, openEntity{marker, offset})
+		return nil
+	}
+
+	lineStart := true
+	for i := 0; i < len(text); {
+		c := text[i]
+		inCode := top() == "`" || top() == "```"
+		switch {
+		case c == '\\':
+			if i+1 < len(text) && text[i+1] >= 1 && text[i+1] <= 126 {
+				plain.WriteByte(text[i+1])
+				i += 2
+			} else {
+				plain.WriteByte(c)
+				i++
+			}
+			lineStart = false
+			continue
+		case inCode && strings.HasPrefix(text[i:], "```") && top() == "```":
+			stack = stack[:len(stack)-1]
+			i += 3
+		case inCode && c == '`' && top() == "`":
+			stack = stack[:len(stack)-1]
+			i++
+		case inCode:
+			_, size := utf8.DecodeRuneInString(text[i:])
+			plain.WriteString(text[i : i+size])
+			lineStart = c == '\n'
+			i += size
+			continue
+		case lineStart && c == '>':
+			i++
+		case lineStart && strings.HasPrefix(text[i:], "**>"):
+			i += 3
+		case strings.HasPrefix(text[i:], "```"):
+			stack = append(stack, openEntity{"```", i})
+			// the rest of the line is the language
+			end := strings.IndexByte(text[i:], '\n')
+			if end < 0 {
+				end = len(text) - i
+			}
+			i += end
+		case c == '`':
+			stack = append(stack, openEntity{"`", i})
+			i++
+		case strings.HasPrefix(text[i:], "__"):
+			if err := toggle("__", i); err != nil {
+				return "", err
+			}
+			i += 2
+		case strings.HasPrefix(text[i:], "||") && top() != "||" && (i+2 == len(text) || text[i+2] == '\n'):
+			// the end of an expandable block quote
+			i += 2
+		case strings.HasPrefix(text[i:], "||"):
+			if err := toggle("||", i); err != nil {
+				return "", err
+			}
+			i += 2
+		case c == '*' || c == '_' || c == '~':
+			if err := toggle(string(c), i); err != nil {
+				return "", err
+			}
+			i++
+		case c == '[':
+			stack = append(stack, openEntity{"[", i})
+			i++
+		case c == ']' && top() == "[":
+			start := stack[len(stack)-1].offset
+			stack = stack[:len(stack)-1]
+			n, err := skipURL(text, i+1)
+			if err != nil {
+				return "", &ParseError{Offset: start, Message: err.Error()}
+			}
+			i = n
+		case strings.IndexByte(reservedChars, c) >= 0:
+			return "", &ParseError{
+				Offset:  i,
+				Message: fmt.Sprintf("Character '%c' is reserved and must be escaped with the preceding '\\'", c),
+			}
+		default:
+			_, size := utf8.DecodeRuneInString(text[i:])
+			plain.WriteString(text[i : i+size])
+			lineStart = c == '\n'
+			i += size
+			continue
+		}
+		lineStart = false
+	}
+	if len(stack) > 0 {
+		e := stack[len(stack)-1]
+		return "", &ParseError{
+			Offset:  e.offset,
+			Message: fmt.Sprintf("Can't find end of %s entity at byte offset %d", entityNames[e.marker], e.offset),
+		}
+	}
+	return plain.String(), nil
+}
+
+// skipURL skips the "(url)" part of a link starting at i and returns the
+// offset after it. ")" and "\" must be escaped in the URL.
+func skipURL(text string, i int) (int, error) {
+	if i >= len(text) || text[i] != '(' {
+		return 0, fmt.Errorf("Can't find end of a URL at byte offset %d", i)
+	}
+	for j := i + 1; j < len(text); j++ {
+		switch text[j] {
+		case '\\':
+			j++
+		case ')':
+			return j + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("Can't find end of a URL at byte offset %d", i)
+}
+
+// textLength returns the length of text as the Bot API counts it, in UTF-16
+// code units.
+func textLength(text string) int {
+	return len(utf16.Encode([]rune(text)))
+}
@@ -0,0 +1,708 @@
+// Package telegramtest provides a fake Telegram Bot API server for tests.
+//
+// The server implements getUpdates, sendMessage, editMessageText,
+// sendDocument and answerInlineQuery in memory. It enforces the limits of
+// the real API, checks MarkdownV2 and HTML text and records every message
+// and inline answer the bot sends:
+//
+//	srv := telegramtest.NewServer()
+//	defer srv.Close()
+//	srv.SendText(42, "/start")
+//	runBot(srv.Bot())
+//	for _, msg := range srv.Messages() { ... }
+package telegramtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eternalsad/markdownify/entities"
+	"github.com/eternalsad/markdownify/telegram"
+)
+
+// Token is the bot token the server accepts.
+const Token = "123456:TEST-TOKEN"
+
+// Limits of the Bot API enforced by the server.
+const (
+	// MessageLimit is the maximum length of the text of a message.
+	MessageLimit = 4096
+	// CaptionLimit is the maximum length of the caption of a document.
+	CaptionLimit = 1024
+	// MaxPollTimeout caps the timeout of getUpdates to keep tests fast.
+	MaxPollTimeout = 5 * time.Second
+)
+
+// SentMessage is a message sent or edited by the bot.
+type SentMessage struct {
+	// Method is the called method, e.g. "sendMessage".
+	Method    string
+	ChatID    int64
+	MessageID int
+	// Text is the text as it was sent, with markup.
+	Text      string
+	ParseMode string
+	// PlainText is Text without markup, as users see it.
+	PlainText string
+	// Entities are the entities sent with a text without ParseMode.
+	Entities []entities.MessageEntity
+	// Document is set for sendDocument.
+	Document *Document
+}
+
+// InlineAnswer is the answer of the bot to an inline query.
+type InlineAnswer struct {
+	QueryID    string
+	CacheTime  int
+	IsPersonal bool
+	Results    []InlineResult
+}
+
+// InlineResult is an article in an inline answer.
+type InlineResult struct {
+	ID          string
+	Title       string
+	Description string
+	// Text is the text of the message sent when the result is chosen, with
+	// markup.
+	Text      string
+	ParseMode string
+	// PlainText is Text without markup, as users see it.
+	PlainText string
+	Entities  []entities.MessageEntity
+}
+
+// Limits of inline answers.
+const (
+	// MaxInlineResults is the maximum number of results in an answer.
+	MaxInlineResults = 50
+	// MaxResultIDLength is the maximum length of the ID of a result in bytes.
+	MaxResultIDLength = 64
+)
+
+// Document is a file uploaded with sendDocument.
+type Document struct {
+	FileName string
+	Data     []byte
+	Caption  string
+}
+
+// Server is a fake Bot API server.
+type Server struct {
+	// URL is the base URL of the server, for telegram.BotOptions.BaseURL.
+	URL string
+
+	srv *httptest.Server
+
+	mu sync.Mutex
+	// queued updates, the oldest first
+	updates      []telegram.Update
+	nextUpdateID int
+	// closed and replaced when updates are added
+	updated chan struct{}
+	sent    []SentMessage
+	// texts of the messages of the bot by chat and message ID
+	texts         map[int64]map[int]string
+	nextMessageID int
+	// the next throttled calls get 429 errors
+	throttled  int
+	retryAfter int
+	// set by setWebhook, getUpdates fails while there is a webhook
+	webhook telegram.SetWebhookParams
+	// files sent by users by file_id
+	files map[string][]byte
+	// inline queries waiting for an answer by ID
+	queries       map[string]bool
+	nextQueryID   int
+	inlineAnswers []InlineAnswer
+}
+
+// NewServer starts a server. Close it when it is no longer needed.
+func NewServer() *Server {
+	s := &Server{
+		nextUpdateID:  1,
+		nextMessageID: 1,
+		updated:       make(chan struct{}),
+		texts:         map[int64]map[int]string{},
+		files:         map[string][]byte{},
+		queries:       map[string]bool{},
+		nextQueryID:   1,
+	}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.srv.URL
+	return s
+}
+
+// Close shuts the server down.
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// Bot returns a client of the server.
+func (s *Server) Bot() *telegram.Bot {
+	return telegram.NewBot(Token, telegram.BotOptions{BaseURL: s.URL, HTTPClient: s.srv.Client()})
+}
+
+// SendFile queues a document sent by a user to the bot. Its content can be
+// downloaded with getFile.
+func (s *Server) SendFile(chatID int64, fileName string, data []byte) telegram.Update {
+	s.mu.Lock()
+	id := s.nextMessageID
+	s.nextMessageID++
+	fileID := "upload-" + strconv.Itoa(id)
+	s.files[fileID] = data
+	s.mu.Unlock()
+	return s.AddUpdate(telegram.Update{Message: &telegram.Message{
+		MessageID: id,
+		Chat:      telegram.Chat{ID: chatID, Type: "private"},
+		Date:      time.Now().Unix(),
+		Document: &telegram.Document{
+			FileID:   fileID,
+			FileName: fileName,
+			FileSize: int64(len(data)),
+		},
+	}})
+}
+
+// SendInlineQuery queues an inline query typed by a user. The bot answers
+// it once with answerInlineQuery.
+func (s *Server) SendInlineQuery(userID int64, query string) telegram.Update {
+	s.mu.Lock()
+	id := strconv.Itoa(s.nextQueryID)
+	s.nextQueryID++
+	s.queries[id] = true
+	s.mu.Unlock()
+	return s.AddUpdate(telegram.Update{InlineQuery: &telegram.InlineQuery{
+		ID:       id,
+		From:     telegram.User{ID: userID, FirstName: "User"},
+		Query:    query,
+		ChatType: "sender",
+	}})
+}
+
+// InlineAnswers returns the answers of the bot to inline queries so far,
+// in order.
+func (s *Server) InlineAnswers() []InlineAnswer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]InlineAnswer(nil), s.inlineAnswers...)
+}
+
+// Webhook returns the parameters of the last setWebhook call, with a blank
+// URL if there is no webhook.
+func (s *Server) Webhook() telegram.SetWebhookParams {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.webhook
+}
+
+// AddUpdate queues an update for getUpdates and returns it with its
+// update_id set.
+func (s *Server) AddUpdate(update telegram.Update) telegram.Update {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	update.UpdateID = s.nextUpdateID
+	s.nextUpdateID++
+	s.updates = append(s.updates, update)
+	close(s.updated)
+	s.updated = make(chan struct{})
+	return update
+}
+
+// SendText queues a text message written by a user to the bot.
+func (s *Server) SendText(chatID int64, text string) telegram.Update {
+	s.mu.Lock()
+	id := s.nextMessageID
+	s.nextMessageID++
+	s.mu.Unlock()
+	return s.AddUpdate(telegram.Update{Message: &telegram.Message{
+		MessageID: id,
+		Chat:      telegram.Chat{ID: chatID, Type: "private"},
+		Date:      time.Now().Unix(),
+		Text:      text,
+	}})
+}
+
+// Messages returns the messages sent and edited by the bot so far, in
+// order.
+func (s *Server) Messages() []SentMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]SentMessage(nil), s.sent...)
+}
+
+// WaitMessages waits up to timeout until the bot sent at least n messages
+// and returns the messages.
+func (s *Server) WaitMessages(n int, timeout time.Duration) []SentMessage {
+	deadline := time.Now().Add(timeout)
+	for {
+		msgs := s.Messages()
+		if len(msgs) >= n || time.Now().After(deadline) {
+			return msgs
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Throttle makes the next n calls of the sending methods fail with
+// "429 Too Many Requests" asking to retry after retryAfter seconds.
+func (s *Server) Throttle(n int, retryAfter int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.throttled = n
+	s.retryAfter = retryAfter
+}
+
+// apiError is an error response of the Bot API.
+type apiError struct {
+	code        int
+	description string
+	retryAfter  int
+}
+
+func badRequest(format string, args ...any) *apiError {
+	return &apiError{code: http.StatusBadRequest, description: "Bad Request: " + fmt.Sprintf(format, args...)}
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if path, ok := strings.CutPrefix(r.URL.Path, "/file/bot"); ok {
+		s.serveFile(w, r, path)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/bot")
+	token, method, ok := strings.Cut(path, "/")
+	if !ok || token != Token {
+		writeError(w, &apiError{code: http.StatusUnauthorized, description: "Unauthorized"})
+		return
+	}
+
+	var result any
+	var err *apiError
+	switch method {
+	case "getUpdates":
+		result, err = s.getUpdates(r)
+	case "sendMessage":
+		result, err = s.sendMessage(r)
+	case "editMessageText":
+		result, err = s.editMessageText(r)
+	case "sendDocument":
+		result, err = s.sendDocument(r)
+	case "getFile":
+		result, err = s.getFile(r)
+	case "setWebhook":
+		result, err = s.setWebhook(r)
+	case "deleteWebhook":
+		result, err = s.deleteWebhook(r)
+	case "answerInlineQuery":
+		result, err = s.answerInlineQuery(r)
+	default:
+		err = &apiError{code: http.StatusNotFound, description: "Not Found"}
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": result})
+}
+
+func writeError(w http.ResponseWriter, err *apiError) {
+	resp := map[string]any{
+		"ok":          false,
+		"error_code":  err.code,
+		"description": err.description,
+	}
+	if err.retryAfter > 0 {
+		resp["parameters"] = map[string]any{"retry_after": err.retryAfter}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(err.code)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// decode decodes the JSON parameters of r into v.
+func decode(r *http.Request, v any) *apiError {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return badRequest("%v", err)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return badRequest("invalid JSON: %v", err)
+	}
+	return nil
+}
+
+func (s *Server) getUpdates(r *http.Request) (any, *apiError) {
+	var params telegram.GetUpdatesParams
+	if err := decode(r, &params); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	hasWebhook := s.webhook.URL != ""
+	s.mu.Unlock()
+	if hasWebhook {
+		return nil, &apiError{
+			code:        http.StatusConflict,
+			description: "Conflict: can't use getUpdates method while webhook is active; use deleteWebhook to delete the webhook first",
+		}
+	}
+	timeout := time.Duration(params.Timeout) * time.Second
+	if timeout > MaxPollTimeout {
+		timeout = MaxPollTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		s.mu.Lock()
+		// like the Bot API, updates before the offset are forgotten
+		for len(s.updates) > 0 && s.updates[0].UpdateID < params.Offset {
+			s.updates = s.updates[1:]
+		}
+		updates := append([]telegram.Update{}, s.updates...)
+		updated := s.updated
+		s.mu.Unlock()
+		if len(updates) > 0 {
+			return updates, nil
+		}
+		select {
+		case <-updated:
+		case <-timer.C:
+			return updates, nil
+		case <-r.Context().Done():
+			return updates, nil
+		}
+	}
+}
+
+func (s *Server) getFile(r *http.Request) (any, *apiError) {
+	var params telegram.GetFileParams
+	if err := decode(r, &params); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.files[params.FileID]
+	if !ok {
+		return nil, badRequest("invalid file_id")
+	}
+	return telegram.File{
+		FileID:   params.FileID,
+		FileSize: int64(len(data)),
+		FilePath: "documents/" + params.FileID,
+	}, nil
+}
+
+func (s *Server) answerInlineQuery(r *http.Request) (any, *apiError) {
+	var params struct {
+		InlineQueryID string `json:"inline_query_id"`
+		Results       []struct {
+			Type string `json:"type"`
+			telegram.InlineQueryResultArticle
+		} `json:"results"`
+		CacheTime  int  `json:"cache_time"`
+		IsPersonal bool `json:"is_personal"`
+	}
+	if err := decode(r, &params); err != nil {
+		return nil, err
+	}
+	if len(params.Results) > MaxInlineResults {
+		return nil, badRequest("RESULTS_TOO_MUCH")
+	}
+	answer := InlineAnswer{
+		QueryID:    params.InlineQueryID,
+		CacheTime:  params.CacheTime,
+		IsPersonal: params.IsPersonal,
+	}
+	ids := map[string]bool{}
+	for _, result := range params.Results {
+		if result.Type != "article" {
+			return nil, badRequest("unsupported result type %q", result.Type)
+		}
+		if result.ID == "" || len(result.ID) > MaxResultIDLength {
+			return nil, badRequest("RESULT_ID_INVALID")
+		}
+		if ids[result.ID] {
+			return nil, badRequest("RESULT_ID_DUPLICATE")
+		}
+		ids[result.ID] = true
+		if result.Title == "" {
+			return nil, badRequest("RESULT_TITLE_EMPTY")
+		}
+		content := result.InputMessageContent
+		if strings.TrimSpace(content.MessageText) == "" {
+			return nil, badRequest("MESSAGE_EMPTY")
+		}
+		plain, err := parseText(content.MessageText, content.ParseMode, MessageLimit)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkEntities(content.MessageText, content.ParseMode, content.Entities); err != nil {
+			return nil, err
+		}
+		answer.Results = append(answer.Results, InlineResult{
+			ID:          result.ID,
+			Title:       result.Title,
+			Description: result.Description,
+			Text:        content.MessageText,
+			ParseMode:   content.ParseMode,
+			PlainText:   plain,
+			Entities:    content.Entities,
+		})
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.queries[params.InlineQueryID] {
+		return nil, badRequest("query is too old and response timeout expired or query ID is invalid")
+	}
+	delete(s.queries, params.InlineQueryID)
+	s.inlineAnswers = append(s.inlineAnswers, answer)
+	return true, nil
+}
+
+// serveFile serves the download of a file, path is "<token>/<file_path>".
+func (s *Server) serveFile(w http.ResponseWriter, r *http.Request, path string) {
+	token, filePath, _ := strings.Cut(path, "/")
+	fileID, ok := strings.CutPrefix(filePath, "documents/")
+	s.mu.Lock()
+	data, found := s.files[fileID]
+	s.mu.Unlock()
+	if token != Token || !ok || !found {
+		http.NotFound(w, r)
+		return
+	}
+	w.Write(data)
+}
+
+func (s *Server) setWebhook(r *http.Request) (any, *apiError) {
+	var params telegram.SetWebhookParams
+	if err := decode(r, &params); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(params.URL, "https://") {
+		return nil, badRequest("bad webhook: An HTTPS URL must be provided for webhook")
+	}
+	if params.SecretToken != "" && !telegram.IsValidSecretToken(params.SecretToken) {
+		return nil, badRequest("secret token contains unallowed characters")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.webhook = params
+	return true, nil
+}
+
+func (s *Server) deleteWebhook(r *http.Request) (any, *apiError) {
+	var params telegram.DeleteWebhookParams
+	if err := decode(r, &params); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.webhook = telegram.SetWebhookParams{}
+	if params.DropPendingUpdates {
+		s.updates = nil
+	}
+	return true, nil
+}
+
+// throttle returns a 429 error if the call is throttled.
+func (s *Server) throttle() *apiError {
+	if s.throttled == 0 {
+		return nil
+	}
+	s.throttled--
+	return &apiError{
+		code:        http.StatusTooManyRequests,
+		description: fmt.Sprintf("Too Many Requests: retry after %d", s.retryAfter),
+		retryAfter:  s.retryAfter,
+	}
+}
+
+// parseText checks text in parseMode and its length and returns the text
+// without markup.
+func parseText(text, parseMode string, limit int) (string, *apiError) {
+	plain := text
+	var err error
+	switch parseMode {
+	case telegram.ModeMarkdownV2:
+		plain, err = ParseMarkdownV2(text)
+	case telegram.ModeHTML:
+		plain, err = ParseHTML(text)
+	}
+	if err != nil {
+		return "", badRequest("%v", err)
+	}
+	// like the Bot API, spaces around the text are dropped
+	plain = strings.TrimSpace(plain)
+	if textLength(plain) > limit {
+		return "", badRequest("message is too long")
+	}
+	return plain, nil
+}
+
+// checkEntities checks that entities are only sent without a parse mode
+// and lie within text.
+func checkEntities(text, parseMode string, ents []entities.MessageEntity) *apiError {
+	if len(ents) > 0 && parseMode != "" {
+		return badRequest("entities can't be used with parse_mode")
+	}
+	length := textLength(text)
+	for _, e := range ents {
+		if e.Offset < 0 || e.Length <= 0 || e.Offset+e.Length > length {
+			return badRequest("entity %s at %d+%d is beyond the end of the text", e.Type, e.Offset, e.Length)
+		}
+	}
+	return nil
+}
+
+// record records a message sent by the bot and returns it as the API does.
+func (s *Server) record(msg SentMessage) *telegram.Message {
+	if msg.MessageID == 0 {
+		msg.MessageID = s.nextMessageID
+		s.nextMessageID++
+	}
+	s.sent = append(s.sent, msg)
+	if s.texts[msg.ChatID] == nil {
+		s.texts[msg.ChatID] = map[int]string{}
+	}
+	s.texts[msg.ChatID][msg.MessageID] = msg.Text
+	result := &telegram.Message{
+		MessageID: msg.MessageID,
+		Chat:      telegram.Chat{ID: msg.ChatID, Type: "private"},
+		Date:      time.Now().Unix(),
+		Text:      msg.PlainText,
+	}
+	if doc := msg.Document; doc != nil {
+		result.Text = ""
+		result.Caption = msg.PlainText
+		result.Document = &telegram.Document{
+			FileID:   "file-" + strconv.Itoa(msg.MessageID),
+			FileName: doc.FileName,
+			FileSize: int64(len(doc.Data)),
+		}
+	}
+	return result
+}
+
+func (s *Server) sendMessage(r *http.Request) (any, *apiError) {
+	var params telegram.SendMessageParams
+	if err := decode(r, &params); err != nil {
+		return nil, err
+	}
+	if params.ChatID == 0 {
+		return nil, badRequest("chat not found")
+	}
+	if strings.TrimSpace(params.Text) == "" {
+		return nil, badRequest("message text is empty")
+	}
+	plain, err := parseText(params.Text, params.ParseMode, MessageLimit)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkEntities(params.Text, params.ParseMode, params.Entities); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.throttle(); err != nil {
+		return nil, err
+	}
+	return s.record(SentMessage{
+		Method:    "sendMessage",
+		ChatID:    params.ChatID,
+		Text:      params.Text,
+		ParseMode: params.ParseMode,
+		PlainText: plain,
+		Entities:  params.Entities,
+	}), nil
+}
+
+func (s *Server) editMessageText(r *http.Request) (any, *apiError) {
+	var params telegram.EditMessageTextParams
+	if err := decode(r, &params); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(params.Text) == "" {
+		return nil, badRequest("message text is empty")
+	}
+	plain, err := parseText(params.Text, params.ParseMode, MessageLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.texts[params.ChatID][params.MessageID]
+	if !ok {
+		return nil, badRequest("message to edit not found")
+	}
+	if old == params.Text {
+		return nil, badRequest("message is not modified: specified new message content and reply markup are exactly the same as a current content and reply markup of the message")
+	}
+	if err := s.throttle(); err != nil {
+		return nil, err
+	}
+	return s.record(SentMessage{
+		Method:    "editMessageText",
+		ChatID:    params.ChatID,
+		MessageID: params.MessageID,
+		Text:      params.Text,
+		ParseMode: params.ParseMode,
+		PlainText: plain,
+	}), nil
+}
+
+func (s *Server) sendDocument(r *http.Request) (any, *apiError) {
+	if err := r.ParseMultipartForm(50 << 20); err != nil {
+		return nil, badRequest("%v", err)
+	}
+	chatID, _ := strconv.ParseInt(r.FormValue("chat_id"), 10, 64)
+	if chatID == 0 {
+		return nil, badRequest("chat not found")
+	}
+	file, header, ferr := r.FormFile("document")
+	if ferr != nil {
+		return nil, badRequest("there is no document in the request")
+	}
+	defer file.Close()
+	data, ferr := io.ReadAll(file)
+	if ferr != nil {
+		return nil, badRequest("%v", ferr)
+	}
+	if len(data) == 0 {
+		return nil, badRequest("file must be non-empty")
+	}
+	caption, parseMode := r.FormValue("caption"), r.FormValue("parse_mode")
+	plain, err := parseText(caption, parseMode, CaptionLimit)
+	if err != nil {
+		if err.description == "Bad Request: message is too long" {
+			err = badRequest("message caption is too long")
+		}
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.throttle(); err != nil {
+		return nil, err
+	}
+	return s.record(SentMessage{
+		Method:    "sendDocument",
+		ChatID:    chatID,
+		Text:      caption,
+		ParseMode: parseMode,
+		PlainText: plain,
+		Document:  &Document{FileName: header.Filename, Data: data, Caption: caption},
+	}), nil
+}
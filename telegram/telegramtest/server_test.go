@@ -0,0 +1,322 @@
+package telegramtest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eternalsad/markdownify/entities"
+	"github.com/eternalsad/markdownify/telegram"
+)
+
+func TestParseMarkdownV2(t *testing.T) {
+	tests := []struct {
+		text  string
+		plain string
+		err   string
+	}{
+		{"*bold* _italic_ __under__ ~strike~ ||spoiler||", "bold italic under strike spoiler", ""},
+		{"[link](https://example.com/a\\)b) and `code.` 1\\.", "link and code. 1.", ""},
+		{"```go\nfmt.Println(\"*\")\n```", "\nfmt.Println(\"*\")\n", ""},
+		{">quote\n**>expandable||", "quote\nexpandable", ""},
+		{"end.", "", "Character '.' is reserved and must be escaped with the preceding '\\'"},
+		{"ab *bold", "", "Can't find end of Bold entity at byte offset 3"},
+		{"*a _b* c_", "", "Can't find end of Italic entity at byte offset 3"},
+		{"[link](https://example.com", "", "Can't find end of a URL at byte offset 6"},
+	}
+	for _, test := range tests {
+		plain, err := ParseMarkdownV2(test.text)
+		if test.err != "" {
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) || parseErr.Message != test.err {
+				t.Errorf("%q: err = %v, expected %q", test.text, err, test.err)
+			}
+			continue
+		}
+		if err != nil || plain != test.plain {
+			t.Errorf("%q: got %q, %v, expected %q", test.text, plain, err, test.plain)
+		}
+	}
+}
+
+func TestSendMessage(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	bot := srv.Bot()
+	ctx := context.Background()
+
+	if err := bot.SendMarkdownMessage(ctx, 42, "*hi*"); err != nil {
+		t.Fatal(err)
+	}
+	err := bot.SendMarkdownMessage(ctx, 42, "hi.")
+	if !telegram.IsBadRequest(err) || !strings.Contains(err.Error(), "can't parse entities") {
+		t.Errorf("err = %v", err)
+	}
+	err = bot.SendMarkdownMessage(ctx, 42, strings.Repeat("a", MessageLimit+1))
+	if !telegram.IsBadRequest(err) || !strings.Contains(err.Error(), "message is too long") {
+		t.Errorf("err = %v", err)
+	}
+
+	msgs := srv.Messages()
+	if len(msgs) != 1 || msgs[0].Text != "*hi*" || msgs[0].PlainText != "hi" || msgs[0].ChatID != 42 {
+		t.Errorf("messages = %+v", msgs)
+	}
+}
+
+func TestSendEntities(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	bot := srv.Bot()
+	ctx := context.Background()
+
+	bold := []entities.MessageEntity{{Type: "bold", Offset: 0, Length: 4}}
+	if _, err := bot.SendMessage(ctx, telegram.SendMessageParams{ChatID: 1, Text: "bold text", Entities: bold}); err != nil {
+		t.Fatal(err)
+	}
+	msgs := srv.Messages()
+	if len(msgs) != 1 || !reflect.DeepEqual(msgs[0].Entities, bold) || msgs[0].PlainText != "bold text" {
+		t.Errorf("messages = %+v", msgs)
+	}
+
+	beyond := []entities.MessageEntity{{Type: "bold", Offset: 2, Length: 4}}
+	if _, err := bot.SendMessage(ctx, telegram.SendMessageParams{ChatID: 1, Text: "text", Entities: beyond}); !telegram.IsBadRequest(err) {
+		t.Errorf("err = %v, expected a bad request", err)
+	}
+	_, err := bot.SendMessage(ctx, telegram.SendMessageParams{ChatID: 1, Text: "bold text", ParseMode: telegram.ModeMarkdownV2, Entities: bold})
+	if !telegram.IsBadRequest(err) {
+		t.Errorf("err = %v, expected a bad request", err)
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	bot := srv.Bot()
+	srv.Throttle(1, 3)
+
+	err := bot.SendMarkdownMessage(context.Background(), 42, "hi")
+	var apiErr *telegram.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != 429 || apiErr.RetryAfter != 3*time.Second {
+		t.Errorf("err = %v", err)
+	}
+	if err := bot.SendMarkdownMessage(context.Background(), 42, "hi"); err != nil {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestEditAndDocument(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	bot := srv.Bot()
+	ctx := context.Background()
+
+	msg, err := bot.SendMessage(ctx, telegram.SendMessageParams{ChatID: 42, Text: "draft"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := telegram.EditMessageTextParams{ChatID: 42, MessageID: msg.MessageID, Text: "final"}
+	if _, err := bot.EditMessageText(ctx, params); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bot.EditMessageText(ctx, params); !telegram.IsBadRequest(err) {
+		t.Errorf("editing with the same text: err = %v", err)
+	}
+	params.MessageID = 1000
+	if _, err := bot.EditMessageText(ctx, params); !telegram.IsBadRequest(err) {
+		t.Errorf("editing a missing message: err = %v", err)
+	}
+
+	doc, err := bot.SendDocument(ctx, telegram.SendDocumentParams{
+		ChatID:    42,
+		FileName:  "code.go",
+		Data:      []byte("package main\n"),
+		Caption:   "*code*",
+		ParseMode: telegram.ModeMarkdownV2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Document == nil || doc.Document.FileName != "code.go" || doc.Caption != "code" {
+		t.Errorf("document = %+v", doc)
+	}
+
+	msgs := srv.Messages()
+	if len(msgs) != 3 || msgs[1].Method != "editMessageText" || msgs[1].MessageID != msg.MessageID ||
+		msgs[2].Document == nil || string(msgs[2].Document.Data) != "package main\n" {
+		t.Errorf("messages = %+v", msgs)
+	}
+}
+
+func TestGetUpdates(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	bot := srv.Bot()
+	ctx := context.Background()
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		srv.SendText(42, "hello")
+	}()
+	updates, err := bot.GetUpdates(ctx, 0, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updates) != 1 || updates[0].Message.Text != "hello" {
+		t.Fatalf("updates = %+v", updates)
+	}
+	updates, err = bot.GetUpdates(ctx, updates[0].UpdateID+1, 0)
+	if err != nil || len(updates) != 0 {
+		t.Errorf("updates = %+v, %v", updates, err)
+	}
+}
+
+func TestFiles(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	bot := srv.Bot()
+	ctx := context.Background()
+
+	update := srv.SendFile(42, "notes.md", []byte("# Notes\n"))
+	doc := update.Message.Document
+	if doc == nil || doc.FileName != "notes.md" || doc.FileSize != 8 {
+		t.Fatalf("document = %+v", doc)
+	}
+	file, err := bot.GetFile(ctx, doc.FileID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := bot.DownloadFile(ctx, file.FilePath, 0)
+	if err != nil || string(data) != "# Notes\n" {
+		t.Errorf("data = %q, %v", data, err)
+	}
+	if _, err := bot.DownloadFile(ctx, file.FilePath, 4); !errors.Is(err, telegram.ErrFileTooLarge) {
+		t.Errorf("err = %v, expected ErrFileTooLarge", err)
+	}
+	if _, err := bot.GetFile(ctx, "missing"); !telegram.IsBadRequest(err) {
+		t.Errorf("err = %v, expected a bad request", err)
+	}
+	var apiErr *telegram.Error
+	if _, err := bot.DownloadFile(ctx, "documents/missing", 0); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("err = %v, expected not found", err)
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	bot := srv.Bot()
+	ctx := context.Background()
+
+	if err := bot.SetWebhook(ctx, telegram.SetWebhookParams{URL: "http://example.com"}); !telegram.IsBadRequest(err) {
+		t.Errorf("err = %v, expected a bad request for plain HTTP", err)
+	}
+	params := telegram.SetWebhookParams{URL: "https://example.com/webhook", SecretToken: "s3cret"}
+	if err := bot.SetWebhook(ctx, params); err != nil {
+		t.Fatal(err)
+	}
+	if got := srv.Webhook(); got.URL != params.URL || got.SecretToken != params.SecretToken {
+		t.Errorf("webhook = %+v", got)
+	}
+	var apiErr *telegram.Error
+	if _, err := bot.GetUpdates(ctx, 0, 0); !errors.As(err, &apiErr) || apiErr.Code != http.StatusConflict {
+		t.Errorf("err = %v, expected a conflict", err)
+	}
+	if err := bot.DeleteWebhook(ctx, telegram.DeleteWebhookParams{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bot.GetUpdates(ctx, 0, 0); err != nil {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestUnauthorized(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	bot := telegram.NewBot("wrong", telegram.BotOptions{BaseURL: srv.URL})
+	var apiErr *telegram.Error
+	if err := bot.SendMarkdownMessage(context.Background(), 1, "x"); !errors.As(err, &apiErr) || apiErr.Code != 401 {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestParseHTML(t *testing.T) {
+	tests := []struct {
+		text  string
+		plain string
+		err   string
+	}{
+		{`<b>bold</b> <i>it</i> <a href="https://example.com">link</a> a &lt; b &amp;&#33;`, "bold it link a < b &!", ""},
+		{`<pre><code class="language-go">x</code></pre><span class="tg-spoiler">s</span>`, "xs", ""},
+		{"<div>x</div>", "", `Unsupported start tag "div" at byte offset 0`},
+		{"<b>x", "", `Can't find end tag corresponding to start tag "b"`},
+		{"<b><i>x</b></i>", "", `Unmatched end tag at byte offset 7, found "</b>"`},
+		{"<code><b>x</b></code>", "", "Entities can't be nested in code at byte offset 6"},
+		{"a > b", "", "Unexpected end of tag at byte offset 2"},
+	}
+	for _, test := range tests {
+		plain, err := ParseHTML(test.text)
+		if test.err != "" {
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) || parseErr.Message != test.err {
+				t.Errorf("%q: err = %v, expected %q", test.text, err, test.err)
+			}
+			continue
+		}
+		if err != nil || plain != test.plain {
+			t.Errorf("%q: got %q, %v, expected %q", test.text, plain, err, test.plain)
+		}
+	}
+}
+
+func TestInlineQuery(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	bot := srv.Bot()
+	ctx := context.Background()
+
+	update := srv.SendInlineQuery(7, "**hi**")
+	updates, err := bot.GetUpdates(ctx, 0, 0)
+	if err != nil || len(updates) != 1 || updates[0].InlineQuery == nil || updates[0].InlineQuery.Query != "**hi**" {
+		t.Fatalf("updates = %+v, err = %v", updates, err)
+	}
+	article := func(id, text, parseMode string) telegram.InlineQueryResultArticle {
+		return telegram.InlineQueryResultArticle{
+			ID:                  id,
+			Title:               id,
+			InputMessageContent: telegram.InputTextMessageContent{MessageText: text, ParseMode: parseMode},
+		}
+	}
+	params := telegram.AnswerInlineQueryParams{
+		InlineQueryID: update.InlineQuery.ID,
+		Results:       []telegram.InlineQueryResultArticle{article("md", "*hi*", telegram.ModeMarkdownV2), article("md", "hi", "")},
+	}
+	if err := bot.AnswerInlineQuery(ctx, params); !telegram.IsBadRequest(err) || !strings.Contains(err.Error(), "RESULT_ID_DUPLICATE") {
+		t.Errorf("err = %v", err)
+	}
+	params.Results[1] = article("html", "<i>hi", telegram.ModeHTML)
+	if err := bot.AnswerInlineQuery(ctx, params); !telegram.IsParseError(err) {
+		t.Errorf("err = %v", err)
+	}
+	params.Results[1] = article("html", "<b>hi</b>", telegram.ModeHTML)
+	if err := bot.AnswerInlineQuery(ctx, params); err != nil {
+		t.Fatal(err)
+	}
+	// a query is answered once
+	if err := bot.AnswerInlineQuery(ctx, params); !telegram.IsBadRequest(err) {
+		t.Errorf("err = %v", err)
+	}
+
+	answers := srv.InlineAnswers()
+	if len(answers) != 1 || len(answers[0].Results) != 2 {
+		t.Fatalf("answers = %+v", answers)
+	}
+	for _, result := range answers[0].Results {
+		if result.PlainText != "hi" {
+			t.Errorf("result = %+v", result)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package telegram
+
+import (
+	"encoding/json"
+
+	"github.com/eternalsad/markdownify/entities"
+)
+
+// Update is an incoming update, see https://core.telegram.org/bots/api#update.
+type Update struct {
+	UpdateID    int          `json:"update_id"`
+	Message     *Message     `json:"message,omitempty"`
+	InlineQuery *InlineQuery `json:"inline_query,omitempty"`
+}
+
+// Message is a message, see https://core.telegram.org/bots/api#message.
+type Message struct {
+	MessageID int       `json:"message_id"`
+	Chat      Chat      `json:"chat"`
+	Date      int64     `json:"date,omitempty"`
+	Text      string    `json:"text,omitempty"`
+	Document  *Document `json:"document,omitempty"`
+	Caption   string    `json:"caption,omitempty"`
+}
+
+// Document is a file sent as a document, see
+// https://core.telegram.org/bots/api#document.
+type Document struct {
+	FileID   string `json:"file_id"`
+	FileName string `json:"file_name,omitempty"`
+	MimeType string `json:"mime_type,omitempty"`
+	FileSize int64  `json:"file_size,omitempty"`
+}
+
+// File is a file ready to be downloaded, see
+// https://core.telegram.org/bots/api#file.
+type File struct {
+	FileID       string `json:"file_id"`
+	FileUniqueID string `json:"file_unique_id,omitempty"`
+	FileSize     int64  `json:"file_size,omitempty"`
+	// FilePath is the path to download the file from with DownloadFile.
+	FilePath string `json:"file_path,omitempty"`
+}
+
+// Chat is a chat, see https://core.telegram.org/bots/api#chat.
+type Chat struct {
+	ID   int64  `json:"id"`
+	Type string `json:"type,omitempty"`
+}
+
+// User is a user or a bot, see https://core.telegram.org/bots/api#user.
+type User struct {
+	ID        int64  `json:"id"`
+	IsBot     bool   `json:"is_bot,omitempty"`
+	FirstName string `json:"first_name,omitempty"`
+	Username  string `json:"username,omitempty"`
+}
+
+// InlineQuery is a query typed after the username of the bot in any chat,
+// see https://core.telegram.org/bots/api#inlinequery.
+type InlineQuery struct {
+	ID    string `json:"id"`
+	From  User   `json:"from"`
+	Query string `json:"query"`
+	// Offset is the offset of the results to return, for pagination.
+	Offset string `json:"offset,omitempty"`
+	// ChatType is the type of the chat the query was sent from.
+	ChatType string `json:"chat_type,omitempty"`
+}
+
+// InlineQueryResultArticle is a result of an inline query that sends a
+// text message, see
+// https://core.telegram.org/bots/api#inlinequeryresultarticle. Its type is
+// always "article".
+type InlineQueryResultArticle struct {
+	ID                  string                  `json:"id"`
+	Title               string                  `json:"title"`
+	InputMessageContent InputTextMessageContent `json:"input_message_content"`
+	Description         string                  `json:"description,omitempty"`
+}
+
+// MarshalJSON adds the type to the result.
+func (r InlineQueryResultArticle) MarshalJSON() ([]byte, error) {
+	type article InlineQueryResultArticle
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		article
+	}{"article", article(r)})
+}
+
+// InputTextMessageContent is the message sent when an inline result is
+// chosen, see https://core.telegram.org/bots/api#inputtextmessagecontent.
+// Entities format the text when there is no ParseMode.
+type InputTextMessageContent struct {
+	MessageText string                   `json:"message_text"`
+	ParseMode   string                   `json:"parse_mode,omitempty"`
+	Entities    []entities.MessageEntity `json:"entities,omitempty"`
+}
+
+// Parse modes of messages.
+const (
+	ModeMarkdownV2 = "MarkdownV2"
+	ModeHTML       = "HTML"
+)
+
+// SendMessageParams are the parameters of sendMessage. Entities format
+// the text when there is no ParseMode.
+type SendMessageParams struct {
+	ChatID    int64                    `json:"chat_id"`
+	Text      string                   `json:"text"`
+	ParseMode string                   `json:"parse_mode,omitempty"`
+	Entities  []entities.MessageEntity `json:"entities,omitempty"`
+}
+
+// EditMessageTextParams are the parameters of editMessageText.
+type EditMessageTextParams struct {
+	ChatID    int64  `json:"chat_id"`
+	MessageID int    `json:"message_id"`
+	Text      string `json:"text"`
+	ParseMode string `json:"parse_mode,omitempty"`
+}
+
+// SendDocumentParams are the parameters of sendDocument. The document is
+// uploaded from Data.
+type SendDocumentParams struct {
+	ChatID    int64
+	FileName  string
+	Data      []byte
+	Caption   string
+	ParseMode string
+}
+
+// GetUpdatesParams are the parameters of getUpdates.
+type GetUpdatesParams struct {
+	Offset int `json:"offset,omitempty"`
+	// Timeout of long polling in seconds.
+	Timeout int `json:"timeout,omitempty"`
+}
+
+// AnswerInlineQueryParams are the parameters of answerInlineQuery.
+type AnswerInlineQueryParams struct {
+	InlineQueryID string                     `json:"inline_query_id"`
+	Results       []InlineQueryResultArticle `json:"results"`
+	// CacheTime is how long in seconds the results may be cached, the Bot
+	// API caches them for 300 seconds if 0.
+	CacheTime int `json:"cache_time,omitempty"`
+	// IsPersonal makes the results cached only for the user who sent the
+	// query.
+	IsPersonal bool `json:"is_personal,omitempty"`
+}
+
+// GetFileParams are the parameters of getFile.
+type GetFileParams struct {
+	FileID string `json:"file_id"`
+}
+
+// SetWebhookParams are the parameters of setWebhook.
+type SetWebhookParams struct {
+	URL string `json:"url"`
+	// SecretToken is sent back in SecretTokenHeader of every webhook
+	// request, 1-256 characters A-Z, a-z, 0-9, _ and -.
+	SecretToken        string   `json:"secret_token,omitempty"`
+	AllowedUpdates     []string `json:"allowed_updates,omitempty"`
+	DropPendingUpdates bool     `json:"drop_pending_updates,omitempty"`
+}
+
+// DeleteWebhookParams are the parameters of deleteWebhook.
+type DeleteWebhookParams struct {
+	DropPendingUpdates bool `json:"drop_pending_updates,omitempty"`
+}
+
+// ResponseParameters tell why a request failed and how it can be retried.
+type ResponseParameters struct {
+	MigrateToChatID int64 `json:"migrate_to_chat_id,omitempty"`
+	RetryAfter      int   `json:"retry_after,omitempty"`
+}
+
+// response is the envelope of all Bot API responses.
+type response struct {
+	Ok          bool                `json:"ok"`
+	Result      json.RawMessage     `json:"result,omitempty"`
+	ErrorCode   int                 `json:"error_code,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Parameters  *ResponseParameters `json:"parameters,omitempty"`
+}
@@ -0,0 +1,66 @@
+package telegram
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"net/http"
+	"regexp"
+)
+
+// SecretTokenHeader is the header of webhook requests carrying the
+// secret_token passed to setWebhook.
+const SecretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// maxUpdateSize bounds the body of webhook requests.
+const maxUpdateSize = 1 << 20
+
+// UpdateHandler handles an update, whether it was received with
+// getUpdates or by a webhook.
+type UpdateHandler func(ctx context.Context, update Update)
+
+// secretTokenRe matches the secret tokens accepted by setWebhook.
+var secretTokenRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+
+// IsValidSecretToken tells if setWebhook accepts token as secret_token.
+func IsValidSecretToken(token string) bool {
+	return secretTokenRe.MatchString(token)
+}
+
+// WebhookHandler returns a handler of the requests the Bot API sends to a
+// webhook. Every update is passed to handle before the request is
+// answered, so the Bot API sends the next update of a chat only after the
+// previous one was handled.
+//
+// Requests without secretToken in SecretTokenHeader are rejected with 401.
+// A blank secretToken turns the check off, anyone who finds the URL can
+// then send updates.
+func WebhookHandler(secretToken string, handle UpdateHandler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		got := r.Header.Get(SecretTokenHeader)
+		if secretToken != "" && subtle.ConstantTimeCompare([]byte(got), []byte(secretToken)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxUpdateSize))
+		if err != nil {
+			http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		var update Update
+		if err := json.Unmarshal(data, &update); err != nil {
+			http.Error(w, "invalid update", http.StatusBadRequest)
+			return
+		}
+		// the update is handled even if the Bot API stops waiting, it
+		// would be sent again otherwise
+		handle(context.WithoutCancel(r.Context()), update)
+		w.WriteHeader(http.StatusOK)
+	})
+}
@@ -0,0 +1,74 @@
+package telegram
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWebhookHandler(t *testing.T) {
+	var got []Update
+	handler := WebhookHandler("s3cret", func(ctx context.Context, update Update) {
+		got = append(got, update)
+	})
+	post := func(secret, body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body))
+		if secret != "" {
+			req.Header.Set(SecretTokenHeader, secret)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	update := `{"update_id":3,"message":{"message_id":1,"chat":{"id":42},"text":"hi"}}`
+	if code := post("s3cret", update); code != http.StatusOK {
+		t.Errorf("code = %d", code)
+	}
+	if code := post("", update); code != http.StatusUnauthorized {
+		t.Errorf("no secret: code = %d", code)
+	}
+	if code := post("wrong", update); code != http.StatusUnauthorized {
+		t.Errorf("wrong secret: code = %d", code)
+	}
+	if code := post("s3cret", "{"); code != http.StatusBadRequest {
+		t.Errorf("invalid JSON: code = %d", code)
+	}
+	if len(got) != 1 || got[0].UpdateID != 3 || got[0].Message == nil || got[0].Message.Text != "hi" {
+		t.Errorf("updates = %+v", got)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/webhook", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: code = %d", rec.Code)
+	}
+}
+
+func TestIsValidSecretToken(t *testing.T) {
+	for token, valid := range map[string]bool{
+		"abc_DEF-123":            true,
+		"":                       false,
+		"with space":             false,
+		strings.Repeat("a", 257): false,
+	} {
+		if IsValidSecretToken(token) != valid {
+			t.Errorf("IsValidSecretToken(%q) = %v", token, !valid)
+		}
+	}
+}
+
+func TestSetWebhook(t *testing.T) {
+	bot := newTestBot(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bot123:abc/setWebhook" {
+			t.Errorf("path = %q", r.URL.Path)
+		}
+		w.Write([]byte(`{"ok":true,"result":true}`))
+	})
+	err := bot.SetWebhook(context.Background(), SetWebhookParams{URL: "https://example.com/webhook", SecretToken: "s3cret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,46 @@
+package whatsapp
+
+import (
+	"testing"
+
+	"github.com/eternalsad/markdownify/internal/rendertest"
+)
+
+func testRendering(t *testing.T, source string, expected string) {
+	t.Helper()
+	rendertest.Expect(t, source, expected, rendertest.Render(NewRenderer(RendererOptions{}), source))
+}
+
+func TestRenderInline(t *testing.T) {
+	testRendering(t, "**bold** *italic* ~~strike~~ `a < b`\n", "*bold* _italic_ ~strike~ ```a < b```\n\n")
+	testRendering(t, "Tom & Jerry\n", "Tom & Jerry\n\n")
+}
+
+func TestRenderLinks(t *testing.T) {
+	testRendering(t, "[docs](https://go.dev/doc)\n", "docs (https://go.dev/doc)\n\n")
+	testRendering(t, "see https://go.dev\n", "see https://go.dev\n\n")
+	testRendering(t, "![logo](https://go.dev/logo.png)\n", "logo (https://go.dev/logo.png)\n\n")
+}
+
+func TestRenderHeading(t *testing.T) {
+	testRendering(t, "# Title with **bold**\n\ntext\n", "*TITLE WITH BOLD*\n\ntext\n\n")
+}
+
+func TestRenderCodeBlock(t *testing.T) {
+	testRendering(t, "```go\nif a < b {}\n```\n", "```\nif a < b {}\n```\n\n")
+}
+
+func TestRenderBlockQuote(t *testing.T) {
+	testRendering(t, "> first\n>\n> **second**\n", "> first\n>\n> *second*\n\n")
+}
+
+func TestRenderLists(t *testing.T) {
+	testRendering(t, "- a\n    - b\n- [x] c\n", "- a\n   - b\n- ☑ c\n\n")
+	testRendering(t, "3. a\n4. b\n", "3. a\n4. b\n\n")
+}
+
+func TestRenderTable(t *testing.T) {
+	source := "| Name | Year |\n|---|---|\n| Go | 2009 |\n| Rust | 2010 |\n"
+	expected := "```\nName | Year\n-----+-----\nGo   | 2009\nRust | 2010\n```\n\n"
+	testRendering(t, source, expected)
+}